@@ -0,0 +1,253 @@
+-- Canonical ingredients used to normalize the free-form ingredient names in
+-- recipes. Names and synonyms must already be in the form produced by the
+-- normalizer package: lower case with a singular final word.
+DELETE FROM ingredient_catalog;
+INSERT INTO ingredient_catalog (name, category) VALUES
+-- Produce
+('tomato', 'produce'),
+('onion', 'produce'),
+('red onion', 'produce'),
+('green onion', 'produce'),
+('garlic', 'produce'),
+('potato', 'produce'),
+('sweet potato', 'produce'),
+('carrot', 'produce'),
+('celery', 'produce'),
+('bell pepper', 'produce'),
+('jalapeno', 'produce'),
+('zucchini', 'produce'),
+('cucumber', 'produce'),
+('broccoli', 'produce'),
+('cauliflower', 'produce'),
+('spinach', 'produce'),
+('lettuce', 'produce'),
+('kale', 'produce'),
+('cabbage', 'produce'),
+('mushroom', 'produce'),
+('corn', 'produce'),
+('pea', 'produce'),
+('green bean', 'produce'),
+('asparagus', 'produce'),
+('avocado', 'produce'),
+('lemon', 'produce'),
+('lime', 'produce'),
+('apple', 'produce'),
+('banana', 'produce'),
+('strawberry', 'produce'),
+('blueberry', 'produce'),
+('orange', 'produce'),
+('ginger', 'produce'),
+('basil', 'produce'),
+('parsley', 'produce'),
+('cilantro', 'produce'),
+('rosemary', 'produce'),
+('thyme', 'produce'),
+('mint', 'produce'),
+-- Dairy and eggs
+('milk', 'dairy'),
+('buttermilk', 'dairy'),
+('butter', 'dairy'),
+('heavy cream', 'dairy'),
+('sour cream', 'dairy'),
+('yogurt', 'dairy'),
+('cream cheese', 'dairy'),
+('parmesan cheese', 'dairy'),
+('cheddar cheese', 'dairy'),
+('mozzarella cheese', 'dairy'),
+('feta cheese', 'dairy'),
+('egg', 'dairy'),
+-- Meat and seafood
+('chicken breast', 'meat'),
+('chicken thigh', 'meat'),
+('ground beef', 'meat'),
+('beef steak', 'meat'),
+('pork chop', 'meat'),
+('bacon', 'meat'),
+('sausage', 'meat'),
+('ham', 'meat'),
+('ground turkey', 'meat'),
+('salmon', 'seafood'),
+('shrimp', 'seafood'),
+('tuna', 'seafood'),
+('cod', 'seafood'),
+-- Grains and baking
+('all-purpose flour', 'baking'),
+('whole wheat flour', 'baking'),
+('sugar', 'baking'),
+('brown sugar', 'baking'),
+('powdered sugar', 'baking'),
+('baking powder', 'baking'),
+('baking soda', 'baking'),
+('yeast', 'baking'),
+('vanilla extract', 'baking'),
+('chocolate chip', 'baking'),
+('cocoa powder', 'baking'),
+('honey', 'baking'),
+('maple syrup', 'baking'),
+('rice', 'grains'),
+('spaghetti', 'grains'),
+('pasta', 'grains'),
+('bread', 'grains'),
+('tortilla', 'grains'),
+('oats', 'grains'),
+('quinoa', 'grains'),
+('breadcrumb', 'grains'),
+-- Legumes and nuts
+('black bean', 'legumes'),
+('chickpea', 'legumes'),
+('lentil', 'legumes'),
+('tofu', 'legumes'),
+('peanut butter', 'nuts'),
+('almond', 'nuts'),
+('walnut', 'nuts'),
+('pine nut', 'nuts'),
+('cashew', 'nuts'),
+-- Oils, condiments and spices
+('olive oil', 'oils'),
+('vegetable oil', 'oils'),
+('sesame oil', 'oils'),
+('soy sauce', 'condiments'),
+('vinegar', 'condiments'),
+('balsamic vinegar', 'condiments'),
+('mayonnaise', 'condiments'),
+('mustard', 'condiments'),
+('ketchup', 'condiments'),
+('chicken broth', 'condiments'),
+('vegetable broth', 'condiments'),
+('tomato paste', 'condiments'),
+('salt', 'spices'),
+('black pepper', 'spices'),
+('paprika', 'spices'),
+('cumin', 'spices'),
+('chili powder', 'spices'),
+('cinnamon', 'spices'),
+('oregano', 'spices'),
+('red pepper flake', 'spices'),
+('garlic powder', 'spices'),
+('onion powder', 'spices'),
+('nutmeg', 'spices'),
+-- Alternatives for special diets
+('oat milk', 'plant-based'),
+('soy milk', 'plant-based'),
+('almond milk', 'plant-based'),
+('coconut milk', 'plant-based'),
+('vegan butter', 'plant-based'),
+('nutritional yeast', 'plant-based'),
+('coconut oil', 'oils'),
+('flaxseed', 'baking'),
+('gluten-free flour', 'baking'),
+('gluten-free pasta', 'grains'),
+('corn tortilla', 'grains'),
+('tamari', 'condiments');
+
+INSERT INTO ingredient_synonyms (catalog_id, synonym)
+SELECT c.id, s.synonym FROM (VALUES
+    ('tomato', 'roma tomato'),
+    ('tomato', 'cherry tomato'),
+    ('tomato', 'plum tomato'),
+    ('onion', 'yellow onion'),
+    ('onion', 'white onion'),
+    ('green onion', 'scallion'),
+    ('green onion', 'spring onion'),
+    ('garlic', 'garlic clove'),
+    ('bell pepper', 'red bell pepper'),
+    ('bell pepper', 'green bell pepper'),
+    ('bell pepper', 'capsicum'),
+    ('zucchini', 'courgette'),
+    ('basil', 'basil leaf'),
+    ('cilantro', 'coriander leaf'),
+    ('spinach', 'baby spinach'),
+    ('heavy cream', 'whipping cream'),
+    ('heavy cream', 'double cream'),
+    ('parmesan cheese', 'parmesan'),
+    ('parmesan cheese', 'parmigiano reggiano'),
+    ('cheddar cheese', 'cheddar'),
+    ('mozzarella cheese', 'mozzarella'),
+    ('feta cheese', 'feta'),
+    ('yogurt', 'greek yogurt'),
+    ('butter', 'unsalted butter'),
+    ('butter', 'salted butter'),
+    ('milk', 'whole milk'),
+    ('egg', 'egg yolk'),
+    ('egg', 'egg white'),
+    ('chicken breast', 'chicken'),
+    ('ground beef', 'minced beef'),
+    ('ground beef', 'beef mince'),
+    ('shrimp', 'prawn'),
+    ('all-purpose flour', 'flour'),
+    ('all-purpose flour', 'plain flour'),
+    ('sugar', 'granulated sugar'),
+    ('sugar', 'white sugar'),
+    ('powdered sugar', 'icing sugar'),
+    ('powdered sugar', 'confectioners sugar'),
+    ('chocolate chip', 'semisweet chocolate chip'),
+    ('rice', 'white rice'),
+    ('rice', 'brown rice'),
+    ('oats', 'rolled oats'),
+    ('breadcrumb', 'panko'),
+    ('chickpea', 'garbanzo bean'),
+    ('olive oil', 'extra virgin olive oil'),
+    ('vegetable oil', 'canola oil'),
+    ('chicken broth', 'chicken stock'),
+    ('vegetable broth', 'vegetable stock'),
+    ('black pepper', 'pepper'),
+    ('salt', 'kosher salt'),
+    ('salt', 'sea salt')
+) AS s (name, synonym)
+JOIN ingredient_catalog c ON c.name = s.name;
+
+-- Allergens follow the 14 major allergens that must be declared on food
+-- labels. The animal source decides which diets an ingredient fits: 'product'
+-- rules out vegan, 'fish' also rules out vegetarian and 'meat' also rules out
+-- pescatarian.
+UPDATE ingredient_catalog c SET allergens = a.allergens FROM (VALUES
+    ('all-purpose flour', ARRAY['gluten']),
+    ('whole wheat flour', ARRAY['gluten']),
+    ('spaghetti', ARRAY['gluten']),
+    ('pasta', ARRAY['gluten']),
+    ('bread', ARRAY['gluten']),
+    ('tortilla', ARRAY['gluten']),
+    ('oats', ARRAY['gluten']),
+    ('oat milk', ARRAY['gluten']),
+    ('breadcrumb', ARRAY['gluten']),
+    ('soy sauce', ARRAY['gluten', 'soybeans']),
+    ('milk', ARRAY['milk']),
+    ('buttermilk', ARRAY['milk']),
+    ('butter', ARRAY['milk']),
+    ('heavy cream', ARRAY['milk']),
+    ('sour cream', ARRAY['milk']),
+    ('yogurt', ARRAY['milk']),
+    ('cream cheese', ARRAY['milk']),
+    ('parmesan cheese', ARRAY['milk']),
+    ('cheddar cheese', ARRAY['milk']),
+    ('mozzarella cheese', ARRAY['milk']),
+    ('feta cheese', ARRAY['milk']),
+    ('chocolate chip', ARRAY['milk', 'soybeans']),
+    ('egg', ARRAY['eggs']),
+    ('mayonnaise', ARRAY['eggs', 'mustard']),
+    ('salmon', ARRAY['fish']),
+    ('tuna', ARRAY['fish']),
+    ('cod', ARRAY['fish']),
+    ('shrimp', ARRAY['crustaceans']),
+    ('peanut butter', ARRAY['peanuts']),
+    ('almond', ARRAY['tree-nuts']),
+    ('walnut', ARRAY['tree-nuts']),
+    ('pine nut', ARRAY['tree-nuts']),
+    ('cashew', ARRAY['tree-nuts']),
+    ('tofu', ARRAY['soybeans']),
+    ('soy milk', ARRAY['soybeans']),
+    ('tamari', ARRAY['soybeans']),
+    ('almond milk', ARRAY['tree-nuts']),
+    ('celery', ARRAY['celery']),
+    ('chicken broth', ARRAY['celery']),
+    ('vegetable broth', ARRAY['celery']),
+    ('mustard', ARRAY['mustard']),
+    ('sesame oil', ARRAY['sesame']),
+    ('balsamic vinegar', ARRAY['sulphites'])
+) AS a (name, allergens)
+WHERE c.name = a.name;
+
+UPDATE ingredient_catalog SET animal_source = 'meat' WHERE category = 'meat' OR name = 'chicken broth';
+UPDATE ingredient_catalog SET animal_source = 'fish' WHERE category = 'seafood';
+UPDATE ingredient_catalog SET animal_source = 'product'
+WHERE category = 'dairy' OR name IN ('honey', 'mayonnaise', 'chocolate chip');
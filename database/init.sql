@@ -1,11 +1,55 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+DROP TABLE IF EXISTS households CASCADE;
+CREATE TABLE households (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TABLE IF EXISTS household_members;
+CREATE TABLE household_members (
+    household_id INTEGER NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'member')),
+    PRIMARY KEY (household_id, user_id)
+);
+
 DROP TABLE IF EXISTS recipes CASCADE;
 CREATE TABLE recipes (
     id SERIAL PRIMARY KEY,
     user_id INTEGER NOT NULL,
+    household_id INTEGER REFERENCES households (id) ON DELETE SET NULL,
     name TEXT NOT NULL,
     cooking_time TEXT,
+    servings INTEGER NOT NULL DEFAULT 0,
     description TEXT,
-    instructions TEXT
+    instructions TEXT,
+    allergens TEXT[] NOT NULL DEFAULT '{}',
+    diets TEXT[] NOT NULL DEFAULT '{}',
+    unclassified TEXT[] NOT NULL DEFAULT '{}',
+    classification_overridden BOOLEAN NOT NULL DEFAULT FALSE,
+    -- NULL until the classification is first derived or set, so that
+    -- recipes that have never been classified are not taken as allergen-free
+    classified_at TIMESTAMPTZ,
+    source TEXT,
+    source_format TEXT CHECK (source_format IN ('cooklang', 'markdown'))
+);
+
+DROP TABLE IF EXISTS ingredient_catalog CASCADE;
+CREATE TABLE ingredient_catalog (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    category TEXT NOT NULL,
+    allergens TEXT[] NOT NULL DEFAULT '{}',
+    animal_source TEXT NOT NULL DEFAULT 'none' CHECK (animal_source IN ('none', 'product', 'fish', 'meat'))
+);
+CREATE INDEX ingredient_catalog_name_trgm_idx ON ingredient_catalog USING GIN (name gin_trgm_ops);
+
+DROP TABLE IF EXISTS ingredient_synonyms;
+CREATE TABLE ingredient_synonyms (
+    catalog_id INTEGER NOT NULL REFERENCES ingredient_catalog (id) ON DELETE CASCADE,
+    synonym TEXT NOT NULL UNIQUE
 );
 
 DROP TABLE IF EXISTS ingredients; 
@@ -15,12 +59,435 @@ CREATE TABLE ingredients (
     user_id INTEGER NOT NULL,
     recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
     unit TEXT NOT NULL,
-    quantity REAL NOT NULL
+    quantity REAL NOT NULL,
+    catalog_id INTEGER REFERENCES ingredient_catalog (id) ON DELETE SET NULL,
+    catalog_locked BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX ingredients_name_trgm_idx ON ingredients USING GIN (lower(name) gin_trgm_ops);
+
+DROP TABLE IF EXISTS tags CASCADE;
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    household_id INTEGER REFERENCES households (id) ON DELETE CASCADE,
+    name TEXT NOT NULL
 );
+CREATE UNIQUE INDEX tags_user_name_idx ON tags (user_id, name) WHERE household_id IS NULL;
+CREATE UNIQUE INDEX tags_household_name_idx ON tags (household_id, name) WHERE household_id IS NOT NULL;
 
 DROP TABLE IF EXISTS recipe_tags;
 CREATE TABLE recipe_tags (
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (recipe_id, tag_id)
+);
+
+DROP TABLE IF EXISTS recipe_shares;
+CREATE TABLE recipe_shares (
     id SERIAL PRIMARY KEY,
     recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
-    name TEXT NOT NULL
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+    UNIQUE (recipe_id, user_id)
+);
+
+DROP TABLE IF EXISTS recipe_share_links;
+CREATE TABLE recipe_share_links (
+    id SERIAL PRIMARY KEY,
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ
+);
+
+DROP TABLE IF EXISTS collections CASCADE;
+CREATE TABLE collections (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    household_id INTEGER REFERENCES households (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    cover_image TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TABLE IF EXISTS collection_recipes;
+CREATE TABLE collection_recipes (
+    collection_id INTEGER NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, recipe_id)
+);
+
+DROP TABLE IF EXISTS meal_plans;
+CREATE TABLE meal_plans (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    household_id INTEGER REFERENCES households (id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    meal TEXT NOT NULL CHECK (meal IN ('breakfast', 'lunch', 'dinner', 'snack')),
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    servings INTEGER NOT NULL DEFAULT 1,
+    notes TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX meal_plans_date_idx ON meal_plans (date);
+
+DROP TABLE IF EXISTS pantry_items;
+CREATE TABLE pantry_items (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    household_id INTEGER REFERENCES households (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    quantity REAL NOT NULL DEFAULT 0,
+    unit TEXT NOT NULL DEFAULT '',
+    expires_on DATE
+);
+
+-- Bundled substitution rules have no user_id and are visible to everyone
+DROP TABLE IF EXISTS substitutions;
+CREATE TABLE substitutions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER,
+    household_id INTEGER REFERENCES households (id) ON DELETE CASCADE,
+    ingredient_id INTEGER NOT NULL REFERENCES ingredient_catalog (id) ON DELETE CASCADE,
+    substitute_id INTEGER NOT NULL REFERENCES ingredient_catalog (id) ON DELETE CASCADE,
+    ratio REAL NOT NULL DEFAULT 1 CHECK (ratio > 0),
+    unit TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    diets TEXT[] NOT NULL DEFAULT '{}'
+);
+CREATE INDEX substitutions_ingredient_idx ON substitutions (ingredient_id);
+
+DROP TABLE IF EXISTS prices;
+CREATE TABLE prices (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    household_id INTEGER REFERENCES households (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    price NUMERIC(10, 2) NOT NULL CHECK (price >= 0),
+    quantity REAL NOT NULL DEFAULT 1,
+    unit TEXT NOT NULL DEFAULT '',
+    store TEXT NOT NULL DEFAULT ''
+);
+
+DROP TABLE IF EXISTS recipe_preferences;
+CREATE TABLE recipe_preferences (
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    favorite BOOLEAN NOT NULL DEFAULT FALSE,
+    rating INTEGER CHECK (rating BETWEEN 1 AND 5),
+    PRIMARY KEY (recipe_id, user_id)
+);
+
+DROP TABLE IF EXISTS recipe_notes;
+CREATE TABLE recipe_notes (
+    id SERIAL PRIMARY KEY,
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX recipe_notes_recipe_idx ON recipe_notes (recipe_id, user_id);
+
+DROP TABLE IF EXISTS cook_log;
+CREATE TABLE cook_log (
+    id SERIAL PRIMARY KEY,
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    cooked_on DATE NOT NULL DEFAULT CURRENT_DATE,
+    servings INTEGER NOT NULL DEFAULT 1,
+    rating INTEGER CHECK (rating BETWEEN 1 AND 5),
+    notes TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX cook_log_user_recipe_idx ON cook_log (user_id, recipe_id, cooked_on);
+
+-- Photos of a whole recipe have no step. Thumbnails are stored under the
+-- same key prefix as the original.
+DROP TABLE IF EXISTS recipe_photos;
+CREATE TABLE recipe_photos (
+    id SERIAL PRIMARY KEY,
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    step INTEGER CHECK (step > 0),
+    key_prefix TEXT NOT NULL UNIQUE,
+    extension TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX recipe_photos_recipe_idx ON recipe_photos (recipe_id);
+
+-- Timers mentioned in a recipe's steps, such as "bake for 25 minutes"
+DROP TABLE IF EXISTS recipe_timers;
+CREATE TABLE recipe_timers (
+    id SERIAL PRIMARY KEY,
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    step INTEGER NOT NULL CHECK (step > 0),
+    name TEXT NOT NULL DEFAULT '',
+    quantity REAL NOT NULL,
+    unit TEXT NOT NULL
+);
+CREATE INDEX recipe_timers_recipe_idx ON recipe_timers (recipe_id);
+
+-- Append-only log of changes to recipes, ingredients and tags, which offline
+-- clients read to sync. Each change records the cookbook the entity was in at
+-- the time, so that a recipe moving between cookbooks is deleted from one and
+-- created in the other. Changes are recorded by the triggers below, so that
+-- every way of modifying these tables is logged.
+--
+-- Sequence numbers are handed out before transactions commit, so they can
+-- become visible out of order. Clients therefore read changes in order of
+-- the transaction that made them, and only from transactions older than
+-- every transaction still running, which can no longer gain changes.
+DROP TABLE IF EXISTS changes;
+CREATE TABLE changes (
+    seq BIGSERIAL PRIMARY KEY,
+    txid XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    entity TEXT NOT NULL CHECK (entity IN ('recipe', 'ingredient', 'tag')),
+    entity_id INTEGER NOT NULL,
+    recipe_id INTEGER,
+    operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete')),
+    user_id INTEGER NOT NULL,
+    household_id INTEGER,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
+CREATE INDEX changes_household_idx ON changes (household_id, txid, seq);
+CREATE INDEX changes_user_idx ON changes (user_id, txid, seq) WHERE household_id IS NULL;
+
+CREATE OR REPLACE FUNCTION log_recipe_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO changes (entity, entity_id, recipe_id, operation, user_id, household_id)
+        VALUES ('recipe', NEW.id, NEW.id, 'create', NEW.user_id, NEW.household_id);
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO changes (entity, entity_id, recipe_id, operation, user_id, household_id)
+        VALUES ('recipe', OLD.id, OLD.id, 'delete', OLD.user_id, OLD.household_id);
+    ELSIF OLD.user_id IS DISTINCT FROM NEW.user_id OR OLD.household_id IS DISTINCT FROM NEW.household_id THEN
+        INSERT INTO changes (entity, entity_id, recipe_id, operation, user_id, household_id)
+        VALUES ('recipe', OLD.id, OLD.id, 'delete', OLD.user_id, OLD.household_id),
+            ('recipe', NEW.id, NEW.id, 'create', NEW.user_id, NEW.household_id);
+    ELSE
+        INSERT INTO changes (entity, entity_id, recipe_id, operation, user_id, household_id)
+        VALUES ('recipe', NEW.id, NEW.id, 'update', NEW.user_id, NEW.household_id);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Ingredients are logged in their recipe's cookbook. Ingredients deleted
+-- along with their recipe are covered by the recipe's deletion.
+CREATE OR REPLACE FUNCTION log_ingredient_change() RETURNS trigger AS $$
+DECLARE
+    ingredient ingredients%ROWTYPE;
+    op TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        ingredient := OLD;
+        op := 'delete';
+    ELSE
+        ingredient := NEW;
+        op := CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END;
+    END IF;
+
+    INSERT INTO changes (entity, entity_id, recipe_id, operation, user_id, household_id)
+    SELECT 'ingredient', ingredient.id, r.id, op, r.user_id, r.household_id
+    FROM recipes r WHERE r.id = ingredient.recipe_id;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION log_tag_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO changes (entity, entity_id, operation, user_id, household_id)
+        VALUES ('tag', OLD.id, 'delete', OLD.user_id, OLD.household_id);
+    ELSE
+        INSERT INTO changes (entity, entity_id, operation, user_id, household_id)
+        VALUES ('tag', NEW.id, CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.user_id, NEW.household_id);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Tagging or untagging a recipe updates the recipe
+CREATE OR REPLACE FUNCTION log_recipe_tag_change() RETURNS trigger AS $$
+DECLARE
+    link recipe_tags%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        link := OLD;
+    ELSE
+        link := NEW;
+    END IF;
+
+    INSERT INTO changes (entity, entity_id, recipe_id, operation, user_id, household_id)
+    SELECT 'recipe', r.id, r.id, 'update', r.user_id, r.household_id
+    FROM recipes r WHERE r.id = link.recipe_id;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER recipes_log_change AFTER INSERT OR DELETE ON recipes
+    FOR EACH ROW EXECUTE FUNCTION log_recipe_change();
+CREATE TRIGGER recipes_log_update AFTER UPDATE ON recipes
+    FOR EACH ROW WHEN (OLD IS DISTINCT FROM NEW) EXECUTE FUNCTION log_recipe_change();
+CREATE TRIGGER ingredients_log_change AFTER INSERT OR DELETE ON ingredients
+    FOR EACH ROW EXECUTE FUNCTION log_ingredient_change();
+CREATE TRIGGER ingredients_log_update AFTER UPDATE ON ingredients
+    FOR EACH ROW WHEN (OLD IS DISTINCT FROM NEW) EXECUTE FUNCTION log_ingredient_change();
+CREATE TRIGGER tags_log_change AFTER INSERT OR DELETE ON tags
+    FOR EACH ROW EXECUTE FUNCTION log_tag_change();
+CREATE TRIGGER tags_log_update AFTER UPDATE ON tags
+    FOR EACH ROW WHEN (OLD IS DISTINCT FROM NEW) EXECUTE FUNCTION log_tag_change();
+CREATE TRIGGER recipe_tags_log_change AFTER INSERT OR DELETE ON recipe_tags
+    FOR EACH ROW EXECUTE FUNCTION log_recipe_tag_change();
+
+-- Endpoints notified of recipe changes. Webhooks belong to a cookbook like
+-- recipes do, except global ones, which admins register to hear about every
+-- cookbook. An empty events list subscribes to every event.
+DROP TABLE IF EXISTS webhooks CASCADE;
+CREATE TABLE webhooks (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    household_id INTEGER REFERENCES households (id) ON DELETE CASCADE,
+    global BOOLEAN NOT NULL DEFAULT FALSE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Recipe events waiting to be handed to webhooks. Rows are written by the
+-- triggers below, so they commit in the same transaction as the change, and
+-- are removed once they are turned into deliveries.
+DROP TABLE IF EXISTS webhook_outbox;
+CREATE TABLE webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL CHECK (event_type IN ('recipe.created', 'recipe.updated', 'recipe.deleted')),
+    recipe_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    household_id INTEGER,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX webhook_outbox_recipe_idx ON webhook_outbox (recipe_id);
+
+-- A delivery of an event to a webhook. Pending deliveries are sent once
+-- next_attempt_at has passed, and dead ones have run out of attempts.
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
+
+DROP TABLE IF EXISTS webhook_attempts;
+CREATE TABLE webhook_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    status_code INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL
+);
+CREATE INDEX webhook_attempts_delivery_idx ON webhook_attempts (delivery_id);
+
+-- Queues a webhook event for a recipe. Updates to a recipe whose event is
+-- still waiting in the outbox are folded into that event, so that creating
+-- a recipe with its ingredients is announced once.
+CREATE OR REPLACE FUNCTION queue_recipe_webhook(event TEXT, recipe recipes) RETURNS void AS $$
+DECLARE
+    payload JSONB;
+BEGIN
+    payload := jsonb_build_object(
+        'id', recipe.id,
+        'name', recipe.name,
+        'cookingTime', recipe.cooking_time,
+        'servings', recipe.servings,
+        'description', recipe.description,
+        'instructions', recipe.instructions,
+        'userId', recipe.user_id,
+        'householdId', recipe.household_id,
+        'ingredients', COALESCE((
+            SELECT jsonb_agg(jsonb_build_object('name', i.name, 'quantity', i.quantity, 'unit', i.unit) ORDER BY i.id)
+            FROM ingredients i WHERE i.recipe_id = recipe.id
+        ), '[]'::jsonb)
+    );
+
+    IF event = 'recipe.updated' THEN
+        UPDATE webhook_outbox SET payload = queue_recipe_webhook.payload
+        WHERE recipe_id = recipe.id
+            AND event_type IN ('recipe.created', 'recipe.updated')
+            AND household_id IS NOT DISTINCT FROM recipe.household_id;
+
+        IF FOUND THEN
+            RETURN;
+        END IF;
+    END IF;
+
+    INSERT INTO webhook_outbox (event_type, recipe_id, user_id, household_id, payload)
+    VALUES (event, recipe.id, recipe.user_id, recipe.household_id, queue_recipe_webhook.payload);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION queue_recipe_change_webhook() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM queue_recipe_webhook('recipe.created', NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM queue_recipe_webhook('recipe.deleted', OLD);
+    ELSIF OLD.user_id IS DISTINCT FROM NEW.user_id OR OLD.household_id IS DISTINCT FROM NEW.household_id THEN
+        PERFORM queue_recipe_webhook('recipe.deleted', OLD);
+        PERFORM queue_recipe_webhook('recipe.created', NEW);
+    ELSE
+        PERFORM queue_recipe_webhook('recipe.updated', NEW);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Changing a recipe's ingredients or tags updates the recipe. Changes made
+-- while deleting the recipe are covered by its deletion.
+CREATE OR REPLACE FUNCTION queue_recipe_child_webhook() RETURNS trigger AS $$
+DECLARE
+    recipe recipes%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        SELECT * INTO recipe FROM recipes WHERE id = OLD.recipe_id;
+    ELSE
+        SELECT * INTO recipe FROM recipes WHERE id = NEW.recipe_id;
+    END IF;
+
+    IF FOUND THEN
+        PERFORM queue_recipe_webhook('recipe.updated', recipe);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER recipes_queue_webhook AFTER INSERT OR DELETE ON recipes
+    FOR EACH ROW EXECUTE FUNCTION queue_recipe_change_webhook();
+CREATE TRIGGER recipes_queue_webhook_update AFTER UPDATE ON recipes
+    FOR EACH ROW WHEN (OLD IS DISTINCT FROM NEW) EXECUTE FUNCTION queue_recipe_change_webhook();
+CREATE TRIGGER ingredients_queue_webhook AFTER INSERT OR DELETE ON ingredients
+    FOR EACH ROW EXECUTE FUNCTION queue_recipe_child_webhook();
+CREATE TRIGGER ingredients_queue_webhook_update AFTER UPDATE ON ingredients
+    FOR EACH ROW WHEN (OLD IS DISTINCT FROM NEW) EXECUTE FUNCTION queue_recipe_child_webhook();
+CREATE TRIGGER recipe_tags_queue_webhook AFTER INSERT OR DELETE ON recipe_tags
+    FOR EACH ROW EXECUTE FUNCTION queue_recipe_child_webhook();
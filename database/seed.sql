@@ -61,10 +61,15 @@ INSERT INTO ingredients (name, user_id, recipe_id, quantity, unit) VALUES
 ('salt', 1, 4, 0.5, 'tsp'),
 ('pepper', 1, 4, 0.25, 'tsp');  
 
-INSERT INTO recipe_tags (recipe_id, name) VALUES 
-(2, 'vegetarian'),
-(3, 'vegetarian'),
-(4, 'low-carb');
+DELETE FROM tags;
+INSERT INTO tags (user_id, name) VALUES
+(1, 'vegetarian'),
+(1, 'low-carb');
+
+INSERT INTO recipe_tags (recipe_id, tag_id) VALUES 
+(2, 1),
+(3, 1),
+(4, 2);
 
 
 SELECT * FROM recipes;
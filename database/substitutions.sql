@@ -0,0 +1,39 @@
+-- Bundled ingredient substitution rules. Must be loaded after catalog.sql.
+-- The ratio is how much of the substitute replaces one unit of the
+-- ingredient. The unit is only set when the substitute is measured
+-- differently from the ingredient. Diets lists the diets the substitution
+-- helps a recipe fit.
+DELETE FROM substitutions WHERE user_id IS NULL;
+INSERT INTO substitutions (ingredient_id, substitute_id, ratio, unit, notes, diets)
+SELECT i.id, s.id, r.ratio, r.unit, r.notes, r.diets FROM (VALUES
+    ('buttermilk', 'milk', 1, '', 'Stir 1 tbsp lemon juice or vinegar into each cup and let it stand for 5 minutes.', ARRAY[]::TEXT[]),
+    ('buttermilk', 'soy milk', 1, '', 'Stir 1 tbsp lemon juice into each cup and let it stand for 5 minutes.', ARRAY['vegan', 'dairy-free']),
+    ('milk', 'oat milk', 1, '', '', ARRAY['vegan', 'dairy-free']),
+    ('milk', 'soy milk', 1, '', '', ARRAY['vegan', 'dairy-free']),
+    ('milk', 'almond milk', 1, '', 'Thinner than dairy milk; not for custards.', ARRAY['vegan', 'dairy-free']),
+    ('heavy cream', 'coconut milk', 1, '', 'Use full-fat canned coconut milk.', ARRAY['vegan', 'dairy-free']),
+    ('sour cream', 'yogurt', 1, '', 'Use plain full-fat yogurt.', ARRAY[]::TEXT[]),
+    ('butter', 'vegan butter', 1, '', '', ARRAY['vegan', 'dairy-free']),
+    ('butter', 'coconut oil', 0.8, '', 'Adds a mild coconut flavor.', ARRAY['vegan', 'dairy-free']),
+    ('butter', 'olive oil', 0.75, '', 'Best for cooking rather than baking.', ARRAY['vegan', 'dairy-free']),
+    ('parmesan cheese', 'nutritional yeast', 0.5, '', 'Gives a similar savory flavor but does not melt.', ARRAY['vegan', 'dairy-free']),
+    ('egg', 'flaxseed', 1, 'tbsp', 'Mix each tbsp of ground flaxseed with 3 tbsp water and rest for 5 minutes. Works for binding, not for rising.', ARRAY['vegan', 'egg-free']),
+    ('mayonnaise', 'yogurt', 1, '', 'Tangier than mayonnaise.', ARRAY['egg-free']),
+    ('honey', 'maple syrup', 1, '', '', ARRAY['vegan']),
+    ('chicken broth', 'vegetable broth', 1, '', '', ARRAY['vegan', 'vegetarian', 'pescatarian']),
+    ('chicken breast', 'tofu', 1, '', 'Use extra-firm tofu, pressed and cubed.', ARRAY['vegan', 'vegetarian', 'pescatarian']),
+    ('ground beef', 'lentil', 1, '', 'Use cooked lentils.', ARRAY['vegan', 'vegetarian', 'pescatarian']),
+    ('ground beef', 'ground turkey', 1, '', 'Leaner; add a little oil when browning.', ARRAY[]::TEXT[]),
+    ('all-purpose flour', 'gluten-free flour', 1, '', 'Use a blend that contains xanthan gum for baking.', ARRAY['gluten-free']),
+    ('whole wheat flour', 'gluten-free flour', 1, '', 'Use a blend that contains xanthan gum for baking.', ARRAY['gluten-free']),
+    ('spaghetti', 'gluten-free pasta', 1, '', '', ARRAY['gluten-free']),
+    ('pasta', 'gluten-free pasta', 1, '', '', ARRAY['gluten-free']),
+    ('tortilla', 'corn tortilla', 1, '', '', ARRAY['gluten-free']),
+    ('soy sauce', 'tamari', 1, '', 'Check the label, as some brands contain wheat.', ARRAY['gluten-free']),
+    ('peanut butter', 'almond', 1, '', 'Blend roasted almonds into a butter.', ARRAY[]::TEXT[]),
+    ('brown sugar', 'sugar', 1, '', 'Add 1 tbsp maple syrup per cup for a similar flavor.', ARRAY[]::TEXT[]),
+    ('sugar', 'honey', 0.75, '', 'Reduce the other liquids by 3 tbsp per cup of honey.', ARRAY[]::TEXT[]),
+    ('lemon', 'lime', 1, '', '', ARRAY[]::TEXT[])
+) AS r (ingredient, substitute, ratio, unit, notes, diets)
+JOIN ingredient_catalog i ON i.name = r.ingredient
+JOIN ingredient_catalog s ON s.name = r.substitute;
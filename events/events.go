@@ -0,0 +1,189 @@
+// Package events is an in-process event bus. Publishers announce changes,
+// such as a recipe being updated, and subscribers receive the events of the
+// cookbook they are scoped to. Recent events are kept so that subscribers
+// that reconnect can catch up on the events they missed.
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	RecipeCreated = "recipe.created"
+	RecipeUpdated = "recipe.updated"
+	RecipeDeleted = "recipe.deleted"
+)
+
+// Most recent events kept for subscribers catching up.
+const historySize = 1000
+
+// Most events queued for a subscriber. Subscribers that fall further behind
+// are dropped, and catch up when they reconnect.
+const queueSize = 64
+
+// Scope is a cookbook: a household's, or a user's personal cookbook when
+// HouseholdID is empty. Only one of UserID and HouseholdID is set.
+type Scope struct {
+	UserID      string
+	HouseholdID string
+}
+
+// Event is a change to an entity in a cookbook. IDs increase with every
+// event published by this process.
+type Event struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	RecipeID int64     `json:"recipeId"`
+	Time     time.Time `json:"time"`
+
+	scope Scope
+	seq   uint64
+}
+
+// Bus delivers published events to subscribers.
+type Bus struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	history     []Event
+	subscribers map[*Subscription]bool
+	closed      bool
+}
+
+// Subscription receives the events of a scope on Events, which is closed
+// when the subscriber falls behind or the bus is closed.
+type Subscription struct {
+	Events <-chan Event
+
+	events chan Event
+	scope  Scope
+	bus    *Bus
+}
+
+// The bus the models publish to.
+var Default = NewBus()
+
+// Creates an empty bus. Event IDs start with the time the bus was created,
+// so that IDs from before a restart are not mistaken for new ones.
+func NewBus() *Bus {
+	return &Bus{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+// Publishes an event to the subscribers of a scope. Publishing to a closed
+// bus does nothing.
+func (b *Bus) Publish(scope Scope, eventType string, recipeId int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	event := Event{
+		ID:       fmt.Sprintf("%s-%d", b.epoch, b.seq),
+		Type:     eventType,
+		RecipeID: recipeId,
+		Time:     time.Now().UTC(),
+		scope:    scope,
+		seq:      b.seq,
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+
+	for subscription := range b.subscribers {
+		if subscription.scope != scope {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			b.drop(subscription)
+		}
+	}
+}
+
+// Subscribes to the events of a scope. When lastEventID is set, the events
+// after it that are still kept are returned to be sent first. Reports false
+// when those events cannot be recovered, because lastEventID is too old or
+// from before a restart, so the subscriber should reload everything.
+func (b *Bus) Subscribe(scope Scope, lastEventID string) (*Subscription, []Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan Event, queueSize)
+	subscription := &Subscription{Events: events, events: events, scope: scope, bus: b}
+
+	if b.closed {
+		close(events)
+		return subscription, []Event{}, true
+	}
+
+	b.subscribers[subscription] = true
+
+	missed := []Event{}
+	if lastEventID == "" {
+		return subscription, missed, true
+	}
+
+	epoch, seqText, _ := strings.Cut(lastEventID, "-")
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil || epoch != b.epoch || seq > b.seq {
+		return subscription, missed, false
+	}
+
+	// Events between lastEventID and the oldest kept event are lost
+	if seq < b.seq && (len(b.history) == 0 || b.history[0].seq > seq+1) {
+		return subscription, missed, false
+	}
+
+	for _, event := range b.history {
+		if event.seq > seq && event.scope == scope {
+			missed = append(missed, event)
+		}
+	}
+
+	return subscription, missed, true
+}
+
+// Stops delivering events to a subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if s.bus.subscribers[s] {
+		s.bus.drop(s)
+	}
+}
+
+// Closes every subscription and stops accepting new events, such as when
+// the server shuts down.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for subscription := range b.subscribers {
+		b.drop(subscription)
+	}
+}
+
+// Helper Functions
+
+// Removes a subscription and closes its channel. The bus must be locked.
+func (b *Bus) drop(subscription *Subscription) {
+	delete(b.subscribers, subscription)
+	close(subscription.events)
+}
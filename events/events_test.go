@@ -0,0 +1,259 @@
+package events
+
+import (
+	"sync"
+	"testing"
+)
+
+var (
+	alice   = Scope{UserID: "alice"}
+	bob     = Scope{UserID: "bob"}
+	kitchen = Scope{HouseholdID: "kitchen"}
+)
+
+// Reads the events queued for a subscription without waiting.
+func queued(subscription *Subscription) []Event {
+	events := []Event{}
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// Reports whether a subscription's channel has been closed, after reading
+// any queued events.
+func closed(subscription *Subscription) bool {
+	for {
+		select {
+		case _, ok := <-subscription.Events:
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func recipeIDs(events []Event) []int64 {
+	ids := []int64{}
+	for _, event := range events {
+		ids = append(ids, event.RecipeID)
+	}
+
+	return ids
+}
+
+func equalIDs(a []int64, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestSubscribeReceivesOwnScope(t *testing.T) {
+	bus := NewBus()
+	subscription, missed, ok := bus.Subscribe(alice, "")
+	if !ok || len(missed) != 0 {
+		t.Fatalf("got %v %v, want no missed events", missed, ok)
+	}
+
+	bus.Publish(alice, RecipeCreated, 1)
+	bus.Publish(bob, RecipeCreated, 2)
+	bus.Publish(kitchen, RecipeCreated, 3)
+	bus.Publish(alice, RecipeUpdated, 1)
+
+	events := queued(subscription)
+	if !equalIDs(recipeIDs(events), []int64{1, 1}) {
+		t.Fatalf("got recipes %v, want only alice's", recipeIDs(events))
+	}
+	if events[0].Type != RecipeCreated || events[1].Type != RecipeUpdated {
+		t.Errorf("got types %q and %q", events[0].Type, events[1].Type)
+	}
+	if events[0].ID == events[1].ID {
+		t.Errorf("events share the ID %q", events[0].ID)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	subscription, _, _ := bus.Subscribe(alice, "")
+	other, _, _ := bus.Subscribe(alice, "")
+
+	subscription.Close()
+	if !closed(subscription) {
+		t.Fatal("channel not closed after Close")
+	}
+
+	// Closing again is harmless, and publishing skips the closed subscriber
+	subscription.Close()
+	bus.Publish(alice, RecipeCreated, 1)
+
+	if got := recipeIDs(queued(other)); !equalIDs(got, []int64{1}) {
+		t.Errorf("got recipes %v for the remaining subscriber, want [1]", got)
+	}
+}
+
+func TestSubscribeCatchesUp(t *testing.T) {
+	bus := NewBus()
+	first, _, _ := bus.Subscribe(alice, "")
+
+	bus.Publish(alice, RecipeCreated, 1)
+	lastEventID := queued(first)[0].ID
+	first.Close()
+
+	bus.Publish(alice, RecipeUpdated, 2)
+	bus.Publish(bob, RecipeUpdated, 3)
+	bus.Publish(alice, RecipeDeleted, 4)
+
+	subscription, missed, ok := bus.Subscribe(alice, lastEventID)
+	if !ok || !equalIDs(recipeIDs(missed), []int64{2, 4}) {
+		t.Errorf("got %v %v, want recipes [2 4]", recipeIDs(missed), ok)
+	}
+
+	// Missed events are returned, not queued
+	if events := queued(subscription); len(events) != 0 {
+		t.Errorf("got %d queued events, want none", len(events))
+	}
+}
+
+func TestSubscribeWithLatestID(t *testing.T) {
+	bus := NewBus()
+	first, _, _ := bus.Subscribe(alice, "")
+	bus.Publish(alice, RecipeCreated, 1)
+	lastEventID := queued(first)[0].ID
+
+	_, missed, ok := bus.Subscribe(alice, lastEventID)
+	if !ok || len(missed) != 0 {
+		t.Errorf("got %v %v, want nothing missed", missed, ok)
+	}
+}
+
+func TestSubscribeCannotCatchUp(t *testing.T) {
+	bus := NewBus()
+	first, _, _ := bus.Subscribe(alice, "")
+	bus.Publish(alice, RecipeCreated, 1)
+	oldEventID := queued(first)[0].ID
+	first.Close()
+
+	// Push the event after it out of the history
+	for i := range historySize + 1 {
+		bus.Publish(bob, RecipeUpdated, int64(i))
+	}
+
+	other := NewBus()
+	otherSubscription, _, _ := other.Subscribe(alice, "")
+	other.Publish(alice, RecipeCreated, 1)
+	restartedEventID := queued(otherSubscription)[0].ID
+
+	tests := []struct {
+		name        string
+		lastEventID string
+	}{
+		{"too old", oldEventID},
+		{"from before a restart", restartedEventID},
+		{"malformed", "yesterday"},
+		{"from the future", bus.epoch + "-999999"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subscription, missed, ok := bus.Subscribe(alice, test.lastEventID)
+			if ok || len(missed) != 0 {
+				t.Errorf("got %v %v, want a reload", missed, ok)
+			}
+
+			// The subscription still receives new events
+			bus.Publish(alice, RecipeUpdated, 7)
+			if got := recipeIDs(queued(subscription)); !equalIDs(got, []int64{7}) {
+				t.Errorf("got recipes %v, want [7]", got)
+			}
+			subscription.Close()
+		})
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	bus := NewBus()
+	slow, _, _ := bus.Subscribe(alice, "")
+
+	for i := range queueSize {
+		bus.Publish(alice, RecipeUpdated, int64(i))
+	}
+	if closed(slow) {
+		t.Fatal("subscriber dropped before its queue was full")
+	}
+
+	fast, _, _ := bus.Subscribe(alice, "")
+	for i := range queueSize + 1 {
+		bus.Publish(alice, RecipeUpdated, int64(i))
+		queued(fast)
+	}
+
+	if !closed(slow) {
+		t.Error("slow subscriber was not dropped")
+	}
+
+	// Subscribers that keep up are not affected
+	if closed(fast) {
+		t.Error("fast subscriber was dropped")
+	}
+}
+
+func TestBusClose(t *testing.T) {
+	bus := NewBus()
+	subscription, _, _ := bus.Subscribe(alice, "")
+
+	bus.Close()
+	if !closed(subscription) {
+		t.Error("subscription not closed with the bus")
+	}
+
+	// Closing a subscription of a closed bus is harmless
+	subscription.Close()
+
+	bus.Publish(alice, RecipeCreated, 1)
+
+	late, missed, ok := bus.Subscribe(alice, "")
+	if !ok || len(missed) != 0 || !closed(late) {
+		t.Errorf("got %v %v, want a closed subscription", missed, ok)
+	}
+}
+
+func TestConcurrentPublishAndSubscribe(t *testing.T) {
+	bus := NewBus()
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				bus.Publish(alice, RecipeUpdated, int64(i*100+j))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			subscription, _, _ := bus.Subscribe(alice, "")
+			for range subscription.Events {
+			}
+		}()
+	}
+
+	// Dropped or closed subscribers end their loops
+	bus.Close()
+	wg.Wait()
+}
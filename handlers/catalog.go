@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type CatalogResponse struct {
+	Message string                `json:"message"`
+	Data    []models.CatalogEntry `json:"data"`
+}
+
+type CatalogSuggestionResponse struct {
+	Message string                     `json:"message"`
+	Data    []models.CatalogSuggestion `json:"data"`
+}
+
+type IngredientCatalogRequest struct {
+	CatalogID *int64 `json:"catalogId"`
+}
+
+// Handles browsing the ingredient catalog, optionally filtered by the q and
+// category query parameters.
+func GetCatalogEntries(w http.ResponseWriter, r *http.Request) {
+	search, category := r.URL.Query().Get("q"), r.URL.Query().Get("category")
+
+	entries, err := models.ListCatalogEntries(r.Context(), search, category)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseData := CatalogResponse{
+		Data: entries,
+	}
+
+	// Encode the entries in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles getting a single catalog entry.
+func GetCatalogEntry(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry, err := models.FindCatalogEntry(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := CatalogResponse{
+		Data: []models.CatalogEntry{entry},
+	}
+
+	// Encode the entry in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles suggesting the catalog entry for the ingredient name given in the
+// name query parameter.
+func GetCatalogSuggestion(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "name query parameter is required")
+		return
+	}
+
+	suggestion, err := models.SuggestCatalogEntry(r.Context(), name)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseData := CatalogSuggestionResponse{
+		Data: []models.CatalogSuggestion{suggestion},
+	}
+
+	// Encode the suggestion in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles manually linking a recipe ingredient to a catalog entry.
+func PatchIngredientCatalog(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request IngredientCatalogRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ingredient, err := models.SetIngredientCatalogEntry(r.Context(), id, request.CatalogID)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := IngredientsResponse{
+		Message: "Ingredient mapping successfully updated!",
+		Data:    []models.Ingredient{ingredient},
+	}
+
+	// Encode the ingredient in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
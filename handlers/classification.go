@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type ClassificationRequest struct {
+	Allergens []string `json:"allergens"`
+	Diets     []string `json:"diets"`
+}
+
+// Handles overriding the allergens and diets derived for a recipe.
+func PutRecipeClassification(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request ClassificationRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	classification := models.Classification{
+		Allergens: request.Allergens,
+		Diets:     request.Diets,
+	}
+
+	err = models.OverrideClassification(r.Context(), id, classification)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, classificationErrorStatus(err), err.Error())
+		return
+	}
+
+	sendRecipe(w, r, id, "Recipe classification successfully updated!")
+}
+
+// Handles removing an override so the recipe's allergens and diets are
+// derived from its ingredients again.
+func DeleteRecipeClassification(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.ResetClassification(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendRecipe(w, r, id, "Recipe classification successfully reset!")
+}
+
+// Helper Functions
+
+// Maps an error returned while classifying or filtering recipes onto an HTTP
+// status code.
+func classificationErrorStatus(err error) int {
+	if errors.Is(err, models.ErrInvalidAllergen) || errors.Is(err, models.ErrInvalidDiet) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
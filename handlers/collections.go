@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type CollectionResponse struct {
+	Message string              `json:"message"`
+	Data    []models.Collection `json:"data"`
+}
+
+type ShoppingListResponse struct {
+	Message   string                    `json:"message"`
+	Data      []models.ShoppingListItem `json:"data"`
+	TotalCost float64                   `json:"totalCost"`
+	Unpriced  []string                  `json:"unpriced"`
+}
+
+type CollectionRecipeRequest struct {
+	RecipeID int64 `json:"recipeId"`
+}
+
+type ReorderCollectionRequest struct {
+	RecipeIDs []int64 `json:"recipeIds"`
+}
+
+// Handles getting the collections in the current cookbook.
+func GetCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := models.ListCollections(r.Context())
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := CollectionResponse{
+		Data: collections,
+	}
+
+	// Encode the collections in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles getting a single collection.
+func GetCollection(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	collection, err := models.FindCollection(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendCollection(w, http.StatusOK, "", collection)
+}
+
+// Handles creating a collection.
+func PostCollection(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var collection models.Collection
+	err := json.NewDecoder(r.Body).Decode(&collection)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := models.CreateCollection(r.Context(), collection)
+	if errors.Is(err, models.ErrForbidden) {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	} else if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	collection, err = models.FindCollection(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendCollection(w, http.StatusCreated, "Collection successfully created!", collection)
+}
+
+// Handles updating a collection's details.
+func PatchCollection(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var collection models.Collection
+	err = json.NewDecoder(r.Body).Decode(&collection)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.UpdateCollection(r.Context(), id, collection)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	collection, err = models.FindCollection(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendCollection(w, http.StatusOK, "Collection successfully updated!", collection)
+}
+
+// Handles deleting a collection.
+func DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeleteCollection(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles adding a recipe to the end of a collection.
+func PostCollectionRecipe(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request CollectionRecipeRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.AddCollectionRecipe(r.Context(), id, request.RecipeID)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	collection, err := models.FindCollection(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendCollection(w, http.StatusCreated, "Recipe successfully added to collection!", collection)
+}
+
+// Handles reordering the recipes in a collection.
+func PatchCollectionRecipes(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request ReorderCollectionRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.ReorderCollection(r.Context(), id, request.RecipeIDs)
+	if errors.Is(err, models.ErrInvalidOrder) {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	collection, err := models.FindCollection(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendCollection(w, http.StatusOK, "Collection successfully reordered!", collection)
+}
+
+// Handles removing a recipe from a collection.
+func DeleteCollectionRecipe(w http.ResponseWriter, r *http.Request) {
+	// Extract ids from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	recipeId, err := strconv.ParseInt(chi.URLParam(r, "recipeId"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.RemoveCollectionRecipe(r.Context(), id, recipeId)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles exporting every recipe in a collection, in order, with their
+// ingredients and tags. Takes the same format, scale, servings and units
+// query parameters as a single recipe's export.
+func GetCollectionExport(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	options, err := parsePrintOptions(r)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	collection, err := models.FindCollection(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	recipes, err := models.ExportCollection(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendRecipesDocument(w, collection.Name, recipes, options)
+}
+
+// Handles generating a shopping list from every recipe in a collection. The
+// format query parameter selects JSON, HTML or PDF, and units converts the
+// quantities.
+func GetCollectionShoppingList(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	options, err := parsePrintOptions(r)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, err := models.CollectionShoppingList(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	items = models.ConvertShoppingList(items, options.System)
+	if sendShoppingListDocument(w, "Shopping list", items, options) {
+		return
+	}
+
+	totalCost, unpriced := models.ShoppingListCost(items)
+
+	responseData := ShoppingListResponse{
+		Data:      items,
+		TotalCost: totalCost,
+		Unpriced:  unpriced,
+	}
+
+	// Encode the shopping list in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Helper Functions
+
+// Encodes a single collection as JSON and sends it with the given status.
+func sendCollection(w http.ResponseWriter, statusCode int, message string, collection models.Collection) {
+	responseData := CollectionResponse{
+		Message: message,
+		Data:    []models.Collection{collection},
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
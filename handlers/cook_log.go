@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Number of days after which a recipe counts as not made in a while, unless
+// the request sets days.
+const defaultForgottenDays = 30
+
+type CookLogResponse struct {
+	Message string                `json:"message"`
+	Data    []models.CookLogEntry `json:"data"`
+}
+
+// Handles getting the current user's cook log, optionally between the from
+// and to query parameters.
+func GetCookLog(w http.ResponseWriter, r *http.Request) {
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+
+	entries, err := models.ListCookLog(r.Context(), from, to)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, cookLogErrorStatus(err), err.Error())
+		return
+	}
+
+	sendCookLog(w, http.StatusOK, "", entries)
+}
+
+// Handles getting the current user's cook log for a recipe.
+func GetRecipeCookLog(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := models.ListRecipeCookLog(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendCookLog(w, http.StatusOK, "", entries)
+}
+
+// Handles recording that the current user made a recipe.
+func PostCookLogEntry(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var entry models.CookLogEntry
+	err = json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entryId, err := models.CreateCookLogEntry(r.Context(), id, entry)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, cookLogErrorStatus(err), err.Error())
+		return
+	}
+
+	entry, err = models.FindCookLogEntry(r.Context(), entryId)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendCookLog(w, http.StatusCreated, "Cook log entry successfully created!", []models.CookLogEntry{entry})
+}
+
+// Handles updating an entry of the current user's cook log.
+func PatchCookLogEntry(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var entry models.CookLogEntry
+	err = json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.UpdateCookLogEntry(r.Context(), id, entry)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, cookLogErrorStatus(err), err.Error())
+		return
+	}
+
+	entry, err = models.FindCookLogEntry(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendCookLog(w, http.StatusOK, "Cook log entry successfully updated!", []models.CookLogEntry{entry})
+}
+
+// Handles removing an entry from the current user's cook log.
+func DeleteCookLogEntry(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeleteCookLogEntry(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles suggesting recipes the current user has not made in a while. The
+// days query parameter sets how long a while is.
+func GetForgottenRecipes(w http.ResponseWriter, r *http.Request) {
+	days := defaultForgottenDays
+	if param := r.URL.Query().Get("days"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 0 {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "days must be a non-negative number")
+			return
+		}
+
+		days = parsed
+	}
+
+	recipes, err := models.ListForgottenRecipes(r.Context(), days)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := RecipeResponse{
+		Data: recipes,
+	}
+
+	// Encode the recipes in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Helper Functions
+
+// Encodes cook log entries as JSON and sends them with the given status.
+func sendCookLog(w http.ResponseWriter, statusCode int, message string, entries []models.CookLogEntry) {
+	responseData := CookLogResponse{
+		Message: message,
+		Data:    entries,
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Maps an error returned by a cook log model function onto an HTTP status
+// code.
+func cookLogErrorStatus(err error) int {
+	if errors.Is(err, models.ErrInvalidDate) || errors.Is(err, models.ErrInvalidDateRange) ||
+		errors.Is(err, models.ErrInvalidRating) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type DuplicateClusterResponse struct {
+	Message string                    `json:"message"`
+	Data    []models.DuplicateCluster `json:"data"`
+}
+
+type MergeRecipeRequest struct {
+	SourceID int64 `json:"sourceId"`
+}
+
+// Handles getting the clusters of likely duplicate recipes in the current
+// cookbook.
+func GetDuplicateClusters(w http.ResponseWriter, r *http.Request) {
+	clusters, err := models.ListDuplicateClusters(r.Context())
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := DuplicateClusterResponse{
+		Data: clusters,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles merging the recipe given by sourceId into the recipe in the URL.
+// The source is deleted and the merged recipe is sent back.
+func PostMergeRecipe(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request MergeRecipeRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.MergeRecipes(r.Context(), id, request.SourceID)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, mergeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendRecipe(w, r, id, "Recipes successfully merged!")
+}
+
+// Helper Functions
+
+// Maps an error returned by MergeRecipes onto an HTTP status code.
+func mergeErrorStatus(err error) int {
+	if errors.Is(err, models.ErrMergeSameRecipe) || errors.Is(err, models.ErrDifferentCookbooks) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
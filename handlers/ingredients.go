@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/mjande/recipes-microservice/models"
 	"github.com/mjande/recipes-microservice/utils"
@@ -19,13 +20,24 @@ type IngredientsResponse struct {
 	Data    []models.Ingredient `json:"data"`
 }
 
+type IngredientSuggestionsResponse struct {
+	Message string                        `json:"message"`
+	Data    []models.IngredientSuggestion `json:"data"`
+}
+
+// Default and maximum number of autocomplete suggestions returned.
+const (
+	defaultSuggestionLimit = 10
+	maxSuggestionLimit     = 50
+)
+
 // Handles getting a unique list of ingredients used in other recipes.
 func GetIngredients(w http.ResponseWriter, r *http.Request) {
 	// Call database function to query ingredients
 	ingredients, err := models.ListIngredients(r.Context())
 	if err != nil {
 		log.Println(err)
-		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
 		return
 	}
 
@@ -56,7 +68,7 @@ func GetIngredientsByMultipleRecipes(w http.ResponseWriter, r *http.Request) {
 	ingredients, err := models.ListIngredientsByMultipleRecipes(r.Context(), recipeIds)
 	if err != nil {
 		log.Println(err)
-		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
 		return
 	}
 
@@ -71,3 +83,37 @@ func GetIngredientsByMultipleRecipes(w http.ResponseWriter, r *http.Request) {
 		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
 	}
 }
+
+// Handles suggesting ingredient names matching the q query parameter, for
+// autocompletion as the user types.
+func GetIngredientSuggestions(w http.ResponseWriter, r *http.Request) {
+	limit := defaultSuggestionLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+
+		limit = min(parsed, maxSuggestionLimit)
+	}
+
+	suggestions, err := models.SuggestIngredients(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := IngredientSuggestionsResponse{
+		Data: suggestions,
+	}
+
+	// Encode the suggestions in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type MealPlanResponse struct {
+	Message string            `json:"message"`
+	Data    []models.MealPlan `json:"data"`
+}
+
+type GeneratedMealPlanResponse struct {
+	Message      string                    `json:"message"`
+	Data         []models.MealPlan         `json:"data"`
+	ShoppingList []models.ShoppingListItem `json:"shoppingList"`
+	TotalCost    float64                   `json:"totalCost"`
+	Unpriced     []string                  `json:"unpriced"`
+}
+
+type CopyMealPlanWeekRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Handles getting the meal plan entries between the from and to query
+// parameters.
+func GetMealPlans(w http.ResponseWriter, r *http.Request) {
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+
+	mealPlans, err := models.ListMealPlans(r.Context(), from, to)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, mealPlanErrorStatus(err), err.Error())
+		return
+	}
+
+	sendMealPlans(w, http.StatusOK, "", mealPlans)
+}
+
+// Handles getting a single meal plan entry.
+func GetMealPlan(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mealPlan, err := models.FindMealPlan(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendMealPlans(w, http.StatusOK, "", []models.MealPlan{mealPlan})
+}
+
+// Handles planning a recipe for a meal.
+func PostMealPlan(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var mealPlan models.MealPlan
+	err := json.NewDecoder(r.Body).Decode(&mealPlan)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := models.CreateMealPlan(r.Context(), mealPlan)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, mealPlanErrorStatus(err), err.Error())
+		return
+	}
+
+	mealPlan, err = models.FindMealPlan(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendMealPlans(w, http.StatusCreated, "Meal plan successfully created!", []models.MealPlan{mealPlan})
+}
+
+// Handles updating a meal plan entry.
+func PatchMealPlan(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var mealPlan models.MealPlan
+	err = json.NewDecoder(r.Body).Decode(&mealPlan)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.UpdateMealPlan(r.Context(), id, mealPlan)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, mealPlanErrorStatus(err), err.Error())
+		return
+	}
+
+	mealPlan, err = models.FindMealPlan(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendMealPlans(w, http.StatusOK, "Meal plan successfully updated!", []models.MealPlan{mealPlan})
+}
+
+// Handles deleting a meal plan entry.
+func DeleteMealPlan(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeleteMealPlan(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles copying a week of meal plan entries to another week. The target
+// week defaults to the week after the source week.
+func PostCopyMealPlanWeek(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var request CopyMealPlanWeekRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if request.To == "" {
+		from, err := time.Parse(models.DateLayout, request.From)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, models.ErrInvalidDate.Error())
+			return
+		}
+
+		request.To = from.AddDate(0, 0, 7).Format(models.DateLayout)
+	}
+
+	mealPlans, err := models.CopyMealPlanWeek(r.Context(), request.From, request.To)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, mealPlanErrorStatus(err), err.Error())
+		return
+	}
+
+	sendMealPlans(w, http.StatusCreated, "Meal plan week successfully copied!", mealPlans)
+}
+
+// Handles generating a shopping list for the recipes planned between the
+// from and to query parameters. The format query parameter selects JSON,
+// HTML or PDF, and units converts the quantities.
+func GetMealPlanShoppingList(w http.ResponseWriter, r *http.Request) {
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+
+	options, err := parsePrintOptions(r)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, err := models.MealPlanShoppingList(r.Context(), from, to)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, mealPlanErrorStatus(err), err.Error())
+		return
+	}
+
+	items = models.ConvertShoppingList(items, options.System)
+	if sendShoppingListDocument(w, "Shopping list "+from+" to "+to, items, options) {
+		return
+	}
+
+	totalCost, unpriced := models.ShoppingListCost(items)
+
+	responseData := ShoppingListResponse{
+		Data:      items,
+		TotalCost: totalCost,
+		Unpriced:  unpriced,
+	}
+
+	// Encode the shopping list in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles proposing a meal plan for a date range from the current cookbook's
+// recipes. The plan is a draft with no ids; entries are saved by posting
+// them individually.
+func PostGenerateMealPlan(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var constraints models.MealPlanConstraints
+	err := json.NewDecoder(r.Body).Decode(&constraints)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	generated, err := models.GenerateMealPlan(r.Context(), constraints)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, mealPlanErrorStatus(err), err.Error())
+		return
+	}
+
+	totalCost, unpriced := models.ShoppingListCost(generated.ShoppingList)
+
+	responseData := GeneratedMealPlanResponse{
+		Message:      "Meal plan successfully generated!",
+		Data:         generated.Plan,
+		ShoppingList: generated.ShoppingList,
+		TotalCost:    totalCost,
+		Unpriced:     unpriced,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Helper Functions
+
+// Encodes meal plan entries as JSON and sends them with the given status.
+func sendMealPlans(w http.ResponseWriter, statusCode int, message string, mealPlans []models.MealPlan) {
+	responseData := MealPlanResponse{
+		Message: message,
+		Data:    mealPlans,
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Maps an error returned by a meal plan model function onto an HTTP status
+// code.
+func mealPlanErrorStatus(err error) int {
+	if errors.Is(err, models.ErrInvalidDate) || errors.Is(err, models.ErrInvalidDateRange) ||
+		errors.Is(err, models.ErrInvalidMeal) || errors.Is(err, models.ErrRangeTooLong) ||
+		errors.Is(err, models.ErrInvalidAllergen) || errors.Is(err, models.ErrInvalidDiet) {
+		return http.StatusBadRequest
+	} else if errors.Is(err, models.ErrNoFeasiblePlan) {
+		return http.StatusUnprocessableEntity
+	}
+
+	return recipeErrorStatus(err)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Number of days within which a pantry item counts as expiring soon, unless
+// the request sets expiringWithin.
+const defaultExpiringWithin = 3
+
+type PantryResponse struct {
+	Message string              `json:"message"`
+	Data    []models.PantryItem `json:"data"`
+}
+
+type PantryMatchResponse struct {
+	Message string               `json:"message"`
+	Data    []models.PantryMatch `json:"data"`
+}
+
+// Handles getting every item in the pantry.
+func GetPantryItems(w http.ResponseWriter, r *http.Request) {
+	items, err := models.ListPantryItems(r.Context())
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendPantryItems(w, http.StatusOK, "", items)
+}
+
+// Handles adding an item to the pantry.
+func PostPantryItem(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var item models.PantryItem
+	err := json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := models.CreatePantryItem(r.Context(), item)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, pantryErrorStatus(err), err.Error())
+		return
+	}
+
+	item, err = models.FindPantryItem(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendPantryItems(w, http.StatusCreated, "Pantry item successfully created!", []models.PantryItem{item})
+}
+
+// Handles updating a pantry item.
+func PatchPantryItem(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var item models.PantryItem
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.UpdatePantryItem(r.Context(), id, item)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, pantryErrorStatus(err), err.Error())
+		return
+	}
+
+	item, err = models.FindPantryItem(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendPantryItems(w, http.StatusOK, "Pantry item successfully updated!", []models.PantryItem{item})
+}
+
+// Handles removing an item from the pantry.
+func DeletePantryItem(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeletePantryItem(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles ranking recipes by how much of each the pantry covers.
+func GetPantryMatches(w http.ResponseWriter, r *http.Request) {
+	expiringWithin := defaultExpiringWithin
+	if param := r.URL.Query().Get("expiringWithin"); param != "" {
+		days, err := strconv.Atoi(param)
+		if err != nil || days < 0 {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "expiringWithin must be a non-negative number of days")
+			return
+		}
+
+		expiringWithin = days
+	}
+
+	matches, err := models.ListPantryMatches(r.Context(), expiringWithin)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := PantryMatchResponse{
+		Data: matches,
+	}
+
+	// Encode the matches in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Helper Functions
+
+// Encodes pantry items as JSON and sends them with the given status.
+func sendPantryItems(w http.ResponseWriter, statusCode int, message string, items []models.PantryItem) {
+	responseData := PantryResponse{
+		Message: message,
+		Data:    items,
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Maps an error returned by a pantry model function onto an HTTP status code.
+func pantryErrorStatus(err error) int {
+	if errors.Is(err, models.ErrEmptyName) || errors.Is(err, models.ErrInvalidDate) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/imaging"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Largest size of a single uploaded photo, and of a whole upload request.
+const (
+	maxPhotoSize  = 10 << 20
+	maxUploadSize = 4 * maxPhotoSize
+)
+
+var errPhotoTooLarge = errors.New("photo must be at most 10 MB")
+var errNoPhotos = errors.New("no photos uploaded in the photo field")
+
+type PhotoResponse struct {
+	Message string         `json:"message"`
+	Data    []models.Photo `json:"data"`
+}
+
+// Handles getting the photos of a recipe.
+func GetRecipePhotos(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	photos, err := models.ListRecipePhotos(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendPhotos(w, http.StatusOK, "", photos)
+}
+
+// Handles uploading photos of a recipe as multipart form data. Each file in
+// the photo field is stored, or none are if any of them cannot be. The
+// optional step field attaches the photos to one of the recipe's steps,
+// numbered from 1.
+func PostRecipePhotos(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err = r.ParseMultipartForm(maxPhotoSize)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, photoErrorStatus(err), err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var step *int
+	if value := r.FormValue("step"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			log.Println(err)
+			utils.SendErrorResponse(w, http.StatusBadRequest, models.ErrInvalidStep.Error())
+			return
+		}
+
+		step = &parsed
+	}
+
+	files := r.MultipartForm.File["photo"]
+	if len(files) == 0 {
+		utils.SendErrorResponse(w, http.StatusBadRequest, errNoPhotos.Error())
+		return
+	}
+
+	// Read every file before storing any, so that a bad file does not leave
+	// the ones before it stored
+	data := [][]byte{}
+	for _, header := range files {
+		if header.Size > maxPhotoSize {
+			utils.SendErrorResponse(w, http.StatusRequestEntityTooLarge, errPhotoTooLarge.Error())
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			log.Println(err)
+			utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		contents, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			log.Println(err)
+			utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		data = append(data, contents)
+	}
+
+	photos, err := models.CreatePhotos(r.Context(), id, step, data)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, photoErrorStatus(err), err.Error())
+		return
+	}
+
+	sendPhotos(w, http.StatusCreated, "Photos successfully uploaded!", photos)
+}
+
+// Handles removing a photo of a recipe.
+func DeleteRecipePhoto(w http.ResponseWriter, r *http.Request) {
+	// Extract ids from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	photoId, err := strconv.ParseInt(chi.URLParam(r, "photoId"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeletePhoto(r.Context(), id, photoId)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Helper Functions
+
+// Encodes photos as JSON and sends them with the given status.
+func sendPhotos(w http.ResponseWriter, statusCode int, message string, photos []models.Photo) {
+	responseData := PhotoResponse{
+		Message: message,
+		Data:    photos,
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Maps an error returned while uploading photos onto an HTTP status code.
+func photoErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	if errors.Is(err, imaging.ErrUnsupportedFormat) {
+		return http.StatusUnsupportedMediaType
+	}
+
+	if errors.Is(err, imaging.ErrTooManyPixels) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	if errors.Is(err, models.ErrInvalidStep) || errors.Is(err, http.ErrNotMultipart) ||
+		errors.Is(err, http.ErrMissingBoundary) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type RecipeNoteResponse struct {
+	Message string              `json:"message"`
+	Data    []models.RecipeNote `json:"data"`
+}
+
+type RatingRequest struct {
+	Rating *int `json:"rating"`
+}
+
+type RecipeNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// Handles marking a recipe as a favorite.
+func PutFavorite(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.SetFavorite(r.Context(), id, true)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendRecipe(w, r, id, "Recipe added to favorites!")
+}
+
+// Handles removing a recipe from favorites.
+func DeleteFavorite(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.SetFavorite(r.Context(), id, false)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles rating a recipe from 1 to 5. A null rating removes it.
+func PutRating(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request RatingRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.SetRating(r.Context(), id, request.Rating)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, preferenceErrorStatus(err), err.Error())
+		return
+	}
+
+	sendRecipe(w, r, id, "Recipe rating successfully updated!")
+}
+
+// Handles removing the rating of a recipe.
+func DeleteRating(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.SetRating(r.Context(), id, nil)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles getting the current user's notes on a recipe.
+func GetRecipeNotes(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notes, err := models.ListRecipeNotes(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendRecipeNotes(w, http.StatusOK, "", notes)
+}
+
+// Handles adding a note to a recipe.
+func PostRecipeNote(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request RecipeNoteRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	noteId, err := models.CreateRecipeNote(r.Context(), id, request.Body)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, preferenceErrorStatus(err), err.Error())
+		return
+	}
+
+	note, err := models.FindRecipeNote(r.Context(), id, noteId)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendRecipeNotes(w, http.StatusCreated, "Note successfully created!", []models.RecipeNote{note})
+}
+
+// Handles changing the text of a note.
+func PatchRecipeNote(w http.ResponseWriter, r *http.Request) {
+	// Extract ids from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	noteId, err := strconv.ParseInt(chi.URLParam(r, "noteId"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request RecipeNoteRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.UpdateRecipeNote(r.Context(), id, noteId, request.Body)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, preferenceErrorStatus(err), err.Error())
+		return
+	}
+
+	note, err := models.FindRecipeNote(r.Context(), id, noteId)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendRecipeNotes(w, http.StatusOK, "Note successfully updated!", []models.RecipeNote{note})
+}
+
+// Handles removing a note.
+func DeleteRecipeNote(w http.ResponseWriter, r *http.Request) {
+	// Extract ids from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	noteId, err := strconv.ParseInt(chi.URLParam(r, "noteId"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeleteRecipeNote(r.Context(), id, noteId)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Helper Functions
+
+// Encodes notes as JSON and sends them with the given status.
+func sendRecipeNotes(w http.ResponseWriter, statusCode int, message string, notes []models.RecipeNote) {
+	responseData := RecipeNoteResponse{
+		Message: message,
+		Data:    notes,
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Maps an error returned by a rating or note model function onto an HTTP
+// status code.
+func preferenceErrorStatus(err error) int {
+	if errors.Is(err, models.ErrInvalidRating) || errors.Is(err, models.ErrEmptyNote) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
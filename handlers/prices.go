@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type PriceResponse struct {
+	Message string         `json:"message"`
+	Data    []models.Price `json:"data"`
+}
+
+type RecipeCostResponse struct {
+	Message string              `json:"message"`
+	Data    []models.RecipeCost `json:"data"`
+}
+
+// Handles getting every ingredient price in the current cookbook.
+func GetPrices(w http.ResponseWriter, r *http.Request) {
+	prices, err := models.ListPrices(r.Context())
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendPrices(w, http.StatusOK, "", prices)
+}
+
+// Handles adding an ingredient price.
+func PostPrice(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var price models.Price
+	err := json.NewDecoder(r.Body).Decode(&price)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := models.CreatePrice(r.Context(), price)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, priceErrorStatus(err), err.Error())
+		return
+	}
+
+	price, err = models.FindPrice(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendPrices(w, http.StatusCreated, "Price successfully created!", []models.Price{price})
+}
+
+// Handles updating an ingredient price.
+func PatchPrice(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var price models.Price
+	err = json.NewDecoder(r.Body).Decode(&price)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.UpdatePrice(r.Context(), id, price)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, priceErrorStatus(err), err.Error())
+		return
+	}
+
+	price, err = models.FindPrice(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendPrices(w, http.StatusOK, "Price successfully updated!", []models.Price{price})
+}
+
+// Handles removing an ingredient price.
+func DeletePrice(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeletePrice(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles estimating what a recipe costs to make, in total and per serving.
+func GetRecipeCost(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cost, err := models.FindRecipeCost(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := RecipeCostResponse{
+		Data: []models.RecipeCost{cost},
+	}
+
+	// Encode the cost in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Helper Functions
+
+// Encodes prices as JSON and sends them with the given status.
+func sendPrices(w http.ResponseWriter, statusCode int, message string, prices []models.Price) {
+	responseData := PriceResponse{
+		Message: message,
+		Data:    prices,
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Maps an error returned by a price model function onto an HTTP status code.
+func priceErrorStatus(err error) int {
+	if errors.Is(err, models.ErrEmptyName) || errors.Is(err, models.ErrInvalidPrice) ||
+		errors.Is(err, models.ErrInvalidQuantity) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/pdf"
+	"github.com/mjande/recipes-microservice/recipetext"
+	"github.com/mjande/recipes-microservice/units"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+var errUnknownFormat = errors.New("format must be json, html, pdf, cooklang or markdown")
+var errSingleRecipeFormat = errors.New("cooklang and markdown exports hold a single recipe")
+
+// Content types and file extensions of recipe text exports
+var recipeTextExports = map[recipetext.Format]struct {
+	contentType string
+	extension   string
+}{
+	recipetext.Cooklang: {"text/x-cooklang; charset=utf-8", ".cook"},
+	recipetext.Markdown: {"text/markdown; charset=utf-8", ".md"},
+}
+
+// Common fractions shown instead of decimals, by their value
+var quantityFractions = []struct {
+	value float64
+	text  string
+}{
+	{1.0 / 8, "1/8"}, {1.0 / 4, "1/4"}, {1.0 / 3, "1/3"}, {1.0 / 2, "1/2"},
+	{2.0 / 3, "2/3"}, {3.0 / 4, "3/4"},
+}
+
+var printTemplateFuncs = template.FuncMap{
+	"quantity": formatQuantity,
+	"steps":    instructionSteps,
+}
+
+var printStyle = `
+		body { font-family: Georgia, serif; max-width: 42rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; color: #111; }
+		h1, h2, h3 { font-family: Helvetica, Arial, sans-serif; }
+		.details { color: #555; font-size: 0.9rem; }
+		.recipe + .recipe { break-before: page; page-break-before: always; }
+		ul.checklist { list-style: none; padding-left: 0; }
+		ul.checklist li::before { content: "\2610"; margin-right: 0.5rem; }
+		@media print { body { margin: 0; max-width: none; } }
+`
+
+var printRecipesTemplate = template.Must(template.New("recipes").Funcs(printTemplateFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>{{.Title}}</title>
+	<style>` + printStyle + `</style>
+</head>
+<body>
+	{{range .Recipes}}<article class="recipe">
+		<h1>{{.Name}}</h1>
+		{{if .Description}}<p>{{.Description}}</p>{{end}}
+		<p class="details">
+			{{if .Servings}}Serves {{.Servings}}{{end}}
+			{{if and .Servings .CookingTime}} &middot; {{end}}
+			{{if .CookingTime}}{{.CookingTime}}{{end}}
+		</p>
+		<h2>Ingredients</h2>
+		<ul>
+			{{range .Ingredients}}<li>{{quantity .Quantity}} {{.Unit}} {{.Name}}</li>
+			{{end}}
+		</ul>
+		<h2>Instructions</h2>
+		<ol>
+			{{range steps .Instructions}}<li>{{.}}</li>
+			{{end}}
+		</ol>
+	</article>
+	{{end}}
+</body>
+</html>
+`))
+
+var printShoppingListTemplate = template.Must(template.New("shopping-list").Funcs(printTemplateFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>{{.Title}}</title>
+	<style>` + printStyle + `</style>
+</head>
+<body>
+	<h1>{{.Title}}</h1>
+	<ul class="checklist">
+		{{range .Items}}<li>{{quantity .Quantity}} {{.Unit}} {{.Name}}{{if .Store}} <span class="details">({{.Store}})</span>{{end}}</li>
+		{{end}}
+	</ul>
+	{{if .TotalCost}}<p class="details">Estimated cost: {{printf "%.2f" .TotalCost}}</p>{{end}}
+</body>
+</html>
+`))
+
+// How recipes and shopping lists are printed: the output format, how much
+// to scale recipes by, and the unit system to show quantities in.
+type printOptions struct {
+	Format   string
+	Scale    float64
+	Servings int
+	System   units.System
+}
+
+// Handles exporting a single recipe as JSON, a printable HTML page, a PDF,
+// or Cooklang or Markdown text, selected by the format query parameter. The
+// scale or servings query parameter scales the ingredients, and units
+// (metric or us) converts them.
+func GetRecipeExport(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	options, err := parsePrintOptions(r)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	recipe, err := models.FindRecipe(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendRecipesDocument(w, recipe.Name, []models.Recipe{recipe}, options)
+}
+
+// Helper Functions
+
+// Reads the format, scale, servings and units query parameters.
+func parsePrintOptions(r *http.Request) (printOptions, error) {
+	query := r.URL.Query()
+	options := printOptions{Format: query.Get("format"), Scale: 1}
+
+	switch options.Format {
+	case "", "json", "html", "pdf", "cooklang", "markdown":
+	default:
+		return printOptions{}, errUnknownFormat
+	}
+
+	if param := query.Get("scale"); param != "" {
+		scale, err := strconv.ParseFloat(param, 64)
+		if err != nil || scale <= 0 || math.IsInf(scale, 0) {
+			return printOptions{}, models.ErrInvalidScale
+		}
+
+		options.Scale = scale
+	}
+
+	if param := query.Get("servings"); param != "" {
+		servings, err := strconv.Atoi(param)
+		if err != nil || servings < 1 {
+			return printOptions{}, errors.New("servings must be a positive number")
+		}
+
+		options.Servings = servings
+	}
+
+	if param := query.Get("units"); param != "" {
+		system, err := units.ParseSystem(param)
+		if err != nil {
+			return printOptions{}, err
+		}
+
+		options.System = system
+	}
+
+	return options, nil
+}
+
+// Scales and converts recipes as the options ask, then sends them in the
+// requested format.
+func sendRecipesDocument(w http.ResponseWriter, title string, recipes []models.Recipe, options printOptions) {
+	scaled := make([]models.Recipe, len(recipes))
+	for i, recipe := range recipes {
+		// Servings take precedence over scale for recipes with known servings
+		scale := options.Scale
+		if options.Servings > 0 && recipe.Servings > 0 {
+			scale = float64(options.Servings) / float64(recipe.Servings)
+		}
+
+		var err error
+		scaled[i], err = models.ScaleRecipe(recipe, scale, options.System)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	switch options.Format {
+	case "html":
+		data := struct {
+			Title   string
+			Recipes []models.Recipe
+		}{title, scaled}
+
+		sendHTML(w, printRecipesTemplate, data)
+	case "pdf":
+		doc := pdf.New(title)
+		for i, recipe := range scaled {
+			if i > 0 {
+				doc.PageBreak()
+			}
+
+			writeRecipePDF(doc, recipe)
+		}
+
+		sendPDF(w, title, doc)
+	case "cooklang", "markdown":
+		if len(scaled) != 1 {
+			utils.SendErrorResponse(w, http.StatusBadRequest, errSingleRecipeFormat.Error())
+			return
+		}
+
+		format := recipetext.Format(options.Format)
+		text, err := recipetext.Export(scaled[0], format)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		export := recipeTextExports[format]
+		w.Header().Set("Content-Type", export.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", fileName(title)+export.extension))
+		w.WriteHeader(http.StatusOK)
+		_, err = io.WriteString(w, text)
+		if err != nil {
+			log.Println(err)
+		}
+	default:
+		responseData := RecipeResponse{
+			Data: scaled,
+		}
+
+		w.WriteHeader(http.StatusOK)
+		err := json.NewEncoder(w).Encode(responseData)
+		if err != nil {
+			log.Println(err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+// Sends a shopping list as a printable HTML page or a PDF. Reports false,
+// without sending anything, when JSON was requested. Recipe text formats are
+// refused.
+func sendShoppingListDocument(w http.ResponseWriter, title string, items []models.ShoppingListItem, options printOptions) bool {
+	totalCost, _ := models.ShoppingListCost(items)
+
+	switch options.Format {
+	case "html":
+		data := struct {
+			Title     string
+			Items     []models.ShoppingListItem
+			TotalCost float64
+		}{title, items, totalCost}
+
+		sendHTML(w, printShoppingListTemplate, data)
+	case "pdf":
+		doc := pdf.New(title)
+		doc.Title(title)
+		for _, item := range items {
+			line := ingredientLine(item.Quantity, item.Unit, item.Name)
+			if item.Store != "" {
+				line += " (" + item.Store + ")"
+			}
+
+			doc.Checkbox(line)
+		}
+
+		if totalCost > 0 {
+			doc.Space(6)
+			doc.Caption(fmt.Sprintf("Estimated cost: %.2f", totalCost))
+		}
+
+		sendPDF(w, title, doc)
+	case "cooklang", "markdown":
+		utils.SendErrorResponse(w, http.StatusBadRequest, "shopping lists cannot be exported as "+options.Format)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// Lays out a recipe on the current page of a PDF.
+func writeRecipePDF(doc *pdf.Document, recipe models.Recipe) {
+	doc.Title(recipe.Name)
+	if recipe.Description != "" {
+		doc.Text(recipe.Description)
+	}
+
+	details := []string{}
+	if recipe.Servings > 0 {
+		details = append(details, fmt.Sprintf("Serves %d", recipe.Servings))
+	}
+	if recipe.CookingTime != "" {
+		details = append(details, recipe.CookingTime)
+	}
+	if len(details) > 0 {
+		doc.Caption(strings.Join(details, " · "))
+	}
+
+	doc.Heading("Ingredients")
+	for _, ingredient := range recipe.Ingredients {
+		doc.Bullet(ingredientLine(ingredient.Quantity, ingredient.Unit, ingredient.Name))
+	}
+
+	doc.Heading("Instructions")
+	for i, step := range instructionSteps(recipe.Instructions) {
+		doc.Numbered(i+1, step)
+	}
+}
+
+func sendHTML(w http.ResponseWriter, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	err := tmpl.Execute(w, data)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func sendPDF(w http.ResponseWriter, title string, doc *pdf.Document) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", fileName(title)+".pdf"))
+	w.WriteHeader(http.StatusOK)
+	_, err := doc.WriteTo(w)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Turns a title into a safe file name, such as "garlic-parmesan-chicken".
+func fileName(title string) string {
+	var name strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			name.WriteRune(r)
+			dash = false
+		} else if !dash && name.Len() > 0 {
+			name.WriteByte('-')
+			dash = true
+		}
+	}
+
+	if name.Len() == 0 {
+		return "export"
+	}
+
+	return strings.TrimSuffix(name.String(), "-")
+}
+
+func ingredientLine(quantity float32, unit string, name string) string {
+	parts := []string{}
+	for _, part := range []string{formatQuantity(quantity), unit, name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Formats a quantity for reading in a kitchen: "1 1/2" rather than "1.5"
+// when it is close to a common fraction, and at most two decimals otherwise.
+// Zero quantities are left out.
+func formatQuantity(quantity float32) string {
+	if quantity <= 0 {
+		return ""
+	}
+
+	value := float64(quantity)
+	whole := math.Floor(value)
+	fraction := value - whole
+
+	if fraction < 0.02 {
+		return strconv.FormatFloat(whole, 'f', -1, 64)
+	} else if fraction > 0.98 {
+		return strconv.FormatFloat(whole+1, 'f', -1, 64)
+	}
+
+	for _, common := range quantityFractions {
+		if math.Abs(fraction-common.value) < 0.02 {
+			if whole == 0 {
+				return common.text
+			}
+
+			return strconv.FormatFloat(whole, 'f', -1, 64) + " " + common.text
+		}
+	}
+
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// Splits instructions into steps, one per non-empty line.
+func instructionSteps(instructions string) []string {
+	steps := []string{}
+	for _, line := range strings.Split(instructions, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			steps = append(steps, line)
+		}
+	}
+
+	return steps
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// The content of a recipe shown through a public share link. It leaves out
+// the owner, household and everything specific to a user, such as favorites,
+// ratings, notes and the cook log.
+type PublicRecipe struct {
+	Name         string             `json:"name"`
+	CookingTime  string             `json:"cookingTime"`
+	Servings     int                `json:"servings"`
+	Description  string             `json:"description"`
+	Instructions string             `json:"instructions"`
+	Ingredients  []PublicIngredient `json:"ingredients"`
+	Tags         []string           `json:"tags"`
+	Allergens    []string           `json:"allergens"`
+	Diets        []string           `json:"diets"`
+	Timers       []PublicTimer      `json:"timers"`
+	Photos       []PublicPhoto      `json:"photos"`
+}
+
+type PublicIngredient struct {
+	Name     string  `json:"name"`
+	Quantity float32 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+type PublicTimer struct {
+	Step     int     `json:"step"`
+	Name     string  `json:"name"`
+	Quantity float32 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+type PublicPhoto struct {
+	Step       *int              `json:"step"`
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	URL        string            `json:"url"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
+
+type PublicRecipeResponse struct {
+	Message string         `json:"message"`
+	Data    []PublicRecipe `json:"data"`
+}
+
+var publicRecipeTemplate = template.Must(template.New("recipe").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>{{.Name}}</title>
+	<style>
+		body { font-family: sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; }
+		.tags span { background: #eee; border-radius: 0.25rem; padding: 0 0.4rem; margin-right: 0.25rem; }
+	</style>
+</head>
+<body>
+	<h1>{{.Name}}</h1>
+	{{if .Description}}<p>{{.Description}}</p>{{end}}
+	{{if .CookingTime}}<p><strong>Cooking time:</strong> {{.CookingTime}}</p>{{end}}
+	{{if .Tags}}<p class="tags">{{range .Tags}}<span>{{.}}</span>{{end}}</p>{{end}}
+	<h2>Ingredients</h2>
+	<ul>
+		{{range .Ingredients}}<li>{{.Quantity}} {{.Unit}} {{.Name}}</li>
+		{{end}}
+	</ul>
+	<h2>Instructions</h2>
+	<p>{{.Instructions}}</p>
+</body>
+</html>
+`))
+
+// Handles getting a read-only view of a recipe through a public share link.
+// This route does not require authentication. The recipe is rendered as an
+// HTML page when requested with format=html or an Accept header preferring
+// HTML, and as JSON otherwise.
+func GetPublicRecipe(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	recipe, err := models.FindRecipeByShareToken(r.Context(), token)
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, models.ErrShareLinkExpired) {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusNotFound, "share link not found or expired")
+		return
+	} else if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Only show anonymous viewers the content of the recipe
+	public := publicRecipe(recipe)
+
+	if wantsHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		err = publicRecipeTemplate.Execute(w, public)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	responseData := PublicRecipeResponse{
+		Data: []PublicRecipe{public},
+	}
+
+	// Encode the recipe in JSON and send as response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Helper Functions
+
+// Copies the content of a recipe that can be shown publicly.
+func publicRecipe(recipe models.Recipe) PublicRecipe {
+	public := PublicRecipe{
+		Name:         recipe.Name,
+		CookingTime:  recipe.CookingTime,
+		Servings:     recipe.Servings,
+		Description:  recipe.Description,
+		Instructions: recipe.Instructions,
+		Ingredients:  []PublicIngredient{},
+		Tags:         recipe.Tags,
+		Allergens:    recipe.Classification.Allergens,
+		Diets:        recipe.Classification.Diets,
+		Timers:       []PublicTimer{},
+		Photos:       []PublicPhoto{},
+	}
+
+	for _, ingredient := range recipe.Ingredients {
+		public.Ingredients = append(public.Ingredients, PublicIngredient{
+			Name:     ingredient.Name,
+			Quantity: ingredient.Quantity,
+			Unit:     ingredient.Unit,
+		})
+	}
+
+	for _, timer := range recipe.Timers {
+		public.Timers = append(public.Timers, PublicTimer{
+			Step:     timer.Step,
+			Name:     timer.Name,
+			Quantity: timer.Quantity,
+			Unit:     timer.Unit,
+		})
+	}
+
+	for _, photo := range recipe.Photos {
+		public.Photos = append(public.Photos, PublicPhoto{
+			Step:       photo.Step,
+			Width:      photo.Width,
+			Height:     photo.Height,
+			URL:        photo.URL,
+			Thumbnails: photo.Thumbnails,
+		})
+	}
+
+	return public
+}
+
+// Reports whether the client asked for an HTML response.
+func wantsHTML(r *http.Request) bool {
+	format := r.URL.Query().Get("format")
+	if format != "" {
+		return format == "html"
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
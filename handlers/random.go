@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Most recipes that can be picked at once.
+const maxRandomCount = 20
+
+// Handles picking random recipes. The count, tag, diet, maxCookingTime
+// (minutes), excludeCookedWithin (days), exclude (ids), seed and session
+// query parameters shape the picks.
+func GetRandomRecipes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := models.RandomFilter{
+		Count:   1,
+		Tags:    queryList(r, "tag"),
+		Diets:   queryList(r, "diet"),
+		Session: query.Get("session"),
+	}
+
+	if param := query.Get("count"); param != "" {
+		count, err := strconv.Atoi(param)
+		if err != nil || count < 1 || count > maxRandomCount {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "count must be a number from 1 to 20")
+			return
+		}
+
+		filter.Count = count
+	}
+
+	if param := query.Get("maxCookingTime"); param != "" {
+		minutes, err := strconv.Atoi(param)
+		if err != nil || minutes < 1 {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "maxCookingTime must be a positive number of minutes")
+			return
+		}
+
+		filter.MaxCookingTime = minutes
+	}
+
+	if param := query.Get("excludeCookedWithin"); param != "" {
+		days, err := strconv.Atoi(param)
+		if err != nil || days < 0 {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "excludeCookedWithin must be a non-negative number of days")
+			return
+		}
+
+		filter.ExcludeCookedWithin = days
+	}
+
+	for _, param := range queryList(r, "exclude") {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "exclude must be a list of recipe ids")
+			return
+		}
+
+		filter.ExcludeIDs = append(filter.ExcludeIDs, id)
+	}
+
+	if param := query.Get("seed"); param != "" {
+		seed, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "seed must be a non-negative number")
+			return
+		}
+
+		filter.Seed = &seed
+	}
+
+	recipes, err := models.PickRandomRecipes(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, classificationErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := RecipeResponse{
+		Data: recipes,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -2,27 +2,67 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/recipetext"
 	"github.com/mjande/recipes-microservice/utils"
 )
 
+// Largest recipe accepted as Cooklang or Markdown text.
+const maxRecipeTextSize = 1 << 20
+
+// Recipe text formats accepted by PostRecipe, by content type
+var recipeTextFormats = map[string]recipetext.Format{
+	"text/cooklang":   recipetext.Cooklang,
+	"text/x-cooklang": recipetext.Cooklang,
+	"text/markdown":   recipetext.Markdown,
+	"text/x-markdown": recipetext.Markdown,
+}
+
 type RecipeResponse struct {
-	Message string          `json:"message"`
-	Data    []models.Recipe `json:"data"`
+	Message    string                  `json:"message"`
+	Data       []models.Recipe         `json:"data"`
+	Warning    string                  `json:"warning,omitempty"`
+	Duplicates []models.DuplicateMatch `json:"duplicates,omitempty"`
 }
 
-// Handles getting a list of recipes.
+// Handles getting a list of recipes. The diet, excludeAllergen and
+// favorites query parameters filter the list and sort orders it.
 func GetRecipes(w http.ResponseWriter, r *http.Request) {
+	filter := models.RecipeFilter{
+		Diets:            queryList(r, "diet"),
+		ExcludeAllergens: queryList(r, "excludeAllergen"),
+		Sort:             r.URL.Query().Get("sort"),
+	}
+
+	if param := r.URL.Query().Get("favorites"); param != "" {
+		favoritesOnly, err := strconv.ParseBool(param)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "favorites must be true or false")
+			return
+		}
+
+		filter.FavoritesOnly = favoritesOnly
+	}
+
 	// Call database function to query recipes
-	recipes, err := models.ListRecipes(r.Context())
-	if err != nil {
+	recipes, err := models.ListRecipes(r.Context(), filter)
+	if errors.Is(err, models.ErrInvalidSort) {
 		log.Println(err)
-		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, classificationErrorStatus(err), err.Error())
 		return
 	}
 
@@ -53,7 +93,7 @@ func GetRecipe(w http.ResponseWriter, r *http.Request) {
 	recipe, err := models.FindRecipe(r.Context(), id)
 	if err != nil {
 		log.Println(err)
-		utils.SendErrorResponse(w, http.StatusNotFound, err.Error())
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
 		return
 	}
 
@@ -70,11 +110,12 @@ func GetRecipe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Handles creating a recipe with ingredients
+// Handles creating a recipe with ingredients. The recipe is sent as JSON, or
+// as Cooklang or Markdown text with a text/x-cooklang or text/markdown
+// content type.
 func PostRecipe(w http.ResponseWriter, r *http.Request) {
-	// Decode JSON data from request
-	var recipe models.Recipe
-	err := json.NewDecoder(r.Body).Decode(&recipe)
+	// Decode recipe from request
+	recipe, err := decodeRecipe(w, r)
 	if err != nil {
 		log.Println(err)
 		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
@@ -83,7 +124,11 @@ func PostRecipe(w http.ResponseWriter, r *http.Request) {
 
 	// Use database function to create recipe
 	id, err := models.CreateRecipe(r.Context(), recipe)
-	if err != nil {
+	if errors.Is(err, models.ErrForbidden) {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	} else if err != nil {
 		log.Println(err)
 		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
@@ -102,6 +147,15 @@ func PostRecipe(w http.ResponseWriter, r *http.Request) {
 		Data:    []models.Recipe{recipe},
 	}
 
+	// Warn about likely duplicates, but still create the recipe
+	duplicates, err := models.FindDuplicates(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+	} else if len(duplicates) > 0 {
+		responseData.Warning = "This recipe looks like a duplicate of " + duplicates[0].Name
+		responseData.Duplicates = duplicates
+	}
+
 	// Encode recipe as JSON and send response
 	w.WriteHeader(http.StatusCreated)
 	err = json.NewEncoder(w).Encode(responseData)
@@ -125,7 +179,7 @@ func PatchRecipe(w http.ResponseWriter, r *http.Request) {
 	_, err = models.FindRecipe(r.Context(), id)
 	if err != nil {
 		log.Println(err)
-		utils.SendErrorResponse(w, http.StatusNotFound, err.Error())
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
 		return
 	}
 
@@ -140,7 +194,11 @@ func PatchRecipe(w http.ResponseWriter, r *http.Request) {
 
 	// Use database function to create recipe
 	id, err = models.UpdateRecipe(r.Context(), id, recipe)
-	if err != nil {
+	if errors.Is(err, models.ErrForbidden) {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	} else if err != nil {
 		log.Println(err)
 		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
@@ -181,7 +239,7 @@ func DeleteRecipe(w http.ResponseWriter, r *http.Request) {
 	err = models.DeleteRecipe(r.Context(), id)
 	if err != nil {
 		log.Println(err)
-		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
 		return
 	}
 
@@ -198,3 +256,76 @@ func DeleteRecipe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// Helper Functions
+
+// Reads a recipe from a request body in the format its content type names,
+// which is JSON unless it is one of recipeTextFormats.
+func decodeRecipe(w http.ResponseWriter, r *http.Request) (models.Recipe, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	format, ok := recipeTextFormats[mediaType]
+	if !ok {
+		var recipe models.Recipe
+		err := json.NewDecoder(r.Body).Decode(&recipe)
+		return recipe, err
+	}
+
+	text, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRecipeTextSize))
+	if err != nil {
+		return models.Recipe{}, err
+	}
+
+	return recipetext.Parse(format, string(text))
+}
+
+// Maps an error returned while loading or modifying a recipe (or another
+// access-controlled resource) onto the HTTP status code that should be sent
+// to the client.
+func recipeErrorStatus(err error) int {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return http.StatusNotFound
+	} else if errors.Is(err, models.ErrForbidden) {
+		return http.StatusForbidden
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Loads a recipe after it was changed and sends it as JSON.
+func sendRecipe(w http.ResponseWriter, r *http.Request, id int64, message string) {
+	recipe, err := models.FindRecipe(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseData := RecipeResponse{
+		Message: message,
+		Data:    []models.Recipe{recipe},
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Collects the values of a query parameter that may be repeated or hold a
+// comma-separated list.
+func queryList(r *http.Request, name string) []string {
+	values := []string{}
+	for _, param := range r.URL.Query()[name] {
+		for _, value := range strings.Split(param, ",") {
+			value = strings.TrimSpace(value)
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
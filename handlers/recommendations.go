@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Number of recommendations returned unless the request sets limit, and the
+// most it may ask for.
+const (
+	defaultRecommendationLimit = 10
+	maxRecommendationLimit     = 50
+)
+
+type RecommendationResponse struct {
+	Message string                  `json:"message"`
+	Data    []models.Recommendation `json:"data"`
+}
+
+// Handles suggesting recipes for the current user to cook, each with the
+// reasons it was suggested. The limit query parameter sets how many.
+func GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRecommendationLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 1 || parsed > maxRecommendationLimit {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "limit must be a number from 1 to 50")
+			return
+		}
+
+		limit = parsed
+	}
+
+	recommendations, err := models.RecommendRecipes(r.Context(), limit)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := RecommendationResponse{
+		Data: recommendations,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type SubstitutionResponse struct {
+	Message string                `json:"message"`
+	Data    []models.Substitution `json:"data"`
+}
+
+type RecipeSubstitutionsResponse struct {
+	Message string                           `json:"message"`
+	Data    []models.IngredientSubstitutions `json:"data"`
+}
+
+type SubstitutionRequest struct {
+	Ingredient string   `json:"ingredient"`
+	Substitute string   `json:"substitute"`
+	Ratio      *float32 `json:"ratio"`
+	Unit       string   `json:"unit"`
+	Notes      string   `json:"notes"`
+	Diets      []string `json:"diets"`
+}
+
+type SubstitutedRecipeRequest struct {
+	Diet string `json:"diet"`
+}
+
+// Handles getting the substitution rules available to the current cookbook,
+// optionally only those for the ingredient given in the ingredient query
+// parameter.
+func GetSubstitutions(w http.ResponseWriter, r *http.Request) {
+	substitutions, err := models.ListSubstitutions(r.Context(), r.URL.Query().Get("ingredient"))
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendSubstitutions(w, http.StatusOK, "", substitutions)
+}
+
+// Handles adding a substitution rule to the current cookbook. The ratio
+// defaults to 1.
+func PostSubstitution(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var request SubstitutionRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	substitution := models.Substitution{
+		Ingredient: request.Ingredient,
+		Substitute: request.Substitute,
+		Ratio:      1,
+		Unit:       request.Unit,
+		Notes:      request.Notes,
+		Diets:      request.Diets,
+	}
+	if request.Ratio != nil {
+		substitution.Ratio = *request.Ratio
+	}
+
+	id, err := models.CreateSubstitution(r.Context(), substitution)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, substitutionErrorStatus(err), err.Error())
+		return
+	}
+
+	substitution, err = models.FindSubstitution(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendSubstitutions(w, http.StatusCreated, "Substitution successfully created!", []models.Substitution{substitution})
+}
+
+// Handles removing a substitution rule from the current cookbook.
+func DeleteSubstitution(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeleteSubstitution(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles getting substitutions for a recipe's ingredients, optionally only
+// those needed to fit the diet given in the diet query parameter.
+func GetRecipeSubstitutions(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	substitutions, err := models.RecipeSubstitutions(r.Context(), id, r.URL.Query().Get("diet"))
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, substitutionErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := RecipeSubstitutionsResponse{
+		Data: substitutions,
+	}
+
+	// Encode the substitutions in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles creating a copy of a recipe with its ingredients substituted to fit
+// a diet.
+func PostSubstitutedRecipe(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request SubstitutedRecipeRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	copyId, err := models.CreateSubstitutedRecipe(r.Context(), id, request.Diet)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, substitutionErrorStatus(err), err.Error())
+		return
+	}
+
+	recipe, err := models.FindRecipe(r.Context(), copyId)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseData := RecipeResponse{
+		Message: "Substituted recipe successfully created!",
+		Data:    []models.Recipe{recipe},
+	}
+
+	// Encode recipe as JSON and send response
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Helper Functions
+
+// Encodes substitution rules as JSON and sends them with the given status.
+func sendSubstitutions(w http.ResponseWriter, statusCode int, message string, substitutions []models.Substitution) {
+	responseData := SubstitutionResponse{
+		Message: message,
+		Data:    substitutions,
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Maps an error returned by a substitution model function onto an HTTP status
+// code.
+func substitutionErrorStatus(err error) int {
+	if errors.Is(err, models.ErrNotInCatalog) || errors.Is(err, models.ErrInvalidRatio) ||
+		errors.Is(err, models.ErrInvalidSubstitution) || errors.Is(err, models.ErrUnknownUnit) ||
+		errors.Is(err, models.ErrEmptyName) {
+		return http.StatusBadRequest
+	}
+
+	return classificationErrorStatus(err)
+}
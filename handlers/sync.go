@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type SyncResponse struct {
+	Message string           `json:"message"`
+	Data    models.SyncDelta `json:"data"`
+}
+
+// Changes pushed by an offline client. Token is the sync token the client
+// last synced to, which changes are checked for conflicts against.
+type SyncPushRequest struct {
+	Token   string              `json:"token"`
+	Changes []models.PushChange `json:"changes"`
+}
+
+type SyncPushResponse struct {
+	Message string              `json:"message"`
+	Data    []models.PushResult `json:"data"`
+}
+
+// Handles getting the recipes, ingredients and tags that changed since the
+// sync token in the since query parameter, including deleted ones. Without
+// a token, the whole cookbook is sent.
+func GetSync(w http.ResponseWriter, r *http.Request) {
+	delta, err := models.SyncChanges(r.Context(), r.URL.Query().Get("since"))
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, syncErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := SyncResponse{
+		Data: delta,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles applying a batch of changes made by an offline client. Each change
+// gets a result saying whether it was applied, conflicted with a change on
+// the server or failed. Clients sync afterwards to pick up the server's
+// changes.
+func PostSync(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var request SyncPushRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, err := models.PushChanges(r.Context(), request.Token, request.Changes)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, syncErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := SyncPushResponse{
+		Message: "Changes processed",
+		Data:    results,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Helper Functions
+
+// Maps an error returned while syncing onto an HTTP status code.
+func syncErrorStatus(err error) int {
+	if errors.Is(err, models.ErrInvalidSyncToken) || errors.Is(err, models.ErrTooManyChanges) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type TagResponse struct {
+	Message string       `json:"message"`
+	Data    []models.Tag `json:"data"`
+}
+
+type MergeTagsRequest struct {
+	SourceIDs []int64 `json:"sourceIds"`
+}
+
+// Handles getting the tags in the current cookbook with their usage counts.
+func GetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := models.ListTags(r.Context())
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := TagResponse{
+		Data: tags,
+	}
+
+	// Encode the tags in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles renaming a tag across all recipes.
+func PatchTag(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var tag models.Tag
+	err = json.NewDecoder(r.Body).Decode(&tag)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tag, err = models.RenameTag(r.Context(), id, tag.Name)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, tagErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := TagResponse{
+		Message: "Tag successfully renamed!",
+		Data:    []models.Tag{tag},
+	}
+
+	// Encode tag as JSON and send response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles merging other tags into a tag.
+func PostMergeTags(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request
+	var request MergeTagsRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tag, err := models.MergeTags(r.Context(), id, request.SourceIDs)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, tagErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := TagResponse{
+		Message: "Tags successfully merged!",
+		Data:    []models.Tag{tag},
+	}
+
+	// Encode tag as JSON and send response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles deleting a tag from all recipes.
+func DeleteTag(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeleteTag(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, tagErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Helper Functions
+
+// Maps an error returned while modifying a tag onto an HTTP status code.
+func tagErrorStatus(err error) int {
+	if errors.Is(err, models.ErrTagExists) {
+		return http.StatusConflict
+	} else if errors.Is(err, models.ErrEmptyTag) {
+		return http.StatusBadRequest
+	}
+
+	return recipeErrorStatus(err)
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+type WebhookResponse struct {
+	Message string           `json:"message"`
+	Data    []models.Webhook `json:"data"`
+}
+
+type WebhookDeliveryResponse struct {
+	Message string                   `json:"message"`
+	Data    []models.WebhookDelivery `json:"data"`
+}
+
+// Handles getting the webhooks of the cookbook, and the global webhooks for
+// admins.
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := models.ListWebhooks(r.Context())
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendWebhooks(w, http.StatusOK, "", hooks)
+}
+
+// Handles getting a single webhook.
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := models.FindWebhook(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	sendWebhooks(w, http.StatusOK, "", []models.Webhook{webhook})
+}
+
+// Handles registering a webhook. The response holds the secret requests to
+// the webhook are signed with, which is not shown again.
+func PostWebhook(w http.ResponseWriter, r *http.Request) {
+	// Decode JSON data from request
+	var webhook models.Webhook
+	err := json.NewDecoder(r.Body).Decode(&webhook)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, secret, err := models.CreateWebhook(r.Context(), webhook)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	webhook, err = models.FindWebhook(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	webhook.Secret = secret
+	sendWebhooks(w, http.StatusCreated, "Webhook successfully created!", []models.Webhook{webhook})
+}
+
+// Handles updating a webhook's URL, events or active flag. Only the fields
+// in the request are changed.
+func PatchWebhook(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decode JSON data from request. Fields left out are not changed.
+	var patch models.WebhookPatch
+	err = json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := models.UpdateWebhook(r.Context(), id, patch)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	sendWebhooks(w, http.StatusOK, "Webhook successfully updated!", []models.Webhook{webhook})
+}
+
+// Handles removing a webhook.
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, recipeErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles getting the delivery log of a webhook. The status query parameter
+// limits it to pending, succeeded or dead deliveries.
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Extract id from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := models.ListWebhookDeliveries(r.Context(), id, r.URL.Query().Get("status"))
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	responseData := WebhookDeliveryResponse{
+		Data: deliveries,
+	}
+
+	// Encode the deliveries in JSON and send as response
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Handles sending a dead delivery again.
+func PostRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	// Extract ids from request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveryId, err := strconv.ParseInt(chi.URLParam(r, "deliveryId"), 10, 64)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = models.RetryWebhookDelivery(r.Context(), id, deliveryId)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(WebhookDeliveryResponse{Message: "Delivery will be retried"})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Helper Functions
+
+// Encodes webhooks as JSON and sends them with the given status.
+func sendWebhooks(w http.ResponseWriter, statusCode int, message string, hooks []models.Webhook) {
+	responseData := WebhookResponse{
+		Message: message,
+		Data:    hooks,
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(responseData)
+	if err != nil {
+		log.Println(err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// Maps an error returned by a webhook model function onto an HTTP status
+// code.
+func webhookErrorStatus(err error) int {
+	if errors.Is(err, models.ErrInvalidWebhookURL) || errors.Is(err, models.ErrForbiddenWebhookAddress) ||
+		errors.Is(err, models.ErrUnresolvableWebhookHost) || errors.Is(err, models.ErrUnknownWebhookEvent) ||
+		errors.Is(err, models.ErrUnknownDeliveryStatus) {
+		return http.StatusBadRequest
+	} else if errors.Is(err, models.ErrDeliveryNotDead) {
+		return http.StatusConflict
+	}
+
+	return recipeErrorStatus(err)
+}
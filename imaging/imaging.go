@@ -0,0 +1,142 @@
+// Package imaging decodes uploaded photos and scales them down into
+// thumbnails using only the standard library image codecs.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// Register the decoders for the supported formats
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Largest number of pixels an uploaded image may have. Checked before the
+// image is decoded, so that small files cannot expand into huge images.
+const MaxPixels = 40_000_000
+
+// Quality of generated JPEG thumbnails.
+const thumbnailQuality = 85
+
+var ErrUnsupportedFormat = errors.New("image must be a JPEG, PNG or GIF")
+var ErrTooManyPixels = errors.New("image dimensions are too large")
+
+// Content types of the supported image formats
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// A thumbnail size. Thumbnails fit within a square of MaxSize pixels.
+type Size struct {
+	Name    string
+	MaxSize int
+}
+
+// Sizes of the thumbnails generated for each photo, from smallest to largest.
+var ThumbnailSizes = []Size{
+	{"small", 160},
+	{"medium", 480},
+	{"large", 1024},
+}
+
+// Sniffs the content type of an uploaded file from its contents, ignoring
+// whatever the client claimed, and decodes it. Returns the content type and
+// the decoded image.
+func Decode(data []byte) (string, image.Image, error) {
+	contentType := http.DetectContentType(data)
+	if !supportedTypes[contentType] {
+		return "", nil, ErrUnsupportedFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, ErrUnsupportedFormat
+	}
+
+	if config.Width*config.Height > MaxPixels {
+		return "", nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, ErrUnsupportedFormat
+	}
+
+	return contentType, img, nil
+}
+
+// Scales an image down to fit within a square of maxSize pixels, keeping its
+// aspect ratio. Images that already fit are returned unchanged. Each output
+// pixel averages the block of source pixels it covers.
+func Resize(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+
+	newWidth, newHeight := maxSize, maxSize
+	if width > height {
+		newHeight = max(1, height*maxSize/width)
+	} else {
+		newWidth = max(1, width*maxSize/height)
+	}
+
+	// Work on RGBA pixels directly rather than through the image.Image
+	// interface, which is much slower
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0, y1 := y*height/newHeight, max((y+1)*height/newHeight, y*height/newHeight+1)
+
+		for x := 0; x < newWidth; x++ {
+			x0, x1 := x*width/newWidth, max((x+1)*width/newWidth, x*width/newWidth+1)
+
+			var r, g, b, a, count int
+			for sy := y0; sy < y1; sy++ {
+				offset := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(rgba.Pix[offset])
+					g += int(rgba.Pix[offset+1])
+					b += int(rgba.Pix[offset+2])
+					a += int(rgba.Pix[offset+3])
+					count++
+					offset += 4
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count)
+			dst.Pix[offset+1] = uint8(g / count)
+			dst.Pix[offset+2] = uint8(b / count)
+			dst.Pix[offset+3] = uint8(a / count)
+		}
+	}
+
+	return dst
+}
+
+// Encodes an image as a JPEG thumbnail. Transparent areas become white
+// rather than black.
+func EncodeThumbnail(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	flattened := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flattened, flattened.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flattened, flattened.Bounds(), img, bounds.Min, draw.Over)
+
+	var buffer bytes.Buffer
+	err := jpeg.Encode(&buffer, flattened, &jpeg.Options{Quality: thumbnailQuality})
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
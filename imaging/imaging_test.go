@@ -0,0 +1,142 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func solidImage(width int, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func TestDecode(t *testing.T) {
+	img := solidImage(4, 3, color.RGBA{200, 100, 50, 255})
+
+	var pngData, jpegData, gifData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.Encode(&gifData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A PNG whose header claims more pixels than allowed
+	var huge bytes.Buffer
+	if err := png.Encode(&huge, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	hugeData := huge.Bytes()
+	copy(hugeData[16:24], []byte{0, 0, 0x27, 0x10, 0, 0, 0x27, 0x10}) // 10000 x 10000
+	binary.BigEndian.PutUint32(hugeData[29:33], crc32.ChecksumIEEE(hugeData[12:29]))
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		err         error
+	}{
+		{"png", pngData.Bytes(), "image/png", nil},
+		{"jpeg", jpegData.Bytes(), "image/jpeg", nil},
+		{"gif", gifData.Bytes(), "image/gif", nil},
+		{"text", []byte("hello, world"), "", ErrUnsupportedFormat},
+		{"truncated png", pngData.Bytes()[:20], "", ErrUnsupportedFormat},
+		{"too many pixels", hugeData, "", ErrTooManyPixels},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contentType, decoded, err := Decode(test.data)
+			if contentType != test.contentType || !errors.Is(err, test.err) {
+				t.Fatalf("got %q %v, want %q %v", contentType, err, test.contentType, test.err)
+			}
+
+			if err == nil && (decoded.Bounds().Dx() != 4 || decoded.Bounds().Dy() != 3) {
+				t.Errorf("got a %v image, want 4x3", decoded.Bounds())
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		maxSize       int
+		wantW, wantH  int
+	}{
+		{"fits already", 100, 50, 160, 100, 50},
+		{"landscape", 1000, 500, 160, 160, 80},
+		{"portrait", 500, 1000, 160, 80, 160},
+		{"square", 300, 300, 100, 100, 100},
+		{"thin keeps a pixel", 1000, 2, 100, 100, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resized := Resize(image.NewRGBA(image.Rect(0, 0, test.width, test.height)), test.maxSize)
+			if resized.Bounds().Dx() != test.wantW || resized.Bounds().Dy() != test.wantH {
+				t.Errorf("got %v, want %dx%d", resized.Bounds(), test.wantW, test.wantH)
+			}
+		})
+	}
+}
+
+func TestResizeAveragesPixels(t *testing.T) {
+	// Left half black, right half white
+	src := solidImage(4, 2, color.White)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			src.Set(x, y, color.Black)
+		}
+	}
+
+	resized := Resize(src, 2).(*image.RGBA)
+
+	if got := resized.RGBAAt(0, 0); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("left pixel is %v, want black", got)
+	}
+	if got := resized.RGBAAt(1, 0); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("right pixel is %v, want white", got)
+	}
+
+	// Averaging across the halves gives grey
+	single := Resize(src, 1).(*image.RGBA)
+	if got := single.RGBAAt(0, 0); got != (color.RGBA{127, 127, 127, 255}) {
+		t.Errorf("averaged pixel is %v, want grey", got)
+	}
+}
+
+func TestEncodeThumbnailFlattensTransparency(t *testing.T) {
+	data, err := EncodeThumbnail(image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, g, b, _ := decoded.At(4, 4).RGBA()
+	if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Errorf("transparent pixel became %d %d %d, want white", r>>8, g>>8, b>>8)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -11,9 +16,17 @@ import (
 	"github.com/go-chi/jwtauth/v5"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
 	"github.com/mjande/recipes-microservice/handlers"
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/storage"
+	"github.com/mjande/recipes-microservice/utils"
+	"github.com/mjande/recipes-microservice/webhooks"
 )
 
+// How long to wait for requests to finish when shutting down
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Connect to database
 	err := database.InitDB()
@@ -22,14 +35,32 @@ func main() {
 	}
 	defer database.DB.Close()
 
+	// Link ingredients added before their catalog entries existed, and
+	// classify recipes that never have been
+	err = models.LinkUnmatchedIngredients(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = models.ClassifyPendingRecipes(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Set up storage for uploaded files
+	err = storage.InitStorage()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Create new router
 	router := chi.NewRouter()
 
 	// Middleware
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{os.Getenv("CLIENT_URL")},
-		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "X-Household-ID", "Last-Event-ID"},
 		AllowCredentials: true,
 	}))
 
@@ -37,26 +68,216 @@ func main() {
 
 	tokenAuth := jwtauth.New("HS256", []byte(os.Getenv("SECRET_KEY")), nil)
 
-	router.Use(jwtauth.Verifier(tokenAuth))
-
-	// Routes
-	router.Route("/ingredients", func(r chi.Router) {
-		r.Get("/", handlers.GetIngredients)
-		r.Post("/", handlers.GetIngredientsByMultipleRecipes)
+	// Public routes that do not require a JWT
+	router.Route("/public", func(r chi.Router) {
+		r.Get("/recipes/{token}", handlers.GetPublicRecipe)
 	})
 
-	router.Route("/recipes", func(r chi.Router) {
-		r.Get("/", handlers.GetRecipes)
-		r.Get("/{id}", handlers.GetRecipe)
-		r.Post("/", handlers.PostRecipe)
-		r.Patch("/{id}", handlers.PatchRecipe)
-		r.Delete("/{id}", handlers.DeleteRecipe)
+	// Serve uploaded files when they are stored locally
+	if local, ok := storage.Store.(*storage.Local); ok {
+		router.Handle(local.Path()+"/*", local.Handler())
+	}
+
+	// Authenticated routes
+	router.Group(func(r chi.Router) {
+		r.Use(jwtauth.Verifier(tokenAuth))
+		r.Use(utils.HouseholdScope)
+
+		r.Route("/households", func(r chi.Router) {
+			r.Get("/", handlers.GetHouseholds)
+			r.Get("/{id}", handlers.GetHousehold)
+			r.Post("/", handlers.PostHousehold)
+			r.Patch("/{id}", handlers.PatchHousehold)
+			r.Delete("/{id}", handlers.DeleteHousehold)
+
+			r.Post("/{id}/members", handlers.PostHouseholdMember)
+			r.Delete("/{id}/members/{userId}", handlers.DeleteHouseholdMember)
+		})
+
+		r.Route("/ingredients", func(r chi.Router) {
+			r.Get("/", handlers.GetIngredients)
+			r.Get("/suggest", handlers.GetIngredientSuggestions)
+			r.Post("/", handlers.GetIngredientsByMultipleRecipes)
+			r.Patch("/{id}/catalog", handlers.PatchIngredientCatalog)
+		})
+
+		r.Route("/catalog", func(r chi.Router) {
+			r.Get("/", handlers.GetCatalogEntries)
+			r.Get("/suggest", handlers.GetCatalogSuggestion)
+			r.Get("/{id}", handlers.GetCatalogEntry)
+		})
+
+		r.Route("/cooklog", func(r chi.Router) {
+			r.Get("/", handlers.GetCookLog)
+			r.Get("/forgotten", handlers.GetForgottenRecipes)
+			r.Patch("/{id}", handlers.PatchCookLogEntry)
+			r.Delete("/{id}", handlers.DeleteCookLogEntry)
+		})
+
+		r.Route("/prices", func(r chi.Router) {
+			r.Get("/", handlers.GetPrices)
+			r.Post("/", handlers.PostPrice)
+			r.Patch("/{id}", handlers.PatchPrice)
+			r.Delete("/{id}", handlers.DeletePrice)
+		})
+
+		r.Route("/substitutions", func(r chi.Router) {
+			r.Get("/", handlers.GetSubstitutions)
+			r.Post("/", handlers.PostSubstitution)
+			r.Delete("/{id}", handlers.DeleteSubstitution)
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/", handlers.GetTags)
+			r.Patch("/{id}", handlers.PatchTag)
+			r.Delete("/{id}", handlers.DeleteTag)
+			r.Post("/{id}/merge", handlers.PostMergeTags)
+		})
+
+		r.Route("/collections", func(r chi.Router) {
+			r.Get("/", handlers.GetCollections)
+			r.Get("/{id}", handlers.GetCollection)
+			r.Post("/", handlers.PostCollection)
+			r.Patch("/{id}", handlers.PatchCollection)
+			r.Delete("/{id}", handlers.DeleteCollection)
+
+			r.Post("/{id}/recipes", handlers.PostCollectionRecipe)
+			r.Patch("/{id}/recipes", handlers.PatchCollectionRecipes)
+			r.Delete("/{id}/recipes/{recipeId}", handlers.DeleteCollectionRecipe)
+
+			r.Get("/{id}/export", handlers.GetCollectionExport)
+			r.Get("/{id}/shopping-list", handlers.GetCollectionShoppingList)
+		})
+
+		r.Route("/mealplans", func(r chi.Router) {
+			r.Get("/", handlers.GetMealPlans)
+			r.Get("/shopping-list", handlers.GetMealPlanShoppingList)
+			r.Get("/{id}", handlers.GetMealPlan)
+			r.Post("/", handlers.PostMealPlan)
+			r.Post("/copy", handlers.PostCopyMealPlanWeek)
+			r.Post("/generate", handlers.PostGenerateMealPlan)
+			r.Patch("/{id}", handlers.PatchMealPlan)
+			r.Delete("/{id}", handlers.DeleteMealPlan)
+		})
+
+		r.Route("/pantry", func(r chi.Router) {
+			r.Get("/", handlers.GetPantryItems)
+			r.Get("/matches", handlers.GetPantryMatches)
+			r.Post("/", handlers.PostPantryItem)
+			r.Patch("/{id}", handlers.PatchPantryItem)
+			r.Delete("/{id}", handlers.DeletePantryItem)
+		})
+
+		r.Get("/events", handlers.GetEvents)
+
+		r.Get("/sync", handlers.GetSync)
+		r.Post("/sync", handlers.PostSync)
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", handlers.GetWebhooks)
+			r.Get("/{id}", handlers.GetWebhook)
+			r.Post("/", handlers.PostWebhook)
+			r.Patch("/{id}", handlers.PatchWebhook)
+			r.Delete("/{id}", handlers.DeleteWebhook)
+
+			r.Get("/{id}/deliveries", handlers.GetWebhookDeliveries)
+			r.Post("/{id}/deliveries/{deliveryId}/retry", handlers.PostRetryWebhookDelivery)
+		})
+
+		r.Route("/recipes", func(r chi.Router) {
+			r.Get("/", handlers.GetRecipes)
+			r.Get("/shared", handlers.GetSharedRecipes)
+			r.Get("/recommendations", handlers.GetRecommendations)
+			r.Get("/duplicates", handlers.GetDuplicateClusters)
+			r.Get("/random", handlers.GetRandomRecipes)
+			r.Get("/{id}", handlers.GetRecipe)
+			r.Post("/", handlers.PostRecipe)
+			r.Patch("/{id}", handlers.PatchRecipe)
+			r.Delete("/{id}", handlers.DeleteRecipe)
+			r.Post("/{id}/merge", handlers.PostMergeRecipe)
+			r.Get("/{id}/export", handlers.GetRecipeExport)
+
+			r.Get("/{id}/shares", handlers.GetRecipeShares)
+			r.Post("/{id}/shares", handlers.PostRecipeShare)
+			r.Delete("/{id}/shares/{userId}", handlers.DeleteRecipeShare)
+
+			r.Get("/{id}/links", handlers.GetShareLinks)
+			r.Post("/{id}/links", handlers.PostShareLink)
+			r.Delete("/{id}/links/{linkId}", handlers.DeleteShareLink)
+
+			r.Post("/{id}/move", handlers.PostMoveRecipe)
+
+			r.Get("/{id}/nutrition", handlers.GetRecipeNutrition)
+			r.Get("/{id}/cost", handlers.GetRecipeCost)
+
+			r.Put("/{id}/classification", handlers.PutRecipeClassification)
+			r.Delete("/{id}/classification", handlers.DeleteRecipeClassification)
+
+			r.Put("/{id}/favorite", handlers.PutFavorite)
+			r.Delete("/{id}/favorite", handlers.DeleteFavorite)
+			r.Put("/{id}/rating", handlers.PutRating)
+			r.Delete("/{id}/rating", handlers.DeleteRating)
+
+			r.Get("/{id}/notes", handlers.GetRecipeNotes)
+			r.Post("/{id}/notes", handlers.PostRecipeNote)
+			r.Patch("/{id}/notes/{noteId}", handlers.PatchRecipeNote)
+			r.Delete("/{id}/notes/{noteId}", handlers.DeleteRecipeNote)
+
+			r.Get("/{id}/cooklog", handlers.GetRecipeCookLog)
+			r.Post("/{id}/cooklog", handlers.PostCookLogEntry)
+
+			r.Get("/{id}/photos", handlers.GetRecipePhotos)
+			r.Post("/{id}/photos", handlers.PostRecipePhotos)
+			r.Delete("/{id}/photos/{photoId}", handlers.DeleteRecipePhoto)
+
+			r.Get("/{id}/substitutions", handlers.GetRecipeSubstitutions)
+			r.Post("/{id}/substitute", handlers.PostSubstitutedRecipe)
+		})
 	})
 
+	// Send webhook deliveries in the background until shutting down
+	dispatchCtx, stopDispatching := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+	go func() {
+		dispatcher := webhooks.Dispatcher{Store: models.WebhookOutbox{}}
+		dispatcher.Run(dispatchCtx)
+		close(dispatchDone)
+	}()
+
+	server := &http.Server{Addr: ":" + os.Getenv("PORT"), Handler: router}
+
+	// Event streams never go idle, so end them before waiting for requests
+	// to finish
+	server.RegisterOnShutdown(events.Default.Close)
+
+	// Shut down cleanly on interrupt or termination
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		<-stop
+		log.Println("Shutting down recipes service")
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := server.Shutdown(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+		close(shutdownDone)
+	}()
+
 	// Start server
 	log.Printf("Recipes service listening on port %s", os.Getenv("PORT"))
-	err = http.ListenAndServe(":"+os.Getenv("PORT"), router)
-	if err != nil {
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
+
+	<-shutdownDone
+
+	stopDispatching()
+	<-dispatchDone
 }
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
+	"github.com/mjande/recipes-microservice/normalizer"
+)
+
+// Maximum number of catalog entries returned when browsing the catalog.
+const catalogPageSize = 50
+
+type CatalogEntry struct {
+	ID           int64    `json:"id"`
+	Name         string   `json:"name"`
+	Category     string   `json:"category"`
+	Synonyms     []string `json:"synonyms"`
+	Allergens    []string `json:"allergens"`
+	AnimalSource string   `json:"animalSource"`
+}
+
+type CatalogSuggestion struct {
+	Input      string        `json:"input"`
+	Normalized string        `json:"normalized"`
+	Entry      *CatalogEntry `json:"entry"`
+}
+
+// Queries catalog entries whose name or synonyms start with the given
+// search text, optionally restricted to a category. Empty filters match
+// every entry.
+func ListCatalogEntries(ctx context.Context, search string, category string) ([]CatalogEntry, error) {
+	query := `SELECT c.id, c.name, c.category,
+			ARRAY(SELECT synonym FROM ingredient_synonyms WHERE catalog_id = c.id ORDER BY synonym),
+			c.allergens, c.animal_source
+		FROM ingredient_catalog c
+		WHERE ($1 = '' OR c.name LIKE $1 || '%' OR EXISTS (
+				SELECT 1 FROM ingredient_synonyms s WHERE s.catalog_id = c.id AND s.synonym LIKE $1 || '%'))
+			AND ($2 = '' OR c.category = $2)
+		ORDER BY c.name
+		LIMIT $3`
+
+	rows, err := database.DB.Query(ctx, query, normalizer.Normalize(search), category, catalogPageSize)
+	if err != nil {
+		return []CatalogEntry{}, err
+	}
+	defer rows.Close()
+
+	entries := []CatalogEntry{}
+	for rows.Next() {
+		var entry CatalogEntry
+
+		err = rows.Scan(&entry.ID, &entry.Name, &entry.Category, &entry.Synonyms,
+			&entry.Allergens, &entry.AnimalSource)
+		if err != nil {
+			return []CatalogEntry{}, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []CatalogEntry{}, err
+	}
+
+	return entries, nil
+}
+
+// Queries a single catalog entry with its synonyms.
+func FindCatalogEntry(ctx context.Context, id int64) (CatalogEntry, error) {
+	query := `SELECT c.id, c.name, c.category,
+			ARRAY(SELECT synonym FROM ingredient_synonyms WHERE catalog_id = c.id ORDER BY synonym),
+			c.allergens, c.animal_source
+		FROM ingredient_catalog c
+		WHERE c.id = $1`
+
+	var entry CatalogEntry
+	err := database.DB.QueryRow(ctx, query, id).Scan(&entry.ID, &entry.Name, &entry.Category, &entry.Synonyms,
+		&entry.Allergens, &entry.AnimalSource)
+	if err != nil {
+		return CatalogEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// Finds the catalog entry a free-form ingredient name refers to. The name is
+// normalized and looked up by catalog name and synonym, preferring the most
+// specific match. Returns nil if no entry matches.
+func MatchCatalogEntry(ctx context.Context, name string) (*CatalogEntry, error) {
+	candidates := normalizer.Candidates(name)
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT terms.catalog_id
+		FROM unnest($1::text[]) WITH ORDINALITY AS candidates (term, rank)
+		JOIN (
+			SELECT id AS catalog_id, name AS term FROM ingredient_catalog
+			UNION ALL
+			SELECT catalog_id, synonym FROM ingredient_synonyms
+		) terms ON terms.term = candidates.term
+		ORDER BY candidates.rank
+		LIMIT 1`
+
+	var id int64
+	err := database.DB.QueryRow(ctx, query, candidates).Scan(&id)
+	if err != nil && err == pgx.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entry, err := FindCatalogEntry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// Shows how a free-form ingredient name would be normalized and which
+// catalog entry it would be linked to.
+func SuggestCatalogEntry(ctx context.Context, name string) (CatalogSuggestion, error) {
+	entry, err := MatchCatalogEntry(ctx, name)
+	if err != nil {
+		return CatalogSuggestion{}, err
+	}
+
+	return CatalogSuggestion{
+		Input:      name,
+		Normalized: normalizer.Normalize(name),
+		Entry:      entry,
+	}, nil
+}
+
+// Links ingredients that have never been matched to the catalog, such as
+// those loaded from seed.sql or added before their catalog entry existed,
+// and classifies their recipes again. Manual links are left alone. Run when
+// the server starts.
+func LinkUnmatchedIngredients(ctx context.Context) error {
+	query := `SELECT id, name FROM ingredients WHERE catalog_id IS NULL AND NOT catalog_locked`
+
+	rows, err := database.DB.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	unmatched, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Ingredient, error) {
+		var ingredient Ingredient
+		err := row.Scan(&ingredient.ID, &ingredient.Name)
+		return ingredient, err
+	})
+	if err != nil {
+		return err
+	}
+
+	recipeIds := []int64{}
+	for _, ingredient := range unmatched {
+		recipeId, linked, err := relinkIngredient(ctx, ingredient.ID, ingredient.Name)
+		if err != nil {
+			return err
+		}
+
+		if linked && !slices.Contains(recipeIds, recipeId) {
+			recipeIds = append(recipeIds, recipeId)
+		}
+	}
+
+	for _, recipeId := range recipeIds {
+		err = classifyRecipe(ctx, recipeId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Manually links an ingredient to a catalog entry. A manual link is kept
+// when the recipe is edited or synced. A nil catalog ID removes the manual link and
+// lets the normalizer choose the entry again. The user must be able to edit
+// the ingredient's recipe.
+func SetIngredientCatalogEntry(ctx context.Context, ingredientId int64, catalogId *int64) (Ingredient, error) {
+	var recipeId int64
+	var name string
+	err := database.DB.QueryRow(ctx, `SELECT recipe_id, name FROM ingredients WHERE id = $1`, ingredientId).Scan(&recipeId, &name)
+	if err != nil {
+		return Ingredient{}, err
+	}
+
+	err = authorizeRecipe(ctx, recipeId, RoleEditor)
+	if err != nil {
+		return Ingredient{}, err
+	}
+
+	locked := catalogId != nil
+	if catalogId == nil {
+		entry, err := MatchCatalogEntry(ctx, name)
+		if err != nil {
+			return Ingredient{}, err
+		}
+
+		if entry != nil {
+			catalogId = &entry.ID
+		}
+	} else {
+		// Make sure the entry exists before linking to it
+		_, err = FindCatalogEntry(ctx, *catalogId)
+		if err != nil {
+			return Ingredient{}, err
+		}
+	}
+
+	query := `UPDATE ingredients SET catalog_id = $1, catalog_locked = $2 WHERE id = $3
+		RETURNING id, name, recipe_id, quantity, unit, catalog_id`
+
+	var ingredient Ingredient
+	err = database.DB.QueryRow(ctx, query, catalogId, locked, ingredientId).Scan(&ingredient.ID, &ingredient.Name,
+		&ingredient.RecipeID, &ingredient.Quantity, &ingredient.Unit, &ingredient.CatalogID)
+	if err != nil {
+		return Ingredient{}, err
+	}
+
+	// The new link may change which allergens and diets the recipe has
+	err = classifyRecipe(ctx, recipeId)
+	if err != nil {
+		return Ingredient{}, err
+	}
+
+	publishRecipeEvent(ctx, events.RecipeUpdated, recipeId)
+
+	return ingredient, nil
+}
+
+// Helper Functions
+
+// Links an ingredient to the catalog entry its name matches, such as after it
+// is renamed. Ingredients linked manually keep their link. Returns the
+// ingredient's recipe and whether it is now linked to an entry.
+func relinkIngredient(ctx context.Context, ingredientId int64, name string) (int64, bool, error) {
+	entry, err := MatchCatalogEntry(ctx, name)
+	if err != nil {
+		return -1, false, err
+	}
+
+	var catalogId *int64
+	if entry != nil {
+		catalogId = &entry.ID
+	}
+
+	query := `UPDATE ingredients SET catalog_id = $1 WHERE id = $2 AND NOT catalog_locked RETURNING recipe_id`
+
+	var recipeId int64
+	err = database.DB.QueryRow(ctx, query, catalogId, ingredientId).Scan(&recipeId)
+	if err != nil && err == pgx.ErrNoRows {
+		return -1, false, nil
+	} else if err != nil {
+		return -1, false, err
+	}
+
+	return recipeId, catalogId != nil, nil
+}
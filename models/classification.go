@@ -0,0 +1,286 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
+)
+
+// The 14 major allergens that must be declared on food labels.
+var Allergens = []string{
+	"celery", "crustaceans", "eggs", "fish", "gluten", "lupin", "milk",
+	"molluscs", "mustard", "peanuts", "sesame", "soybeans", "sulphites", "tree-nuts",
+}
+
+// Diets a recipe can be classified as, with the allergens each one rules out.
+// Diets that depend on where the ingredients come from are handled by
+// fitsDiet.
+var dietAllergens = map[string][]string{
+	"vegan":       {},
+	"vegetarian":  {},
+	"pescatarian": {},
+	"gluten-free": {"gluten"},
+	"dairy-free":  {"milk"},
+	"egg-free":    {"eggs"},
+	"nut-free":    {"peanuts", "tree-nuts"},
+}
+
+// Animal sources of catalog entries, from least to most restrictive.
+var animalSources = map[string]int{
+	"none":    0,
+	"product": 1,
+	"fish":    2,
+	"meat":    3,
+}
+
+// The most restrictive animal source each diet allows.
+var dietAnimalSources = map[string]string{
+	"vegan":       "none",
+	"vegetarian":  "product",
+	"pescatarian": "fish",
+}
+
+var ErrInvalidAllergen = errors.New("unknown allergen")
+var ErrInvalidDiet = errors.New("unknown diet")
+
+// Classification lists the allergens a recipe contains and the diets it fits.
+// Diets are only derived when every ingredient is linked to the catalog, so
+// ingredients that are not are listed as unclassified. An overridden
+// classification was set by a user and is no longer derived.
+type Classification struct {
+	Allergens    []string `json:"allergens"`
+	Diets        []string `json:"diets"`
+	Unclassified []string `json:"unclassified"`
+	Overridden   bool     `json:"overridden"`
+}
+
+// Catalog data of a recipe ingredient, as needed to classify it.
+type classifiedIngredient struct {
+	ID           int64
+	CatalogID    *int64
+	Name         string
+	Linked       bool
+	Allergens    []string
+	AnimalSource string
+}
+
+// Derives a classification from a recipe's ingredients.
+func classify(ingredients []classifiedIngredient) Classification {
+	classification := Classification{
+		Allergens:    []string{},
+		Diets:        []string{},
+		Unclassified: []string{},
+	}
+
+	for _, ingredient := range ingredients {
+		if !ingredient.Linked {
+			classification.Unclassified = append(classification.Unclassified, ingredient.Name)
+			continue
+		}
+
+		for _, allergen := range ingredient.Allergens {
+			if !slices.Contains(classification.Allergens, allergen) {
+				classification.Allergens = append(classification.Allergens, allergen)
+			}
+		}
+	}
+
+	// Without knowing every ingredient, no diet can be promised
+	if len(classification.Unclassified) == 0 {
+		for diet := range dietAllergens {
+			if fitsDiet(diet, ingredients) {
+				classification.Diets = append(classification.Diets, diet)
+			}
+		}
+	}
+
+	sort.Strings(classification.Allergens)
+	sort.Strings(classification.Diets)
+	return classification
+}
+
+// Derives the classification of a recipe from its ingredients and stores it,
+// unless a user has overridden it.
+func classifyRecipe(ctx context.Context, recipeId int64) error {
+	ingredients, err := findClassifiedIngredients(ctx, recipeId)
+	if err != nil {
+		return err
+	}
+
+	classification := classify(ingredients)
+
+	query := `UPDATE recipes SET allergens = $1, diets = $2, unclassified = $3,
+			classified_at = COALESCE(classified_at, now())
+		WHERE id = $4 AND NOT classification_overridden`
+
+	_, err = database.DB.Exec(ctx, query, classification.Allergens, classification.Diets, classification.Unclassified, recipeId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Classifies the recipes that have never been classified, such as those
+// loaded from seed.sql or created before classification existed. Run when
+// the server starts.
+func ClassifyPendingRecipes(ctx context.Context) error {
+	query := `SELECT id FROM recipes WHERE classified_at IS NULL AND NOT classification_overridden`
+
+	rows, err := database.DB.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	recipeIds, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return err
+	}
+
+	for _, recipeId := range recipeIds {
+		err = classifyRecipe(ctx, recipeId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replaces the derived classification of a recipe with one set by the user.
+// The user must be able to edit the recipe.
+func OverrideClassification(ctx context.Context, recipeId int64, classification Classification) error {
+	err := authorizeRecipe(ctx, recipeId, RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	err = validateClassification(classification)
+	if err != nil {
+		return err
+	}
+
+	allergens, diets := slices.Clone(classification.Allergens), slices.Clone(classification.Diets)
+	if allergens == nil {
+		allergens = []string{}
+	}
+	if diets == nil {
+		diets = []string{}
+	}
+	sort.Strings(allergens)
+	sort.Strings(diets)
+
+	query := `UPDATE recipes SET allergens = $1, diets = $2, unclassified = '{}', classification_overridden = TRUE,
+			classified_at = COALESCE(classified_at, now())
+		WHERE id = $3`
+
+	_, err = database.DB.Exec(ctx, query, slices.Compact(allergens), slices.Compact(diets), recipeId)
+	if err != nil {
+		return err
+	}
+
+	publishRecipeEvent(ctx, events.RecipeUpdated, recipeId)
+
+	return nil
+}
+
+// Removes a user's override so that the classification is derived from the
+// ingredients again.
+func ResetClassification(ctx context.Context, recipeId int64) error {
+	err := authorizeRecipe(ctx, recipeId, RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(ctx, `UPDATE recipes SET classification_overridden = FALSE WHERE id = $1`, recipeId)
+	if err != nil {
+		return err
+	}
+
+	err = classifyRecipe(ctx, recipeId)
+	if err != nil {
+		return err
+	}
+
+	publishRecipeEvent(ctx, events.RecipeUpdated, recipeId)
+
+	return nil
+}
+
+// Helper Functions
+
+// Queries a recipe's ingredients along with the catalog data needed to
+// classify them.
+func findClassifiedIngredients(ctx context.Context, recipeId int64) ([]classifiedIngredient, error) {
+	query := `SELECT i.id, i.catalog_id, i.name, c.id IS NOT NULL, COALESCE(c.allergens, '{}'), COALESCE(c.animal_source, 'none')
+		FROM ingredients i
+		LEFT JOIN ingredient_catalog c ON c.id = i.catalog_id
+		WHERE i.recipe_id = $1
+		ORDER BY i.id`
+
+	rows, err := database.DB.Query(ctx, query, recipeId)
+	if err != nil {
+		return []classifiedIngredient{}, err
+	}
+	defer rows.Close()
+
+	ingredients := []classifiedIngredient{}
+	for rows.Next() {
+		var ingredient classifiedIngredient
+
+		err = rows.Scan(&ingredient.ID, &ingredient.CatalogID, &ingredient.Name, &ingredient.Linked,
+			&ingredient.Allergens, &ingredient.AnimalSource)
+		if err != nil {
+			return []classifiedIngredient{}, err
+		}
+
+		ingredients = append(ingredients, ingredient)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []classifiedIngredient{}, err
+	}
+
+	return ingredients, nil
+}
+
+// Reports whether every ingredient fits a diet.
+func fitsDiet(diet string, ingredients []classifiedIngredient) bool {
+	maxSource, restrictsSource := dietAnimalSources[diet]
+
+	for _, ingredient := range ingredients {
+		if restrictsSource && animalSources[ingredient.AnimalSource] > animalSources[maxSource] {
+			return false
+		}
+
+		for _, allergen := range dietAllergens[diet] {
+			if slices.Contains(ingredient.Allergens, allergen) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Checks that a classification only names known allergens and diets.
+func validateClassification(classification Classification) error {
+	for _, allergen := range classification.Allergens {
+		if !slices.Contains(Allergens, allergen) {
+			return ErrInvalidAllergen
+		}
+	}
+
+	for _, diet := range classification.Diets {
+		if _, ok := dietAllergens[diet]; !ok {
+			return ErrInvalidDiet
+		}
+	}
+
+	return nil
+}
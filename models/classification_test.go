@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	flour := classifiedIngredient{Name: "flour", Linked: true, Allergens: []string{"gluten"}, AnimalSource: "none"}
+	butter := classifiedIngredient{Name: "butter", Linked: true, Allergens: []string{"milk"}, AnimalSource: "product"}
+	salmon := classifiedIngredient{Name: "salmon", Linked: true, Allergens: []string{"fish"}, AnimalSource: "fish"}
+	beef := classifiedIngredient{Name: "beef", Linked: true, AnimalSource: "meat"}
+	rice := classifiedIngredient{Name: "rice", Linked: true, AnimalSource: "none"}
+	mystery := classifiedIngredient{Name: "mystery spice"}
+
+	tests := []struct {
+		name         string
+		ingredients  []classifiedIngredient
+		allergens    []string
+		diets        []string
+		unclassified []string
+	}{
+		{
+			name:         "no ingredients fits every diet",
+			ingredients:  nil,
+			allergens:    []string{},
+			diets:        []string{"dairy-free", "egg-free", "gluten-free", "nut-free", "pescatarian", "vegan", "vegetarian"},
+			unclassified: []string{},
+		},
+		{
+			name:         "plant ingredients",
+			ingredients:  []classifiedIngredient{rice, flour},
+			allergens:    []string{"gluten"},
+			diets:        []string{"dairy-free", "egg-free", "nut-free", "pescatarian", "vegan", "vegetarian"},
+			unclassified: []string{},
+		},
+		{
+			name:         "animal products rule out vegan",
+			ingredients:  []classifiedIngredient{flour, butter},
+			allergens:    []string{"gluten", "milk"},
+			diets:        []string{"egg-free", "nut-free", "pescatarian", "vegetarian"},
+			unclassified: []string{},
+		},
+		{
+			name:         "fish is pescatarian only",
+			ingredients:  []classifiedIngredient{salmon, rice},
+			allergens:    []string{"fish"},
+			diets:        []string{"dairy-free", "egg-free", "gluten-free", "nut-free", "pescatarian"},
+			unclassified: []string{},
+		},
+		{
+			name:         "meat rules out every animal source diet",
+			ingredients:  []classifiedIngredient{beef, butter},
+			allergens:    []string{"milk"},
+			diets:        []string{"egg-free", "gluten-free", "nut-free"},
+			unclassified: []string{},
+		},
+		{
+			name:         "allergens are listed once",
+			ingredients:  []classifiedIngredient{flour, flour},
+			allergens:    []string{"gluten"},
+			diets:        []string{"dairy-free", "egg-free", "nut-free", "pescatarian", "vegan", "vegetarian"},
+			unclassified: []string{},
+		},
+		{
+			name:         "unlinked ingredient promises no diet",
+			ingredients:  []classifiedIngredient{rice, mystery, butter},
+			allergens:    []string{"milk"},
+			diets:        []string{},
+			unclassified: []string{"mystery spice"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := classify(test.ingredients)
+			if !slices.Equal(got.Allergens, test.allergens) || !slices.Equal(got.Diets, test.diets) ||
+				!slices.Equal(got.Unclassified, test.unclassified) {
+				t.Errorf("got %+v, want allergens %v, diets %v and unclassified %v",
+					got, test.allergens, test.diets, test.unclassified)
+			}
+		})
+	}
+}
+
+func TestFitsDiet(t *testing.T) {
+	tests := []struct {
+		diet       string
+		ingredient classifiedIngredient
+		want       bool
+	}{
+		{"vegan", classifiedIngredient{AnimalSource: "none"}, true},
+		{"vegan", classifiedIngredient{AnimalSource: "product"}, false},
+		{"vegetarian", classifiedIngredient{AnimalSource: "product"}, true},
+		{"vegetarian", classifiedIngredient{AnimalSource: "fish"}, false},
+		{"pescatarian", classifiedIngredient{AnimalSource: "fish"}, true},
+		{"pescatarian", classifiedIngredient{AnimalSource: "meat"}, false},
+		{"gluten-free", classifiedIngredient{AnimalSource: "meat"}, true},
+		{"gluten-free", classifiedIngredient{Allergens: []string{"gluten"}}, false},
+		{"nut-free", classifiedIngredient{Allergens: []string{"tree-nuts"}}, false},
+		{"nut-free", classifiedIngredient{Allergens: []string{"sesame"}}, true},
+	}
+
+	for _, test := range tests {
+		got := fitsDiet(test.diet, []classifiedIngredient{test.ingredient})
+		if got != test.want {
+			t.Errorf("fitsDiet(%q, %+v) = %v, want %v", test.diet, test.ingredient, got, test.want)
+		}
+	}
+}
+
+func TestValidateClassification(t *testing.T) {
+	tests := []struct {
+		name           string
+		classification Classification
+		err            error
+	}{
+		{"empty", Classification{}, nil},
+		{"known values", Classification{Allergens: []string{"milk", "tree-nuts"}, Diets: []string{"vegetarian"}}, nil},
+		{"unknown allergen", Classification{Allergens: []string{"nuts"}}, ErrInvalidAllergen},
+		{"unknown diet", Classification{Diets: []string{"keto"}}, ErrInvalidDiet},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateClassification(test.classification); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
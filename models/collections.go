@@ -0,0 +1,281 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+var ErrInvalidOrder = errors.New("recipe order must list every recipe in the collection exactly once")
+
+type Collection struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CoverImage  string    `json:"coverImage"`
+	RecipeIDs   []int64   `json:"recipeIds"`
+	UserID      int64     `json:"userId"`
+	HouseholdID *int64    `json:"householdId"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Queries all collections in the cookbook the request is scoped to.
+func ListCollections(ctx context.Context) ([]Collection, error) {
+	scope, scopeId, err := cookbookScope(ctx, "c", "$1")
+	if err != nil {
+		return []Collection{}, err
+	}
+
+	query := `SELECT c.id, c.name, c.description, c.cover_image, c.user_id, c.household_id, c.created_at,
+			ARRAY(SELECT recipe_id FROM collection_recipes WHERE collection_id = c.id ORDER BY position)
+		FROM collections c
+		WHERE ` + scope + `
+		ORDER BY c.name`
+
+	rows, err := database.DB.Query(ctx, query, scopeId)
+	if err != nil {
+		return []Collection{}, err
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		var collection Collection
+
+		err = rows.Scan(&collection.ID, &collection.Name, &collection.Description, &collection.CoverImage,
+			&collection.UserID, &collection.HouseholdID, &collection.CreatedAt, &collection.RecipeIDs)
+		if err != nil {
+			return []Collection{}, err
+		}
+
+		collections = append(collections, collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Collection{}, err
+	}
+
+	return collections, nil
+}
+
+// Queries a single collection with its ordered recipe IDs, as long as it
+// belongs to the cookbook the request is scoped to.
+func FindCollection(ctx context.Context, id int64) (Collection, error) {
+	scope, scopeId, err := cookbookScope(ctx, "c", "$2")
+	if err != nil {
+		return Collection{}, err
+	}
+
+	query := `SELECT c.id, c.name, c.description, c.cover_image, c.user_id, c.household_id, c.created_at,
+			ARRAY(SELECT recipe_id FROM collection_recipes WHERE collection_id = c.id ORDER BY position)
+		FROM collections c
+		WHERE c.id = $1 AND ` + scope
+
+	var collection Collection
+	err = database.DB.QueryRow(ctx, query, id, scopeId).Scan(&collection.ID, &collection.Name,
+		&collection.Description, &collection.CoverImage, &collection.UserID, &collection.HouseholdID,
+		&collection.CreatedAt, &collection.RecipeIDs)
+	if err != nil {
+		return Collection{}, err
+	}
+
+	return collection, nil
+}
+
+// Creates a collection in the cookbook the request is scoped to. Any recipe
+// IDs given are added in order.
+func CreateCollection(ctx context.Context, collection Collection) (int64, error) {
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	query := `INSERT INTO collections (user_id, household_id, name, description, cover_image) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	var id int64
+	err = database.DB.QueryRow(ctx, query, userId, householdId, collection.Name, collection.Description, collection.CoverImage).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	for _, recipeId := range collection.RecipeIDs {
+		err = AddCollectionRecipe(ctx, id, recipeId)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	return id, nil
+}
+
+// Updates a collection's name, description and cover image.
+func UpdateCollection(ctx context.Context, id int64, collection Collection) error {
+	_, err := FindCollection(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE collections SET name = $1, description = $2, cover_image = $3 WHERE id = $4`
+
+	_, err = database.DB.Exec(ctx, query, collection.Name, collection.Description, collection.CoverImage, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a collection. The recipes in it are not affected.
+func DeleteCollection(ctx context.Context, id int64) error {
+	_, err := FindCollection(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM collections WHERE id = $1`
+
+	_, err = database.DB.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Appends a recipe to the end of a collection. The user must be able to view
+// the recipe. Adding a recipe that is already in the collection does nothing.
+func AddCollectionRecipe(ctx context.Context, collectionId int64, recipeId int64) error {
+	_, err := FindCollection(ctx, collectionId)
+	if err != nil {
+		return err
+	}
+
+	err = authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO collection_recipes (collection_id, recipe_id, position)
+		SELECT $1, $2, COALESCE(MAX(position) + 1, 0) FROM collection_recipes WHERE collection_id = $1
+		ON CONFLICT DO NOTHING`
+
+	_, err = database.DB.Exec(ctx, query, collectionId, recipeId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Removes a recipe from a collection.
+func RemoveCollectionRecipe(ctx context.Context, collectionId int64, recipeId int64) error {
+	_, err := FindCollection(ctx, collectionId)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM collection_recipes WHERE collection_id = $1 AND recipe_id = $2`
+
+	tag, err := database.DB.Exec(ctx, query, collectionId, recipeId)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// Reorders the recipes in a collection. The new order must contain exactly
+// the recipes already in the collection.
+func ReorderCollection(ctx context.Context, collectionId int64, recipeIds []int64) error {
+	collection, err := FindCollection(ctx, collectionId)
+	if err != nil {
+		return err
+	}
+
+	if len(recipeIds) != len(collection.RecipeIDs) {
+		return ErrInvalidOrder
+	}
+
+	current := map[int64]bool{}
+	for _, recipeId := range collection.RecipeIDs {
+		current[recipeId] = true
+	}
+
+	for _, recipeId := range recipeIds {
+		if !current[recipeId] {
+			return ErrInvalidOrder
+		}
+
+		// Catch duplicates in the new order
+		current[recipeId] = false
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE collection_recipes SET position = $1 WHERE collection_id = $2 AND recipe_id = $3`
+
+	for position, recipeId := range recipeIds {
+		_, err = tx.Exec(ctx, query, position, collectionId, recipeId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Loads every recipe in a collection in order, with ingredients and tags.
+// Recipes the user can no longer view are skipped.
+func ExportCollection(ctx context.Context, collectionId int64) ([]Recipe, error) {
+	collection, err := FindCollection(ctx, collectionId)
+	if err != nil {
+		return []Recipe{}, err
+	}
+
+	recipes := []Recipe{}
+	for _, recipeId := range collection.RecipeIDs {
+		recipe, err := FindRecipe(ctx, recipeId)
+		if errors.Is(err, ErrForbidden) {
+			continue
+		} else if err != nil {
+			return []Recipe{}, err
+		}
+
+		recipes = append(recipes, recipe)
+	}
+
+	return recipes, nil
+}
+
+// Builds a priced shopping list from the ingredients of every recipe in a
+// collection.
+func CollectionShoppingList(ctx context.Context, collectionId int64) ([]ShoppingListItem, error) {
+	recipes, err := ExportCollection(ctx, collectionId)
+	if err != nil {
+		return []ShoppingListItem{}, err
+	}
+
+	var ingredients []Ingredient
+	for _, recipe := range recipes {
+		for _, ingredient := range recipe.Ingredients {
+			ingredient.RecipeID = recipe.ID
+			ingredients = append(ingredients, ingredient)
+		}
+	}
+
+	return priceShoppingList(ctx, AggregateIngredients(ingredients))
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// An entry in the current user's cook log, recorded each time they make a
+// recipe.
+type CookLogEntry struct {
+	ID         int64  `json:"id"`
+	RecipeID   int64  `json:"recipeId"`
+	RecipeName string `json:"recipeName"`
+	CookedOn   string `json:"cookedOn"`
+	Servings   int    `json:"servings"`
+	Rating     *int   `json:"rating"`
+	Notes      string `json:"notes"`
+}
+
+const cookLogColumns = `l.id, l.recipe_id, r.name, l.cooked_on::text, l.servings, l.rating, l.notes`
+
+// Queries the current user's cook log, most recent first. Empty dates leave
+// the range open on that side.
+func ListCookLog(ctx context.Context, from string, to string) ([]CookLogEntry, error) {
+	fromDate, err := optionalDate(from)
+	if err != nil {
+		return []CookLogEntry{}, err
+	}
+
+	toDate, err := optionalDate(to)
+	if err != nil {
+		return []CookLogEntry{}, err
+	}
+
+	if fromDate != nil && toDate != nil {
+		err = validateDateRange(from, to)
+		if err != nil {
+			return []CookLogEntry{}, err
+		}
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `SELECT ` + cookLogColumns + `
+		FROM cook_log l
+		JOIN recipes r ON r.id = l.recipe_id
+		WHERE l.user_id = $1 AND ($2::date IS NULL OR l.cooked_on >= $2) AND ($3::date IS NULL OR l.cooked_on <= $3)
+		ORDER BY l.cooked_on DESC, l.id DESC`
+
+	return queryCookLog(ctx, query, userId, fromDate, toDate)
+}
+
+// Queries the current user's cook log for a recipe, most recent first.
+func ListRecipeCookLog(ctx context.Context, recipeId int64) ([]CookLogEntry, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return []CookLogEntry{}, err
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `SELECT ` + cookLogColumns + `
+		FROM cook_log l
+		JOIN recipes r ON r.id = l.recipe_id
+		WHERE l.user_id = $1 AND l.recipe_id = $2
+		ORDER BY l.cooked_on DESC, l.id DESC`
+
+	return queryCookLog(ctx, query, userId, recipeId)
+}
+
+// Queries a single entry of the current user's cook log.
+func FindCookLogEntry(ctx context.Context, id int64) (CookLogEntry, error) {
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `SELECT ` + cookLogColumns + `
+		FROM cook_log l
+		JOIN recipes r ON r.id = l.recipe_id
+		WHERE l.id = $1 AND l.user_id = $2`
+
+	var entry CookLogEntry
+	err := database.DB.QueryRow(ctx, query, id, userId).Scan(&entry.ID, &entry.RecipeID, &entry.RecipeName,
+		&entry.CookedOn, &entry.Servings, &entry.Rating, &entry.Notes)
+	if err != nil {
+		return CookLogEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// Records that the current user made a recipe. The date defaults to today
+// and the servings to the recipe's servings.
+func CreateCookLogEntry(ctx context.Context, recipeId int64, entry CookLogEntry) (int64, error) {
+	recipe, err := FindRecipe(ctx, recipeId)
+	if err != nil {
+		return -1, err
+	}
+
+	if entry.CookedOn == "" {
+		entry.CookedOn = time.Now().Format(DateLayout)
+	}
+
+	if entry.Servings <= 0 {
+		entry.Servings = max(recipe.Servings, 1)
+	}
+
+	err = validateCookLogEntry(entry)
+	if err != nil {
+		return -1, err
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `INSERT INTO cook_log (recipe_id, user_id, cooked_on, servings, rating, notes)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	var id int64
+	err = database.DB.QueryRow(ctx, query, recipeId, userId, entry.CookedOn, entry.Servings, entry.Rating,
+		entry.Notes).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Updates an entry of the current user's cook log.
+func UpdateCookLogEntry(ctx context.Context, id int64, entry CookLogEntry) error {
+	previous, err := FindCookLogEntry(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if entry.CookedOn == "" {
+		entry.CookedOn = previous.CookedOn
+	}
+
+	if entry.Servings <= 0 {
+		entry.Servings = previous.Servings
+	}
+
+	err = validateCookLogEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE cook_log SET cooked_on = $1, servings = $2, rating = $3, notes = $4 WHERE id = $5`
+
+	_, err = database.DB.Exec(ctx, query, entry.CookedOn, entry.Servings, entry.Rating, entry.Notes, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Removes an entry from the current user's cook log.
+func DeleteCookLogEntry(ctx context.Context, id int64) error {
+	_, err := FindCookLogEntry(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(ctx, `DELETE FROM cook_log WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Suggests recipes in the cookbook the request is scoped to that the current
+// user has made before but not within the given number of days. The recipes
+// made most often come first, then those made longest ago.
+func ListForgottenRecipes(ctx context.Context, days int) ([]Recipe, error) {
+	recipes, err := ListRecipes(ctx, RecipeFilter{})
+	if err != nil {
+		return []Recipe{}, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days).Format(DateLayout)
+
+	forgotten := []Recipe{}
+	for _, recipe := range recipes {
+		// Dates in DateLayout sort chronologically as strings
+		if recipe.LastCooked != nil && *recipe.LastCooked < cutoff {
+			forgotten = append(forgotten, recipe)
+		}
+	}
+
+	sort.SliceStable(forgotten, func(i, j int) bool {
+		if forgotten[i].TimesCooked != forgotten[j].TimesCooked {
+			return forgotten[i].TimesCooked > forgotten[j].TimesCooked
+		}
+
+		return *forgotten[i].LastCooked < *forgotten[j].LastCooked
+	})
+
+	return forgotten, nil
+}
+
+// Helper Functions
+
+// Adds how often and when the current user last made a recipe.
+func loadCookStats(ctx context.Context, recipe *Recipe) error {
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `SELECT COUNT(*), MAX(cooked_on)::text FROM cook_log WHERE recipe_id = $1 AND user_id = $2`
+
+	return database.DB.QueryRow(ctx, query, recipe.ID, userId).Scan(&recipe.TimesCooked, &recipe.LastCooked)
+}
+
+// Runs a query selecting cookLogColumns and scans the rows.
+func queryCookLog(ctx context.Context, query string, args ...any) ([]CookLogEntry, error) {
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		return []CookLogEntry{}, err
+	}
+	defer rows.Close()
+
+	entries := []CookLogEntry{}
+	for rows.Next() {
+		var entry CookLogEntry
+
+		err = rows.Scan(&entry.ID, &entry.RecipeID, &entry.RecipeName, &entry.CookedOn, &entry.Servings,
+			&entry.Rating, &entry.Notes)
+		if err != nil {
+			return []CookLogEntry{}, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []CookLogEntry{}, err
+	}
+
+	return entries, nil
+}
+
+// Checks that a cook log entry has a valid date and rating.
+func validateCookLogEntry(entry CookLogEntry) error {
+	_, err := time.Parse(DateLayout, entry.CookedOn)
+	if err != nil {
+		return ErrInvalidDate
+	}
+
+	if entry.Rating != nil && (*entry.Rating < 1 || *entry.Rating > 5) {
+		return ErrInvalidRating
+	}
+
+	return nil
+}
+
+// Parses a date that may be left empty.
+func optionalDate(date string) (*string, error) {
+	if date == "" {
+		return nil, nil
+	}
+
+	_, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return nil, ErrInvalidDate
+	}
+
+	return &date, nil
+}
@@ -0,0 +1,399 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
+	"github.com/mjande/recipes-microservice/normalizer"
+)
+
+// Lowest similarity at which two recipes count as likely duplicates.
+const duplicateThreshold = 0.75
+
+// Words left out when comparing recipe names, because copies and imports
+// often add or drop them.
+var nameStopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "with": true, "my": true,
+	"recipe": true, "copy": true, "easy": true, "best": true,
+}
+
+var ErrMergeSameRecipe = errors.New("cannot merge a recipe into itself")
+var ErrDifferentCookbooks = errors.New("recipes must be in the same cookbook to be merged")
+
+// A recipe that is likely a duplicate of another. Similarity is the overall
+// score, made up of how alike the names are and how many ingredients the
+// recipes share.
+type DuplicateMatch struct {
+	RecipeID             int64   `json:"recipeId"`
+	Name                 string  `json:"name"`
+	Similarity           float64 `json:"similarity"`
+	NameSimilarity       float64 `json:"nameSimilarity"`
+	IngredientSimilarity float64 `json:"ingredientSimilarity"`
+}
+
+// A pair of recipes in a duplicate cluster that are likely duplicates of each
+// other.
+type DuplicatePair struct {
+	RecipeID             int64   `json:"recipeId"`
+	DuplicateID          int64   `json:"duplicateId"`
+	Similarity           float64 `json:"similarity"`
+	NameSimilarity       float64 `json:"nameSimilarity"`
+	IngredientSimilarity float64 `json:"ingredientSimilarity"`
+}
+
+// A group of recipes linked by likely duplicate pairs.
+type DuplicateCluster struct {
+	Recipes []Recipe        `json:"recipes"`
+	Pairs   []DuplicatePair `json:"pairs"`
+}
+
+// Groups the recipes in the cookbook the request is scoped to into clusters
+// of likely duplicates. Recipes without duplicates are left out.
+func ListDuplicateClusters(ctx context.Context) ([]DuplicateCluster, error) {
+	recipes, err := ListRecipesWithIngredients(ctx)
+	if err != nil {
+		return []DuplicateCluster{}, err
+	}
+
+	return clusterDuplicates(recipes), nil
+}
+
+// Finds the recipes in the cookbook the request is scoped to that are likely
+// duplicates of a recipe, most similar first.
+func FindDuplicates(ctx context.Context, id int64) ([]DuplicateMatch, error) {
+	recipe, err := FindRecipe(ctx, id)
+	if err != nil {
+		return []DuplicateMatch{}, err
+	}
+
+	recipes, err := ListRecipesWithIngredients(ctx)
+	if err != nil {
+		return []DuplicateMatch{}, err
+	}
+
+	matches := []DuplicateMatch{}
+	for _, other := range recipes {
+		if other.ID == recipe.ID {
+			continue
+		}
+
+		pair := compareRecipes(recipe, other)
+		if pair.Similarity >= duplicateThreshold {
+			matches = append(matches, DuplicateMatch{
+				RecipeID:             other.ID,
+				Name:                 other.Name,
+				Similarity:           pair.Similarity,
+				NameSimilarity:       pair.NameSimilarity,
+				IngredientSimilarity: pair.IngredientSimilarity,
+			})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+
+		return matches[i].RecipeID < matches[j].RecipeID
+	})
+
+	return matches, nil
+}
+
+// Merges the source recipe into the target. The target gains the source's
+// tags, photos and collection entries, and every user's notes, cook log,
+// ratings, favorites and meal plans for the source move to the target. A
+// user who rated both recipes keeps their rating of the target. Photos of
+// steps the target does not have become photos of the whole recipe. The
+// user must own the source and be able to edit the target.
+func MergeRecipes(ctx context.Context, targetId int64, sourceId int64) error {
+	if targetId == sourceId {
+		return ErrMergeSameRecipe
+	}
+
+	err := authorizeRecipe(ctx, targetId, RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	err = authorizeRecipe(ctx, sourceId, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	target, err := findRecipe(ctx, targetId)
+	if err != nil {
+		return err
+	}
+
+	source, err := findRecipe(ctx, sourceId)
+	if err != nil {
+		return err
+	}
+
+	// Tags belong to a cookbook, so they cannot move between cookbooks
+	if !sameCookbook(target, source) {
+		return ErrDifferentCookbooks
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	recipeQueries := []string{
+		`INSERT INTO recipe_tags (recipe_id, tag_id)
+			SELECT $1, tag_id FROM recipe_tags WHERE recipe_id = $2
+			ON CONFLICT DO NOTHING`,
+		`INSERT INTO collection_recipes (collection_id, recipe_id, position)
+			SELECT collection_id, $1, position FROM collection_recipes WHERE recipe_id = $2
+			ON CONFLICT DO NOTHING`,
+	}
+
+	for _, query := range recipeQueries {
+		_, err = tx.Exec(ctx, query, targetId, sourceId)
+		if err != nil {
+			return err
+		}
+	}
+
+	photoQuery := `UPDATE recipe_photos SET recipe_id = $1, step = CASE WHEN step <= $3 THEN step END
+		WHERE recipe_id = $2`
+
+	_, err = tx.Exec(ctx, photoQuery, targetId, sourceId, countSteps(target.Instructions))
+	if err != nil {
+		return err
+	}
+
+	// Every user's data moves with the merge
+	userQueries := []string{
+		`INSERT INTO recipe_preferences (recipe_id, user_id, favorite, rating)
+			SELECT $1, user_id, favorite, rating FROM recipe_preferences WHERE recipe_id = $2
+			ON CONFLICT (recipe_id, user_id) DO UPDATE
+			SET favorite = recipe_preferences.favorite OR EXCLUDED.favorite,
+				rating = COALESCE(recipe_preferences.rating, EXCLUDED.rating)`,
+		`UPDATE recipe_notes SET recipe_id = $1 WHERE recipe_id = $2`,
+		`UPDATE cook_log SET recipe_id = $1 WHERE recipe_id = $2`,
+		`UPDATE meal_plans SET recipe_id = $1 WHERE recipe_id = $2`,
+	}
+
+	for _, query := range userQueries {
+		_, err = tx.Exec(ctx, query, targetId, sourceId)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM recipes WHERE id = $1`, sourceId)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Refresh the target's derived classification now that the merge is done
+	err = classifyRecipe(ctx, targetId)
+	if err != nil {
+		return err
+	}
+
+	// Both recipes are in the target's cookbook
+	scope, err := findRecipeScope(ctx, targetId)
+	if err == nil {
+		events.Default.Publish(scope, events.RecipeDeleted, sourceId)
+		events.Default.Publish(scope, events.RecipeUpdated, targetId)
+	}
+
+	return nil
+}
+
+// Helper Functions
+
+// Counts the steps of a recipe's instructions, which are written one step
+// per line.
+func countSteps(instructions string) int {
+	steps := 0
+	for _, line := range strings.Split(instructions, "\n") {
+		if strings.TrimSpace(line) != "" {
+			steps++
+		}
+	}
+
+	return steps
+}
+
+// Finds every likely duplicate pair among recipes and groups the recipes
+// connected by them. Clusters are ordered by their lowest recipe id.
+func clusterDuplicates(recipes []Recipe) []DuplicateCluster {
+	// Union-find over recipe indexes
+	parents := make([]int, len(recipes))
+	for i := range parents {
+		parents[i] = i
+	}
+
+	var root func(i int) int
+	root = func(i int) int {
+		if parents[i] != i {
+			parents[i] = root(parents[i])
+		}
+
+		return parents[i]
+	}
+
+	pairs := []DuplicatePair{}
+	pairIndexes := []int{}
+	for i := range recipes {
+		for j := i + 1; j < len(recipes); j++ {
+			pair := compareRecipes(recipes[i], recipes[j])
+			if pair.Similarity < duplicateThreshold {
+				continue
+			}
+
+			pairs = append(pairs, pair)
+			pairIndexes = append(pairIndexes, i)
+			parents[root(j)] = root(i)
+		}
+	}
+
+	clustersByRoot := map[int]*DuplicateCluster{}
+	roots := []int{}
+	for p, pair := range pairs {
+		r := root(pairIndexes[p])
+
+		cluster, ok := clustersByRoot[r]
+		if !ok {
+			cluster = &DuplicateCluster{Recipes: []Recipe{}, Pairs: []DuplicatePair{}}
+			clustersByRoot[r] = cluster
+			roots = append(roots, r)
+		}
+
+		cluster.Pairs = append(cluster.Pairs, pair)
+	}
+
+	for i, recipe := range recipes {
+		if cluster, ok := clustersByRoot[root(i)]; ok {
+			cluster.Recipes = append(cluster.Recipes, recipe)
+		}
+	}
+
+	clusters := []DuplicateCluster{}
+	for _, r := range roots {
+		cluster := *clustersByRoot[r]
+		sort.SliceStable(cluster.Recipes, func(i, j int) bool {
+			return cluster.Recipes[i].ID < cluster.Recipes[j].ID
+		})
+
+		clusters = append(clusters, cluster)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Recipes[0].ID < clusters[j].Recipes[0].ID
+	})
+
+	return clusters
+}
+
+// Scores how likely two recipes are duplicates, from 0 to 1. Names and
+// ingredients count equally. Recipes without ingredients are compared by
+// name alone.
+func compareRecipes(a Recipe, b Recipe) DuplicatePair {
+	nameSimilarity := nameSimilarity(a.Name, b.Name)
+
+	keysA, keysB := ingredientKeys(a), ingredientKeys(b)
+	ingredientSimilarity := jaccard(keysA, keysB)
+
+	similarity := (nameSimilarity + ingredientSimilarity) / 2
+	if len(keysA) == 0 && len(keysB) == 0 {
+		similarity = nameSimilarity
+	}
+
+	return DuplicatePair{
+		RecipeID:             a.ID,
+		DuplicateID:          b.ID,
+		Similarity:           roundScore(similarity),
+		NameSimilarity:       roundScore(nameSimilarity),
+		IngredientSimilarity: roundScore(ingredientSimilarity),
+	}
+}
+
+// Scores how alike two recipe names are, from 0 to 1, after normalizing
+// them. Takes the better of the edit distance between the names and the
+// overlap of their words, so that both typos and reordered words match.
+func nameSimilarity(a string, b string) float64 {
+	wordsA, wordsB := nameWords(a), nameWords(b)
+	joinedA, joinedB := strings.Join(wordsA, " "), strings.Join(wordsB, " ")
+
+	longest := max(len([]rune(joinedA)), len([]rune(joinedB)))
+	if longest == 0 {
+		return 0
+	}
+
+	editSimilarity := 1 - float64(levenshtein(joinedA, joinedB))/float64(longest)
+
+	return max(editSimilarity, jaccard(wordsA, wordsB))
+}
+
+// Splits a recipe name into lower case, singular words, without punctuation
+// or stop words.
+func nameWords(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	words := []string{}
+	for _, field := range fields {
+		if nameStopWords[field] {
+			continue
+		}
+
+		words = append(words, normalizer.Singular(field))
+	}
+
+	return words
+}
+
+// Returns the number of single character insertions, deletions and
+// substitutions needed to turn one string into the other.
+func levenshtein(a string, b string) int {
+	runesA, runesB := []rune(a), []rune(b)
+
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(runesB)]
+}
+
+// Reports whether two recipes are in the same cookbook.
+func sameCookbook(a Recipe, b Recipe) bool {
+	if a.HouseholdID != nil || b.HouseholdID != nil {
+		return a.HouseholdID != nil && b.HouseholdID != nil && *a.HouseholdID == *b.HouseholdID
+	}
+
+	return a.UserID == b.UserID
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"stew", "stew", 0},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"crème", "creme", 1},
+	}
+
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"same name ignoring case", "Chocolate Cake", "chocolate cake", 1},
+		{"stop words are ignored", "The Best Chocolate Cake", "Chocolate Cake", 1},
+		{"reordered words", "Cake, Chocolate", "Chocolate Cake", 1},
+		{"plural words", "Pancakes", "pancake", 1},
+		{"typo", "Lasagna", "Lasagne", 1 - 1.0/7},
+		{"only stop words", "The", "A", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := nameSimilarity(test.a, test.b); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if got := nameSimilarity("Beef stew", "Lemon tart"); got >= duplicateThreshold {
+		t.Errorf("unrelated names scored %v", got)
+	}
+}
+
+func TestCountSteps(t *testing.T) {
+	tests := []struct {
+		instructions string
+		want         int
+	}{
+		{"", 0},
+		{"Mix everything", 1},
+		{"Mix\n\nBake\n  \nServe\n", 3},
+	}
+
+	for _, test := range tests {
+		if got := countSteps(test.instructions); got != test.want {
+			t.Errorf("countSteps(%q) = %d, want %d", test.instructions, got, test.want)
+		}
+	}
+}
+
+func TestClusterDuplicates(t *testing.T) {
+	batter := []Ingredient{{Name: "flour"}, {Name: "milk"}, {Name: "eggs"}}
+	stew := []Ingredient{{Name: "beef"}, {Name: "carrots"}}
+
+	recipes := []Recipe{
+		{ID: 1, Name: "Pancakes", Ingredients: batter},
+		{ID: 2, Name: "Pancake", Ingredients: batter},
+		{ID: 3, Name: "Beef stew", Ingredients: stew},
+		{ID: 4, Name: "Lemon tart"},
+		{ID: 5, Name: "Pancakes recipe", Ingredients: []Ingredient{{Name: "Flour"}, {Name: "milk"}, {Name: "egg"}}},
+		{ID: 6, Name: "Beef Stew", Ingredients: []Ingredient{{Name: "beef"}, {Name: "carrot"}}},
+	}
+
+	want := []struct {
+		recipeIds []int64
+		pairs     int
+	}{
+		{[]int64{1, 2, 5}, 3},
+		{[]int64{3, 6}, 1},
+	}
+
+	clusters := clusterDuplicates(recipes)
+	if len(clusters) != len(want) {
+		t.Fatalf("got %d clusters, want %d", len(clusters), len(want))
+	}
+
+	for i, expected := range want {
+		cluster := clusters[i]
+
+		recipeIds := []int64{}
+		for _, recipe := range cluster.Recipes {
+			recipeIds = append(recipeIds, recipe.ID)
+		}
+
+		if !slices.Equal(recipeIds, expected.recipeIds) || len(cluster.Pairs) != expected.pairs {
+			t.Errorf("cluster %d: got recipes %v with %d pairs, want %v with %d", i, recipeIds,
+				len(cluster.Pairs), expected.recipeIds, expected.pairs)
+		}
+	}
+}
+
+func TestClusterDuplicatesWithoutDuplicates(t *testing.T) {
+	recipes := []Recipe{{ID: 1, Name: "Beef stew"}, {ID: 2, Name: "Lemon tart"}}
+
+	if clusters := clusterDuplicates(recipes); len(clusters) != 0 {
+		t.Errorf("got %d clusters, want none", len(clusters))
+	}
+}
@@ -0,0 +1,420 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Roles a user can hold in a household. Household owners manage members and
+// have owner rights on household recipes, members may view and edit them.
+const HouseholdRoleMember = "member"
+
+var ErrInvalidHouseholdRole = errors.New("role must be either owner or member")
+var ErrLastHouseholdOwner = errors.New("a household must keep at least one owner")
+
+type Household struct {
+	ID        int64             `json:"id"`
+	Name      string            `json:"name"`
+	Role      string            `json:"role,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Members   []HouseholdMember `json:"members,omitempty"`
+}
+
+type HouseholdMember struct {
+	HouseholdID int64  `json:"householdId"`
+	UserID      int64  `json:"userId"`
+	Role        string `json:"role"`
+}
+
+// Queries the database for all households the current user belongs to.
+func ListHouseholds(ctx context.Context) ([]Household, error) {
+	userId := utils.ExtractUserIDFromContext(ctx)
+	query := `SELECT h.id, h.name, m.role, h.created_at
+		FROM households h
+		JOIN household_members m ON m.household_id = h.id
+		WHERE m.user_id = $1`
+
+	rows, err := database.DB.Query(ctx, query, userId)
+	if err != nil {
+		return []Household{}, err
+	}
+	defer rows.Close()
+
+	households := []Household{}
+	for rows.Next() {
+		var household Household
+
+		err = rows.Scan(&household.ID, &household.Name, &household.Role, &household.CreatedAt)
+		if err != nil {
+			return []Household{}, err
+		}
+
+		households = append(households, household)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Household{}, err
+	}
+
+	return households, nil
+}
+
+// Queries a household and its members. Only members may view a household.
+func FindHousehold(ctx context.Context, id int64) (Household, error) {
+	role, err := findHouseholdRole(ctx, strconv.FormatInt(id, 10))
+	if err != nil {
+		return Household{}, err
+	}
+
+	query := `SELECT id, name, created_at FROM households WHERE id = $1`
+
+	household := Household{Role: role}
+	err = database.DB.QueryRow(ctx, query, id).Scan(&household.ID, &household.Name, &household.CreatedAt)
+	if err != nil {
+		return Household{}, err
+	}
+
+	membersQuery := `SELECT household_id, user_id, role FROM household_members WHERE household_id = $1`
+
+	rows, err := database.DB.Query(ctx, membersQuery, id)
+	if err != nil {
+		return Household{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member HouseholdMember
+
+		err = rows.Scan(&member.HouseholdID, &member.UserID, &member.Role)
+		if err != nil {
+			return Household{}, err
+		}
+
+		household.Members = append(household.Members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return Household{}, err
+	}
+
+	return household, nil
+}
+
+// Creates a new household with the current user as its owner.
+func CreateHousehold(ctx context.Context, household Household) (int64, error) {
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO households (name) VALUES ($1) RETURNING id`
+
+	var id int64
+	err = tx.QueryRow(ctx, query, household.Name).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	memberQuery := `INSERT INTO household_members (household_id, user_id, role) VALUES ($1, $2, $3)`
+
+	_, err = tx.Exec(ctx, memberQuery, id, userId, RoleOwner)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, tx.Commit(ctx)
+}
+
+// Renames a household. Only household owners may update it.
+func UpdateHousehold(ctx context.Context, id int64, household Household) error {
+	err := authorizeHousehold(ctx, id, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE households SET name = $1 WHERE id = $2`
+
+	_, err = database.DB.Exec(ctx, query, household.Name, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a household. Its recipes return to the personal cookbooks of the
+// users who created them.
+func DeleteHousehold(ctx context.Context, id int64) error {
+	err := authorizeHousehold(ctx, id, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Return recipes to their creators first so their tags move with them
+	// instead of being deleted with the household's tags
+	recipesQuery := `UPDATE recipes SET household_id = NULL WHERE household_id = $1 RETURNING id`
+
+	rows, err := tx.Query(ctx, recipesQuery, id)
+	if err != nil {
+		return err
+	}
+
+	recipeIds, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return err
+	}
+
+	for _, recipeId := range recipeIds {
+		err = relinkRecipeTags(ctx, tx, recipeId)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `DELETE FROM households WHERE id = $1`
+
+	_, err = tx.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The recipes leave the household and appear in their creators' cookbooks
+	household := events.Scope{HouseholdID: strconv.FormatInt(id, 10)}
+	for _, recipeId := range recipeIds {
+		events.Default.Publish(household, events.RecipeDeleted, recipeId)
+	}
+	publishRecipeEvents(ctx, events.RecipeCreated, recipeIds)
+
+	return nil
+}
+
+// Adds a user to a household, or changes the role of an existing member.
+// Only household owners may manage members.
+func SetHouseholdMember(ctx context.Context, householdId int64, member HouseholdMember) (HouseholdMember, error) {
+	if member.Role != RoleOwner && member.Role != HouseholdRoleMember {
+		return HouseholdMember{}, ErrInvalidHouseholdRole
+	}
+
+	err := authorizeHousehold(ctx, householdId, RoleOwner)
+	if err != nil {
+		return HouseholdMember{}, err
+	}
+
+	if member.Role != RoleOwner {
+		err = checkRemainingOwners(ctx, householdId, member.UserID)
+		if err != nil {
+			return HouseholdMember{}, err
+		}
+	}
+
+	query := `INSERT INTO household_members (household_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (household_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING household_id, user_id, role`
+
+	row := database.DB.QueryRow(ctx, query, householdId, member.UserID, member.Role)
+
+	var saved HouseholdMember
+	err = row.Scan(&saved.HouseholdID, &saved.UserID, &saved.Role)
+	if err != nil {
+		return HouseholdMember{}, err
+	}
+
+	return saved, nil
+}
+
+// Removes a user from a household. Owners may remove any member, and any
+// member may leave on their own.
+func RemoveHouseholdMember(ctx context.Context, householdId int64, userId int64) error {
+	currentUserId := utils.ExtractUserIDFromContext(ctx)
+	if currentUserId != strconv.FormatInt(userId, 10) {
+		err := authorizeHousehold(ctx, householdId, RoleOwner)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := checkRemainingOwners(ctx, householdId, userId)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM household_members WHERE household_id = $1 AND user_id = $2`
+
+	_, err = database.DB.Exec(ctx, query, householdId, userId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Moves a recipe between cookbooks. A nil household moves the recipe into
+// the current user's personal cookbook. The user must own the recipe and be
+// a member of the household it is moved into.
+func MoveRecipe(ctx context.Context, recipeId int64, householdId *int64) error {
+	err := authorizeRecipe(ctx, recipeId, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	previous, err := findRecipeScope(ctx, recipeId)
+	if err != nil {
+		return err
+	}
+
+	if householdId != nil {
+		err = authorizeHousehold(ctx, *householdId, HouseholdRoleMember)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if householdId == nil {
+		query := `UPDATE recipes SET household_id = NULL, user_id = $1 WHERE id = $2`
+
+		_, err = tx.Exec(ctx, query, userId, recipeId)
+		if err != nil {
+			return err
+		}
+	} else {
+		query := `UPDATE recipes SET household_id = $1 WHERE id = $2`
+
+		_, err = tx.Exec(ctx, query, *householdId, recipeId)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Tags are per cookbook, so link the recipe to the new cookbook's tags
+	err = relinkRecipeTags(ctx, tx, recipeId)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The recipe leaves one cookbook and appears in the other
+	events.Default.Publish(previous, events.RecipeDeleted, recipeId)
+	publishRecipeEvent(ctx, events.RecipeCreated, recipeId)
+
+	return nil
+}
+
+// Helper Functions
+
+// Returns the current user's role in a household, or ErrForbidden if they
+// are not a member.
+func findHouseholdRole(ctx context.Context, householdId string) (string, error) {
+	userId := utils.ExtractUserIDFromContext(ctx)
+	query := `SELECT role FROM household_members WHERE household_id = $1 AND user_id = $2`
+
+	var role string
+	err := database.DB.QueryRow(ctx, query, householdId, userId).Scan(&role)
+	if err != nil && err == pgx.ErrNoRows {
+		return "", ErrForbidden
+	} else if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// Checks that the current user holds at least the given role in a household.
+func authorizeHousehold(ctx context.Context, householdId int64, minRole string) error {
+	role, err := findHouseholdRole(ctx, strconv.FormatInt(householdId, 10))
+	if err != nil {
+		return err
+	}
+
+	if minRole == RoleOwner && role != RoleOwner {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// Checks that a household still has an owner if the given user stops being
+// one.
+func checkRemainingOwners(ctx context.Context, householdId int64, userId int64) error {
+	query := `SELECT COUNT(*) FROM household_members WHERE household_id = $1 AND role = 'owner' AND user_id <> $2`
+
+	var owners int
+	err := database.DB.QueryRow(ctx, query, householdId, userId).Scan(&owners)
+	if err != nil {
+		return err
+	}
+
+	if owners == 0 {
+		return ErrLastHouseholdOwner
+	}
+
+	return nil
+}
+
+// Returns a SQL condition restricting rows of a cookbook-owned table (such as
+// recipes or tags) with the given alias to the cookbook the request is
+// scoped to, along with its argument. The condition uses the given
+// placeholder. Requests scoped to a household are checked for membership.
+func cookbookScope(ctx context.Context, alias string, placeholder string) (string, string, error) {
+	householdId := utils.ExtractHouseholdIDFromContext(ctx)
+	if householdId == "" {
+		userId := utils.ExtractUserIDFromContext(ctx)
+		return alias + ".user_id = " + placeholder + " AND " + alias + ".household_id IS NULL", userId, nil
+	}
+
+	_, err := findHouseholdRole(ctx, householdId)
+	if err != nil {
+		return "", "", err
+	}
+
+	return alias + ".household_id = " + placeholder, householdId, nil
+}
+
+// Returns the household new rows should be created in, or nil for the
+// user's personal cookbook. Requests scoped to a household are checked for
+// membership.
+func scopedHouseholdID(ctx context.Context) (*string, error) {
+	householdId := utils.ExtractHouseholdIDFromContext(ctx)
+	if householdId == "" {
+		return nil, nil
+	}
+
+	_, err := findHouseholdRole(ctx, householdId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &householdId, nil
+}
@@ -2,28 +2,34 @@ package models
 
 import (
 	"context"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/mjande/recipes-microservice/database"
-	"github.com/mjande/recipes-microservice/utils"
 )
 
 type Ingredient struct {
-	ID       int64   `json:"id"`
-	UserId   string  `json:"userId"`
-	Name     string  `json:"name"`
-	RecipeID int64   `json:"recipeId"`
-	Quantity float32 `json:"quantity"`
-	Unit     string  `json:"unit"`
+	ID        int64   `json:"id"`
+	UserId    string  `json:"userId"`
+	Name      string  `json:"name"`
+	RecipeID  int64   `json:"recipeId"`
+	Quantity  float32 `json:"quantity"`
+	Unit      string  `json:"unit"`
+	CatalogID *int64  `json:"catalogId"`
 }
 
-// Queries the database for all unique ingredients used in any recipe.
+// Queries the database for all unique ingredients used in any recipe of the
+// personal or household cookbook the request is scoped to.
 func ListIngredients(ctx context.Context) ([]string, error) {
-	userId := utils.ExtractUserIDFromContext(ctx)
-	query := `SELECT name FROM ingredients WHERE user_id = $1`
+	scope, scopeId, err := cookbookScope(ctx, "r", "$1")
+	if err != nil {
+		return []string{}, err
+	}
+
+	query := `SELECT i.name FROM ingredients i JOIN recipes r ON r.id = i.recipe_id WHERE ` + scope
 
 	// Execute query
-	rows, err := database.DB.Query(ctx, query, userId)
+	rows, err := database.DB.Query(ctx, query, scopeId)
 	if err != nil {
 		return []string{}, err
 	}
@@ -57,7 +63,7 @@ func ListIngredients(ctx context.Context) ([]string, error) {
 
 // Queries all ingredients for a given recipe.
 func ListIngredientsByRecipe(ctx context.Context, recipeId int64) ([]Ingredient, error) {
-	query := `SELECT id, name, recipe_id, quantity, unit FROM ingredients WHERE recipe_id = $1`
+	query := `SELECT id, name, recipe_id, quantity, unit, catalog_id FROM ingredients WHERE recipe_id = $1`
 
 	rows, err := database.DB.Query(ctx, query, recipeId)
 	if err != nil && err == pgx.ErrNoRows {
@@ -71,7 +77,7 @@ func ListIngredientsByRecipe(ctx context.Context, recipeId int64) ([]Ingredient,
 	for rows.Next() {
 		var ingredient Ingredient
 
-		err = rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.RecipeID, &ingredient.Quantity, &ingredient.Unit)
+		err = rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.RecipeID, &ingredient.Quantity, &ingredient.Unit, &ingredient.CatalogID)
 		if err != nil {
 			return []Ingredient{}, err
 		}
@@ -90,6 +96,11 @@ func ListIngredientsByRecipe(ctx context.Context, recipeId int64) ([]Ingredient,
 func ListIngredientsByMultipleRecipes(ctx context.Context, recipeIds []int64) ([]Ingredient, error) {
 	var ingredients []Ingredient
 	for _, recipeId := range recipeIds {
+		err := authorizeRecipe(ctx, recipeId, RoleViewer)
+		if err != nil {
+			return ingredients, err
+		}
+
 		recipeIngredients, err := ListIngredientsByRecipe(ctx, recipeId)
 		if err != nil {
 			return ingredients, err
@@ -118,18 +129,104 @@ func FindIngredient(ctx context.Context, name string, recipeId int64) (Ingredien
 	return ingredient, nil
 }
 
-// Creates a new ingredient in the database
+// Creates a new ingredient in the database and links it to the matching
+// catalog entry. The ingredient belongs to the recipe's owner, even when it is
+// added by an editor the recipe is shared with.
 func CreateIngredient(ctx context.Context, ingredient Ingredient) (int64, error) {
-	userId := utils.ExtractUserIDFromContext(ctx)
-	query := `INSERT INTO ingredients (name, user_id, recipe_id, quantity, unit) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	entry, err := MatchCatalogEntry(ctx, ingredient.Name)
+	if err != nil {
+		return -1, err
+	}
 
-	row := database.DB.QueryRow(ctx, query, ingredient.Name, userId, ingredient.RecipeID, ingredient.Quantity, ingredient.Unit)
+	var catalogId *int64
+	if entry != nil {
+		catalogId = &entry.ID
+	}
+
+	query := `INSERT INTO ingredients (name, user_id, recipe_id, quantity, unit, catalog_id)
+		SELECT $1, user_id, id, $3, $4, $5 FROM recipes WHERE id = $2 RETURNING id`
+
+	row := database.DB.QueryRow(ctx, query, ingredient.Name, ingredient.RecipeID, ingredient.Quantity, ingredient.Unit, catalogId)
 
 	var id int64
-	err := row.Scan(&id)
+	err = row.Scan(&id)
 	if err != nil {
 		return -1, err
 	}
 
 	return id, nil
 }
+
+type IngredientSuggestion struct {
+	Name   string `json:"name"`
+	Uses   int64  `json:"uses"`
+	Source string `json:"source"`
+}
+
+// Suggests ingredient names for autocompletion. Names come from the
+// ingredients used in the cookbook the request is scoped to and from the
+// ingredient catalog. Prefix matches rank first, then names the user has
+// used most often, then fuzzy trigram matches by similarity.
+func SuggestIngredients(ctx context.Context, search string, limit int) ([]IngredientSuggestion, error) {
+	search = strings.Join(strings.Fields(strings.ToLower(search)), " ")
+	if search == "" {
+		return []IngredientSuggestion{}, nil
+	}
+
+	scope, scopeId, err := cookbookScope(ctx, "r", "$1")
+	if err != nil {
+		return []IngredientSuggestion{}, err
+	}
+
+	query := `WITH history AS (
+			SELECT lower(i.name) AS name, COUNT(*) AS uses
+			FROM ingredients i
+			JOIN recipes r ON r.id = i.recipe_id
+			WHERE ` + scope + ` AND (lower(i.name) LIKE $4 || '%' OR lower(i.name) % $2)
+			GROUP BY lower(i.name)
+		), terms AS (
+			SELECT name, uses, 'history' AS source FROM history
+			UNION ALL
+			SELECT c.name, 0, 'catalog' FROM ingredient_catalog c
+			WHERE (c.name LIKE $4 || '%' OR c.name % $2)
+				AND NOT EXISTS (SELECT 1 FROM history h WHERE h.name = c.name)
+		)
+		SELECT name, uses, source
+		FROM terms
+		ORDER BY name LIKE $4 || '%' DESC, uses DESC, similarity(name, $2) DESC, name
+		LIMIT $3`
+
+	// Wildcards typed by the user match themselves in prefix matches
+	rows, err := database.DB.Query(ctx, query, scopeId, search, limit, escapeLike(search))
+	if err != nil {
+		return []IngredientSuggestion{}, err
+	}
+	defer rows.Close()
+
+	suggestions := []IngredientSuggestion{}
+	for rows.Next() {
+		var suggestion IngredientSuggestion
+
+		err = rows.Scan(&suggestion.Name, &suggestion.Uses, &suggestion.Source)
+		if err != nil {
+			return []IngredientSuggestion{}, err
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []IngredientSuggestion{}, err
+	}
+
+	return suggestions, nil
+}
+
+// Helper Functions
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Escapes the wildcards of a LIKE pattern, so that text matches only itself.
+func escapeLike(text string) string {
+	return likeEscaper.Replace(text)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"garlic", "garlic"},
+		{"%", `\%`},
+		{"half_and_half", `half\_and\_half`},
+		{`100% c\o`, `100\% c\\o`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := escapeLike(test.text); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
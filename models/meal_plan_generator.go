@@ -0,0 +1,342 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/nutrition"
+)
+
+// Longest date range a meal plan can be generated for, in days.
+const maxGeneratedDays = 31
+
+// Most partial plans the solver tries before giving up.
+const maxSolverSteps = 200_000
+
+// Catalog categories whose ingredients make up the main part of a dish, in
+// order of preference when picking a recipe's main ingredient.
+var mainIngredientCategories = [][]string{
+	{"meat", "seafood", "legumes"},
+	{"produce", "grains"},
+}
+
+var ErrRangeTooLong = errors.New("meal plans can be generated for at most 31 days")
+var ErrNoFeasiblePlan = errors.New("no meal plan satisfies the constraints")
+
+// Constraints for generating a meal plan. Meals are the slots to fill each
+// day and default to dinner. Servings defaults to each recipe's servings.
+// MaxWeeknightTime limits the cooking time, in minutes, of dinners from
+// Monday to Friday; zero leaves it unlimited. Seed makes the plan
+// reproducible.
+type MealPlanConstraints struct {
+	From             string   `json:"from"`
+	To               string   `json:"to"`
+	Meals            []string `json:"meals"`
+	Servings         int      `json:"servings"`
+	Diets            []string `json:"diets"`
+	ExcludeAllergens []string `json:"excludeAllergens"`
+	MaxWeeknightTime int      `json:"maxWeeknightTime"`
+	Seed             *uint64  `json:"seed"`
+}
+
+// A proposed meal plan that has not been saved, with the shopping list for
+// all of its meals.
+type GeneratedMealPlan struct {
+	Plan         []MealPlan         `json:"plan"`
+	ShoppingList []ShoppingListItem `json:"shoppingList"`
+}
+
+// A meal slot to fill in a generated plan.
+type planSlot struct {
+	date string
+	meal string
+}
+
+// Proposes a meal plan from the recipes in the cookbook the request is
+// scoped to. Each slot gets a recipe that fits the diets and allergens, is
+// quick enough on weeknights, and whose main ingredient differs from the
+// meal before. Recipes are not repeated unless there are too few to fill
+// the plan. The plan is not saved.
+func GenerateMealPlan(ctx context.Context, constraints MealPlanConstraints) (GeneratedMealPlan, error) {
+	slots, err := planSlots(constraints)
+	if err != nil {
+		return GeneratedMealPlan{}, err
+	}
+
+	recipes, err := listRecipesWithIngredients(ctx, RecipeFilter{
+		Diets:            constraints.Diets,
+		ExcludeAllergens: constraints.ExcludeAllergens,
+	})
+	if err != nil {
+		return GeneratedMealPlan{}, err
+	}
+
+	mainIngredients, err := findMainIngredients(ctx, recipes)
+	if err != nil {
+		return GeneratedMealPlan{}, err
+	}
+
+	var random *rand.Rand
+	if constraints.Seed != nil {
+		random = rand.New(rand.NewPCG(*constraints.Seed, 0))
+	} else {
+		random = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+
+	candidates := make([][]int, len(slots))
+	for i, slot := range slots {
+		candidates[i] = slotCandidates(recipes, slot, constraints.MaxWeeknightTime)
+		random.Shuffle(len(candidates[i]), func(a, b int) {
+			candidates[i][a], candidates[i][b] = candidates[i][b], candidates[i][a]
+		})
+	}
+
+	// Try without repeating recipes first, then allow repeats
+	assignment, ok := solveMealPlan(candidates, mainIngredients, true)
+	if !ok {
+		assignment, ok = solveMealPlan(candidates, mainIngredients, false)
+	}
+
+	if !ok {
+		return GeneratedMealPlan{}, ErrNoFeasiblePlan
+	}
+
+	plan := make([]MealPlan, len(slots))
+	for i, slot := range slots {
+		recipe := recipes[assignment[i]]
+
+		servings := constraints.Servings
+		if servings <= 0 {
+			servings = max(recipe.Servings, 1)
+		}
+
+		plan[i] = MealPlan{
+			Date:        slot.date,
+			Meal:        slot.meal,
+			RecipeID:    recipe.ID,
+			RecipeName:  recipe.Name,
+			Servings:    servings,
+			HouseholdID: recipe.HouseholdID,
+		}
+	}
+
+	shoppingList, err := shoppingListForMealPlans(ctx, plan)
+	if err != nil {
+		return GeneratedMealPlan{}, err
+	}
+
+	return GeneratedMealPlan{Plan: plan, ShoppingList: shoppingList}, nil
+}
+
+// Helper Functions
+
+// Lists the slots to fill, by date and then in the order of MealSlots.
+func planSlots(constraints MealPlanConstraints) ([]planSlot, error) {
+	err := validateDateRange(constraints.From, constraints.To)
+	if err != nil {
+		return []planSlot{}, err
+	}
+
+	from, _ := time.Parse(DateLayout, constraints.From)
+	to, _ := time.Parse(DateLayout, constraints.To)
+	if to.Sub(from).Hours()/24 >= maxGeneratedDays {
+		return []planSlot{}, ErrRangeTooLong
+	}
+
+	meals := constraints.Meals
+	if len(meals) == 0 {
+		meals = []string{"dinner"}
+	}
+
+	for _, meal := range meals {
+		if !slices.Contains(MealSlots, meal) {
+			return []planSlot{}, ErrInvalidMeal
+		}
+	}
+
+	slots := []planSlot{}
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		for _, meal := range MealSlots {
+			if slices.Contains(meals, meal) {
+				slots = append(slots, planSlot{date: date.Format(DateLayout), meal: meal})
+			}
+		}
+	}
+
+	return slots, nil
+}
+
+// Returns the indexes of the recipes that can fill a slot. When any recipe
+// is tagged with the slot's meal, only those tagged recipes are used.
+// Weeknight dinners must have a known cooking time within the limit.
+func slotCandidates(recipes []Recipe, slot planSlot, maxWeeknightTime int) []int {
+	date, _ := time.Parse(DateLayout, slot.date)
+	weeknight := slot.meal == "dinner" && date.Weekday() >= time.Monday && date.Weekday() <= time.Friday
+
+	anyTagged := slices.ContainsFunc(recipes, func(recipe Recipe) bool {
+		return hasTags(recipe, []string{slot.meal})
+	})
+
+	candidates := []int{}
+	for i, recipe := range recipes {
+		if anyTagged && !hasTags(recipe, []string{slot.meal}) {
+			continue
+		}
+
+		if weeknight && maxWeeknightTime > 0 {
+			minutes, ok := parseCookingTime(recipe.CookingTime)
+			if !ok || minutes > maxWeeknightTime {
+				continue
+			}
+		}
+
+		candidates = append(candidates, i)
+	}
+
+	return candidates
+}
+
+// Assigns a recipe to every slot by backtracking search, so that no two
+// meals in a row share a main ingredient and, when unique is set, no recipe
+// is used twice. Returns the index of the recipe for each slot.
+func solveMealPlan(candidates [][]int, mainIngredients []string, unique bool) ([]int, bool) {
+	assignment := make([]int, len(candidates))
+	used := map[int]bool{}
+	steps := 0
+
+	var solve func(slot int) bool
+	solve = func(slot int) bool {
+		if slot == len(candidates) {
+			return true
+		}
+
+		for _, recipe := range candidates[slot] {
+			steps++
+			if steps > maxSolverSteps {
+				return false
+			}
+
+			if unique && used[recipe] {
+				continue
+			}
+
+			if slot > 0 && mainIngredients[recipe] != "" &&
+				mainIngredients[recipe] == mainIngredients[assignment[slot-1]] {
+				continue
+			}
+
+			// A recipe always shares its main ingredient with itself
+			if slot > 0 && recipe == assignment[slot-1] {
+				continue
+			}
+
+			assignment[slot] = recipe
+			used[recipe] = true
+
+			if solve(slot + 1) {
+				return true
+			}
+
+			used[recipe] = false
+		}
+
+		return false
+	}
+
+	if !solve(0) {
+		return []int{}, false
+	}
+
+	return assignment, true
+}
+
+// Picks the main ingredient of each recipe: the heaviest ingredient from the
+// most preferred of mainIngredientCategories, identified by its catalog
+// name. Recipes without such an ingredient have no main ingredient.
+func findMainIngredients(ctx context.Context, recipes []Recipe) ([]string, error) {
+	var ingredients []Ingredient
+	for _, recipe := range recipes {
+		ingredients = append(ingredients, recipe.Ingredients...)
+	}
+
+	categories, err := findCatalogCategories(ctx, ingredients)
+	if err != nil {
+		return []string{}, err
+	}
+
+	mainIngredients := make([]string, len(recipes))
+	for i, recipe := range recipes {
+		for _, preferred := range mainIngredientCategories {
+			var heaviest float64 = -1
+			for _, ingredient := range recipe.Ingredients {
+				if ingredient.CatalogID == nil {
+					continue
+				}
+
+				entry := categories[*ingredient.CatalogID]
+				if !slices.Contains(preferred, entry.Category) {
+					continue
+				}
+
+				var grams float64
+				if food, ok := nutrition.Lookup(entry.Name); ok {
+					grams, _, _ = nutrition.Grams(food, float64(ingredient.Quantity), ingredient.Unit)
+				}
+
+				if grams > heaviest {
+					heaviest = grams
+					mainIngredients[i] = entry.Name
+				}
+			}
+
+			if mainIngredients[i] != "" {
+				break
+			}
+		}
+	}
+
+	return mainIngredients, nil
+}
+
+// Queries the name and category of the catalog entries the ingredients are
+// linked to.
+func findCatalogCategories(ctx context.Context, ingredients []Ingredient) (map[int64]CatalogEntry, error) {
+	ids := []int64{}
+	for _, ingredient := range ingredients {
+		if ingredient.CatalogID != nil {
+			ids = append(ids, *ingredient.CatalogID)
+		}
+	}
+
+	entries := map[int64]CatalogEntry{}
+	if len(ids) == 0 {
+		return entries, nil
+	}
+
+	rows, err := database.DB.Query(ctx, `SELECT id, name, category FROM ingredient_catalog WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return map[int64]CatalogEntry{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry CatalogEntry
+
+		err = rows.Scan(&entry.ID, &entry.Name, &entry.Category)
+		if err != nil {
+			return map[int64]CatalogEntry{}, err
+		}
+
+		entries[entry.ID] = entry
+	}
+
+	if err = rows.Err(); err != nil {
+		return map[int64]CatalogEntry{}, err
+	}
+
+	return entries, nil
+}
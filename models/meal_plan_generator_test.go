@@ -0,0 +1,196 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSolveMealPlan(t *testing.T) {
+	tests := []struct {
+		name            string
+		candidates      [][]int
+		mainIngredients []string
+		unique          bool
+		want            []int
+		ok              bool
+	}{
+		{
+			name:            "no slots",
+			candidates:      [][]int{},
+			mainIngredients: []string{},
+			want:            []int{},
+			ok:              true,
+		},
+		{
+			name:            "takes first fitting recipe",
+			candidates:      [][]int{{0, 1}, {0, 1}},
+			mainIngredients: []string{"chicken", "beef"},
+			want:            []int{0, 1},
+			ok:              true,
+		},
+		{
+			name:            "skips shared main ingredient in a row",
+			candidates:      [][]int{{0}, {1, 2}},
+			mainIngredients: []string{"chicken", "chicken", "tofu"},
+			want:            []int{0, 2},
+			ok:              true,
+		},
+		{
+			name:            "recipes without main ingredient may follow each other",
+			candidates:      [][]int{{0}, {1}},
+			mainIngredients: []string{"", ""},
+			want:            []int{0, 1},
+			ok:              true,
+		},
+		{
+			name:            "never repeats a recipe in a row",
+			candidates:      [][]int{{0}, {0}},
+			mainIngredients: []string{""},
+			want:            []int{},
+			ok:              false,
+		},
+		{
+			name:            "repeats when not unique",
+			candidates:      [][]int{{0, 1}, {0, 1}, {0, 1}},
+			mainIngredients: []string{"chicken", "beef"},
+			want:            []int{0, 1, 0},
+			ok:              true,
+		},
+		{
+			name:            "unique runs out of recipes",
+			candidates:      [][]int{{0, 1}, {0, 1}, {0, 1}},
+			mainIngredients: []string{"chicken", "beef"},
+			unique:          true,
+			want:            []int{},
+			ok:              false,
+		},
+		{
+			name:            "backtracks out of a dead end",
+			candidates:      [][]int{{0, 1}, {2}, {3}},
+			mainIngredients: []string{"beef", "chicken", "fish", "chicken"},
+			unique:          true,
+			want:            []int{0, 2, 3},
+			ok:              true,
+		},
+		{
+			name:            "backtracks to free a recipe for a later slot",
+			candidates:      [][]int{{0, 1}, {0}},
+			mainIngredients: []string{"beef", "chicken"},
+			unique:          true,
+			want:            []int{1, 0},
+			ok:              true,
+		},
+		{
+			name:            "slot without candidates",
+			candidates:      [][]int{{0}, {}},
+			mainIngredients: []string{"beef"},
+			want:            []int{},
+			ok:              false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := solveMealPlan(test.candidates, test.mainIngredients, test.unique)
+			if !slices.Equal(got, test.want) || ok != test.ok {
+				t.Errorf("got %v %v, want %v %v", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestSolveMealPlanGivesUpOnLargeSearches(t *testing.T) {
+	// Every recipe shares its main ingredient, so no two slots in a row can
+	// be filled and the search would otherwise try every combination
+	recipes := []int{}
+	mainIngredients := []string{}
+	for i := range 50 {
+		recipes = append(recipes, i)
+		mainIngredients = append(mainIngredients, "chicken")
+	}
+
+	candidates := [][]int{}
+	for range 31 {
+		candidates = append(candidates, recipes)
+	}
+
+	if _, ok := solveMealPlan(candidates, mainIngredients, true); ok {
+		t.Error("got a plan, want none")
+	}
+}
+
+func TestPlanSlots(t *testing.T) {
+	slots, err := planSlots(MealPlanConstraints{
+		From:  "2026-06-15",
+		To:    "2026-06-16",
+		Meals: []string{"dinner", "breakfast"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []planSlot{
+		{"2026-06-15", "breakfast"},
+		{"2026-06-15", "dinner"},
+		{"2026-06-16", "breakfast"},
+		{"2026-06-16", "dinner"},
+	}
+	if !slices.Equal(slots, want) {
+		t.Errorf("got %v, want %v", slots, want)
+	}
+
+	slots, err = planSlots(MealPlanConstraints{From: "2026-06-15", To: "2026-06-15"})
+	if err != nil || !slices.Equal(slots, []planSlot{{"2026-06-15", "dinner"}}) {
+		t.Errorf("got %v %v, want a single dinner", slots, err)
+	}
+}
+
+func TestPlanSlotsErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		constraints MealPlanConstraints
+		err         error
+	}{
+		{"bad date", MealPlanConstraints{From: "tomorrow", To: "2026-06-15"}, ErrInvalidDate},
+		{"reversed range", MealPlanConstraints{From: "2026-06-15", To: "2026-06-14"}, ErrInvalidDateRange},
+		{"too long", MealPlanConstraints{From: "2026-06-01", To: "2026-07-02"}, ErrRangeTooLong},
+		{"unknown meal", MealPlanConstraints{From: "2026-06-15", To: "2026-06-15", Meals: []string{"brunch"}}, ErrInvalidMeal},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := planSlots(test.constraints); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestSlotCandidates(t *testing.T) {
+	recipes := []Recipe{
+		{Name: "Pancakes", Tags: []string{"breakfast"}, CookingTime: "20 minutes"},
+		{Name: "Roast", CookingTime: "2 hours"},
+		{Name: "Stir fry", CookingTime: "25 minutes"},
+		{Name: "Stew"},
+	}
+
+	tests := []struct {
+		name string
+		slot planSlot
+		want []int
+	}{
+		{"tagged recipes only", planSlot{"2026-06-15", "breakfast"}, []int{0}},
+		{"untagged meal uses every recipe", planSlot{"2026-06-13", "lunch"}, []int{0, 1, 2, 3}},
+		{"weeknight dinner must be quick", planSlot{"2026-06-15", "dinner"}, []int{0, 2}},
+		{"weekend dinner has no limit", planSlot{"2026-06-13", "dinner"}, []int{0, 1, 2, 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := slotCandidates(recipes, test.slot, 30); !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Layout used for meal plan dates in requests, responses and queries.
+const DateLayout = "2006-01-02"
+
+// Meal slots a recipe can be planned for.
+var MealSlots = []string{"breakfast", "lunch", "dinner", "snack"}
+
+var ErrInvalidDate = errors.New("dates must be formatted as YYYY-MM-DD")
+var ErrInvalidDateRange = errors.New("from date must not be after to date")
+var ErrInvalidMeal = errors.New("meal must be one of breakfast, lunch, dinner or snack")
+
+type MealPlan struct {
+	ID          int64  `json:"id"`
+	Date        string `json:"date"`
+	Meal        string `json:"meal"`
+	RecipeID    int64  `json:"recipeId"`
+	RecipeName  string `json:"recipeName"`
+	Servings    int    `json:"servings"`
+	Notes       string `json:"notes"`
+	UserID      int64  `json:"userId"`
+	HouseholdID *int64 `json:"householdId"`
+}
+
+// Queries the meal plan entries between two dates (inclusive) in the
+// cookbook the request is scoped to, ordered by date and meal slot.
+func ListMealPlans(ctx context.Context, from string, to string) ([]MealPlan, error) {
+	err := validateDateRange(from, to)
+	if err != nil {
+		return []MealPlan{}, err
+	}
+
+	scope, scopeId, err := cookbookScope(ctx, "m", "$1")
+	if err != nil {
+		return []MealPlan{}, err
+	}
+
+	query := `SELECT m.id, m.date::text, m.meal, m.recipe_id, r.name, m.servings, m.notes, m.user_id, m.household_id
+		FROM meal_plans m
+		JOIN recipes r ON r.id = m.recipe_id
+		WHERE ` + scope + ` AND m.date BETWEEN $2 AND $3
+		ORDER BY m.date, array_position(ARRAY['breakfast', 'lunch', 'dinner', 'snack'], m.meal), m.id`
+
+	rows, err := database.DB.Query(ctx, query, scopeId, from, to)
+	if err != nil {
+		return []MealPlan{}, err
+	}
+	defer rows.Close()
+
+	mealPlans := []MealPlan{}
+	for rows.Next() {
+		var mealPlan MealPlan
+
+		err = rows.Scan(&mealPlan.ID, &mealPlan.Date, &mealPlan.Meal, &mealPlan.RecipeID, &mealPlan.RecipeName,
+			&mealPlan.Servings, &mealPlan.Notes, &mealPlan.UserID, &mealPlan.HouseholdID)
+		if err != nil {
+			return []MealPlan{}, err
+		}
+
+		mealPlans = append(mealPlans, mealPlan)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []MealPlan{}, err
+	}
+
+	return mealPlans, nil
+}
+
+// Queries a single meal plan entry, as long as it belongs to the cookbook the
+// request is scoped to.
+func FindMealPlan(ctx context.Context, id int64) (MealPlan, error) {
+	scope, scopeId, err := cookbookScope(ctx, "m", "$2")
+	if err != nil {
+		return MealPlan{}, err
+	}
+
+	query := `SELECT m.id, m.date::text, m.meal, m.recipe_id, r.name, m.servings, m.notes, m.user_id, m.household_id
+		FROM meal_plans m
+		JOIN recipes r ON r.id = m.recipe_id
+		WHERE m.id = $1 AND ` + scope
+
+	var mealPlan MealPlan
+	err = database.DB.QueryRow(ctx, query, id, scopeId).Scan(&mealPlan.ID, &mealPlan.Date, &mealPlan.Meal,
+		&mealPlan.RecipeID, &mealPlan.RecipeName, &mealPlan.Servings, &mealPlan.Notes, &mealPlan.UserID,
+		&mealPlan.HouseholdID)
+	if err != nil {
+		return MealPlan{}, err
+	}
+
+	return mealPlan, nil
+}
+
+// Creates a meal plan entry in the cookbook the request is scoped to. The
+// user must be able to view the planned recipe.
+func CreateMealPlan(ctx context.Context, mealPlan MealPlan) (int64, error) {
+	err := validateMealPlan(ctx, &mealPlan)
+	if err != nil {
+		return -1, err
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	query := `INSERT INTO meal_plans (user_id, household_id, date, meal, recipe_id, servings, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	var id int64
+	err = database.DB.QueryRow(ctx, query, userId, householdId, mealPlan.Date, mealPlan.Meal, mealPlan.RecipeID,
+		mealPlan.Servings, mealPlan.Notes).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Updates a meal plan entry.
+func UpdateMealPlan(ctx context.Context, id int64, mealPlan MealPlan) error {
+	_, err := FindMealPlan(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = validateMealPlan(ctx, &mealPlan)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE meal_plans SET date = $1, meal = $2, recipe_id = $3, servings = $4, notes = $5 WHERE id = $6`
+
+	_, err = database.DB.Exec(ctx, query, mealPlan.Date, mealPlan.Meal, mealPlan.RecipeID, mealPlan.Servings,
+		mealPlan.Notes, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a meal plan entry.
+func DeleteMealPlan(ctx context.Context, id int64) error {
+	_, err := FindMealPlan(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM meal_plans WHERE id = $1`
+
+	_, err = database.DB.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Copies the seven days of meal plan entries starting at from to the seven
+// days starting at to. Existing entries in the target week are kept.
+// Returns the new entries.
+func CopyMealPlanWeek(ctx context.Context, from string, to string) ([]MealPlan, error) {
+	fromDate, err := time.Parse(DateLayout, from)
+	if err != nil {
+		return []MealPlan{}, ErrInvalidDate
+	}
+
+	toDate, err := time.Parse(DateLayout, to)
+	if err != nil {
+		return []MealPlan{}, ErrInvalidDate
+	}
+
+	week, err := ListMealPlans(ctx, from, fromDate.AddDate(0, 0, 6).Format(DateLayout))
+	if err != nil {
+		return []MealPlan{}, err
+	}
+
+	offset := int(toDate.Sub(fromDate).Hours() / 24)
+
+	// Check every entry before copying any, so that the week is copied
+	// completely or not at all
+	copied := []MealPlan{}
+	for _, mealPlan := range week {
+		date, err := time.Parse(DateLayout, mealPlan.Date)
+		if err != nil {
+			return []MealPlan{}, err
+		}
+
+		mealPlan.Date = date.AddDate(0, 0, offset).Format(DateLayout)
+
+		err = validateMealPlan(ctx, &mealPlan)
+		if err != nil {
+			return []MealPlan{}, err
+		}
+
+		copied = append(copied, mealPlan)
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return []MealPlan{}, err
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return []MealPlan{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO meal_plans (user_id, household_id, date, meal, recipe_id, servings, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	for i, mealPlan := range copied {
+		err = tx.QueryRow(ctx, query, userId, householdId, mealPlan.Date, mealPlan.Meal, mealPlan.RecipeID,
+			mealPlan.Servings, mealPlan.Notes).Scan(&copied[i].ID)
+		if err != nil {
+			return []MealPlan{}, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return []MealPlan{}, err
+	}
+
+	return copied, nil
+}
+
+// Builds a priced shopping list for every recipe planned between two dates
+// (inclusive). Ingredient quantities are scaled from the recipe's servings to
+// the planned servings when both are known.
+func MealPlanShoppingList(ctx context.Context, from string, to string) ([]ShoppingListItem, error) {
+	mealPlans, err := ListMealPlans(ctx, from, to)
+	if err != nil {
+		return []ShoppingListItem{}, err
+	}
+
+	return shoppingListForMealPlans(ctx, mealPlans)
+}
+
+// Helper Functions
+
+// Loads the ingredients of each planned recipe, scales them to the planned
+// servings and aggregates them into a shopping list.
+func shoppingListForMealPlans(ctx context.Context, mealPlans []MealPlan) ([]ShoppingListItem, error) {
+	recipes := map[int64]Recipe{}
+
+	var ingredients []Ingredient
+	for _, mealPlan := range mealPlans {
+		recipe, ok := recipes[mealPlan.RecipeID]
+		if !ok {
+			var err error
+			recipe, err = FindRecipe(ctx, mealPlan.RecipeID)
+			if err != nil {
+				return []ShoppingListItem{}, err
+			}
+
+			recipes[mealPlan.RecipeID] = recipe
+		}
+
+		scale := float32(1)
+		if recipe.Servings > 0 && mealPlan.Servings > 0 {
+			scale = float32(mealPlan.Servings) / float32(recipe.Servings)
+		}
+
+		for _, ingredient := range recipe.Ingredients {
+			ingredient.RecipeID = recipe.ID
+			ingredient.Quantity *= scale
+			ingredients = append(ingredients, ingredient)
+		}
+	}
+
+	return priceShoppingList(ctx, AggregateIngredients(ingredients))
+}
+
+// Validates a meal plan entry and defaults its servings to the recipe's
+// servings. The user must be able to view the planned recipe.
+func validateMealPlan(ctx context.Context, mealPlan *MealPlan) error {
+	_, err := time.Parse(DateLayout, mealPlan.Date)
+	if err != nil {
+		return ErrInvalidDate
+	}
+
+	validMeal := false
+	for _, meal := range MealSlots {
+		if mealPlan.Meal == meal {
+			validMeal = true
+		}
+	}
+
+	if !validMeal {
+		return ErrInvalidMeal
+	}
+
+	err = authorizeRecipe(ctx, mealPlan.RecipeID, RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	if mealPlan.Servings <= 0 {
+		var recipeServings int
+		err = database.DB.QueryRow(ctx, `SELECT servings FROM recipes WHERE id = $1`, mealPlan.RecipeID).Scan(&recipeServings)
+		if err != nil {
+			return err
+		}
+
+		mealPlan.Servings = max(recipeServings, 1)
+	}
+
+	return nil
+}
+
+// Checks that both dates are valid and in order.
+func validateDateRange(from string, to string) error {
+	fromDate, err := time.Parse(DateLayout, from)
+	if err != nil {
+		return ErrInvalidDate
+	}
+
+	toDate, err := time.Parse(DateLayout, to)
+	if err != nil {
+		return ErrInvalidDate
+	}
+
+	if fromDate.After(toDate) {
+		return ErrInvalidDateRange
+	}
+
+	return nil
+}
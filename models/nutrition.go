@@ -0,0 +1,82 @@
+package models
+
+import (
+	"context"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/nutrition"
+)
+
+// Calculates the nutrition of a recipe from the bundled food dataset.
+// Ingredients linked to the ingredient catalog are looked up by their catalog
+// name, and the rest by the name as written.
+func RecipeNutrition(ctx context.Context, id int64) (nutrition.Report, error) {
+	recipe, err := FindRecipe(ctx, id)
+	if err != nil {
+		return nutrition.Report{}, err
+	}
+
+	catalogNames, err := findCatalogNames(ctx, recipe.Ingredients)
+	if err != nil {
+		return nutrition.Report{}, err
+	}
+
+	items := []nutrition.Item{}
+	for _, ingredient := range recipe.Ingredients {
+		name := ingredient.Name
+		if ingredient.CatalogID != nil {
+			if catalogName, ok := catalogNames[*ingredient.CatalogID]; ok {
+				name = catalogName
+			}
+		}
+
+		items = append(items, nutrition.Item{
+			Name:     name,
+			Quantity: float64(ingredient.Quantity),
+			Unit:     ingredient.Unit,
+		})
+	}
+
+	return nutrition.Calculate(items, recipe.Servings), nil
+}
+
+// Helper Functions
+
+// Queries the catalog names of the entries the ingredients are linked to.
+func findCatalogNames(ctx context.Context, ingredients []Ingredient) (map[int64]string, error) {
+	ids := []int64{}
+	for _, ingredient := range ingredients {
+		if ingredient.CatalogID != nil {
+			ids = append(ids, *ingredient.CatalogID)
+		}
+	}
+
+	names := map[int64]string{}
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	rows, err := database.DB.Query(ctx, `SELECT id, name FROM ingredient_catalog WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return map[int64]string{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var name string
+
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return map[int64]string{}, err
+		}
+
+		names[id] = name
+	}
+
+	if err = rows.Err(); err != nil {
+		return map[int64]string{}, err
+	}
+
+	return names, nil
+}
@@ -0,0 +1,315 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/normalizer"
+	"github.com/mjande/recipes-microservice/units"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+var ErrEmptyName = errors.New("name cannot be empty")
+
+type PantryItem struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Quantity  float32 `json:"quantity"`
+	Unit      string  `json:"unit"`
+	ExpiresOn *string `json:"expiresOn"`
+}
+
+type PantryMatch struct {
+	RecipeID            int64        `json:"recipeId"`
+	RecipeName          string       `json:"recipeName"`
+	Coverage            float64      `json:"coverage"`
+	MissingIngredients  []Ingredient `json:"missingIngredients"`
+	ExpiringIngredients []string     `json:"expiringIngredients"`
+}
+
+// Queries every item in the pantry of the cookbook the request is scoped to,
+// soonest to expire first.
+func ListPantryItems(ctx context.Context) ([]PantryItem, error) {
+	scope, scopeId, err := cookbookScope(ctx, "p", "$1")
+	if err != nil {
+		return []PantryItem{}, err
+	}
+
+	query := `SELECT p.id, p.name, p.quantity, p.unit, p.expires_on::text
+		FROM pantry_items p
+		WHERE ` + scope + `
+		ORDER BY p.expires_on NULLS LAST, p.name`
+
+	rows, err := database.DB.Query(ctx, query, scopeId)
+	if err != nil {
+		return []PantryItem{}, err
+	}
+	defer rows.Close()
+
+	items := []PantryItem{}
+	for rows.Next() {
+		var item PantryItem
+
+		err = rows.Scan(&item.ID, &item.Name, &item.Quantity, &item.Unit, &item.ExpiresOn)
+		if err != nil {
+			return []PantryItem{}, err
+		}
+
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []PantryItem{}, err
+	}
+
+	return items, nil
+}
+
+// Queries a single pantry item, as long as it belongs to the cookbook the
+// request is scoped to.
+func FindPantryItem(ctx context.Context, id int64) (PantryItem, error) {
+	scope, scopeId, err := cookbookScope(ctx, "p", "$2")
+	if err != nil {
+		return PantryItem{}, err
+	}
+
+	query := `SELECT p.id, p.name, p.quantity, p.unit, p.expires_on::text
+		FROM pantry_items p
+		WHERE p.id = $1 AND ` + scope
+
+	var item PantryItem
+	err = database.DB.QueryRow(ctx, query, id, scopeId).Scan(&item.ID, &item.Name, &item.Quantity, &item.Unit, &item.ExpiresOn)
+	if err != nil {
+		return PantryItem{}, err
+	}
+
+	return item, nil
+}
+
+// Adds an item to the pantry of the cookbook the request is scoped to.
+func CreatePantryItem(ctx context.Context, item PantryItem) (int64, error) {
+	err := validatePantryItem(item)
+	if err != nil {
+		return -1, err
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	query := `INSERT INTO pantry_items (user_id, household_id, name, quantity, unit, expires_on)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	var id int64
+	err = database.DB.QueryRow(ctx, query, userId, householdId, item.Name, item.Quantity, item.Unit, item.ExpiresOn).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Updates a pantry item.
+func UpdatePantryItem(ctx context.Context, id int64, item PantryItem) error {
+	_, err := FindPantryItem(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = validatePantryItem(item)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE pantry_items SET name = $1, quantity = $2, unit = $3, expires_on = $4 WHERE id = $5`
+
+	_, err = database.DB.Exec(ctx, query, item.Name, item.Quantity, item.Unit, item.ExpiresOn, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Removes an item from the pantry.
+func DeletePantryItem(ctx context.Context, id int64) error {
+	_, err := FindPantryItem(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM pantry_items WHERE id = $1`
+
+	_, err = database.DB.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Ranks the recipes in the cookbook the request is scoped to by how much of
+// each recipe the pantry covers. Pantry items expiring within the given
+// number of days are treated as expiring soon.
+func ListPantryMatches(ctx context.Context, expiringWithin int) ([]PantryMatch, error) {
+	recipes, err := ListRecipesWithIngredients(ctx)
+	if err != nil {
+		return []PantryMatch{}, err
+	}
+
+	items, err := ListPantryItems(ctx)
+	if err != nil {
+		return []PantryMatch{}, err
+	}
+
+	return MatchPantry(recipes, items, time.Now(), expiringWithin), nil
+}
+
+// Scores recipes against pantry items. An ingredient is covered when the
+// pantry holds at least the quantity the recipe needs of an item with the
+// same name, converting between units where possible. Items past their
+// expiry date are ignored. Recipes are ordered by coverage, then by how many
+// soon-to-expire items they use, then by name.
+func MatchPantry(recipes []Recipe, items []PantryItem, now time.Time, expiringWithin int) []PantryMatch {
+	expiryCutoff := now.AddDate(0, 0, expiringWithin)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	pantry := map[string][]PantryItem{}
+	for _, item := range items {
+		if pantryItemExpired(item, today) {
+			continue
+		}
+
+		name := normalizeIngredientName(item.Name)
+		pantry[name] = append(pantry[name], item)
+	}
+
+	matches := []PantryMatch{}
+	for _, recipe := range recipes {
+		match := PantryMatch{
+			RecipeID:            recipe.ID,
+			RecipeName:          recipe.Name,
+			MissingIngredients:  []Ingredient{},
+			ExpiringIngredients: []string{},
+		}
+
+		covered := 0
+		for _, ingredient := range recipe.Ingredients {
+			pantryItems, ok := pantry[normalizeIngredientName(ingredient.Name)]
+			if !ok || !pantryCovers(pantryItems, ingredient) {
+				match.MissingIngredients = append(match.MissingIngredients, ingredient)
+				continue
+			}
+
+			covered++
+			if pantryExpiresBefore(pantryItems, expiryCutoff) {
+				match.ExpiringIngredients = append(match.ExpiringIngredients, ingredient.Name)
+			}
+		}
+
+		if len(recipe.Ingredients) > 0 {
+			match.Coverage = float64(covered) / float64(len(recipe.Ingredients))
+		}
+
+		matches = append(matches, match)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Coverage != matches[j].Coverage {
+			return matches[i].Coverage > matches[j].Coverage
+		}
+
+		if len(matches[i].ExpiringIngredients) != len(matches[j].ExpiringIngredients) {
+			return len(matches[i].ExpiringIngredients) > len(matches[j].ExpiringIngredients)
+		}
+
+		return matches[i].RecipeName < matches[j].RecipeName
+	})
+
+	return matches
+}
+
+// Helper Functions
+
+// Normalizes an ingredient name for comparison, so that "Tomatoes" in the
+// pantry covers "tomato, diced" in a recipe.
+func normalizeIngredientName(name string) string {
+	return normalizer.Normalize(name)
+}
+
+// Reports whether the pantry items hold enough of an ingredient. Items are
+// converted into the ingredient's unit, and items in a unit that cannot be
+// converted, such as grams for an ingredient measured in cups, do not count.
+func pantryCovers(items []PantryItem, ingredient Ingredient) bool {
+	ingredientUnit, known := units.Parse(ingredient.Unit)
+
+	var total float64
+	for _, item := range items {
+		if strings.EqualFold(strings.TrimSpace(item.Unit), strings.TrimSpace(ingredient.Unit)) {
+			total += float64(item.Quantity)
+			continue
+		}
+
+		itemUnit, ok := units.Parse(item.Unit)
+		if !known || !ok {
+			continue
+		}
+
+		quantity, err := units.Convert(float64(item.Quantity), itemUnit, ingredientUnit)
+		if err == nil {
+			total += quantity
+		}
+	}
+
+	// Allow for rounding in the conversion factors
+	return total >= float64(ingredient.Quantity)*0.999
+}
+
+// Reports whether a pantry item expired before today.
+func pantryItemExpired(item PantryItem, today time.Time) bool {
+	if item.ExpiresOn == nil {
+		return false
+	}
+
+	expiresOn, err := time.Parse(DateLayout, *item.ExpiresOn)
+	return err == nil && expiresOn.Before(today)
+}
+
+// Reports whether any of the pantry items expire before the cutoff.
+func pantryExpiresBefore(items []PantryItem, cutoff time.Time) bool {
+	for _, item := range items {
+		if item.ExpiresOn == nil {
+			continue
+		}
+
+		expiresOn, err := time.Parse(DateLayout, *item.ExpiresOn)
+		if err == nil && !expiresOn.After(cutoff) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks that a pantry item has a name and a valid expiry date.
+func validatePantryItem(item PantryItem) error {
+	if strings.TrimSpace(item.Name) == "" {
+		return ErrEmptyName
+	}
+
+	if item.ExpiresOn != nil {
+		_, err := time.Parse(DateLayout, *item.ExpiresOn)
+		if err != nil {
+			return ErrInvalidDate
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPantryCovers(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      []PantryItem
+		ingredient Ingredient
+		want       bool
+	}{
+		{"same unit", []PantryItem{{Quantity: 500, Unit: "g"}}, Ingredient{Quantity: 200, Unit: "g"}, true},
+		{"not enough", []PantryItem{{Quantity: 100, Unit: "g"}}, Ingredient{Quantity: 200, Unit: "g"}, false},
+		{"items add up", []PantryItem{{Quantity: 100, Unit: "g"}, {Quantity: 100, Unit: "G"}}, Ingredient{Quantity: 200, Unit: "g"}, true},
+		{"converts units", []PantryItem{{Quantity: 1, Unit: "kg"}}, Ingredient{Quantity: 750, Unit: "g"}, true},
+		{"allows for rounding", []PantryItem{{Quantity: 3, Unit: "tsp"}}, Ingredient{Quantity: 1, Unit: "tbsp"}, true},
+		{"different kinds do not count", []PantryItem{{Quantity: 500, Unit: "g"}}, Ingredient{Quantity: 1, Unit: "cup"}, false},
+		{"unknown units must match", []PantryItem{{Quantity: 2, Unit: "handful"}}, Ingredient{Quantity: 1, Unit: "handful"}, true},
+		{"counted items", []PantryItem{{Quantity: 6}}, Ingredient{Quantity: 2}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pantryCovers(test.items, test.ingredient); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatchPantry(t *testing.T) {
+	now := time.Date(2026, time.June, 15, 18, 0, 0, 0, time.UTC)
+	yesterday, tomorrow, nextMonth := "2026-06-14", "2026-06-16", "2026-07-15"
+
+	items := []PantryItem{
+		{Name: "Tomatoes", Quantity: 4, ExpiresOn: &tomorrow},
+		{Name: "pasta", Quantity: 1, Unit: "kg", ExpiresOn: &nextMonth},
+		{Name: "milk", Quantity: 1, Unit: "l", ExpiresOn: &yesterday},
+		{Name: "flour", Quantity: 500, Unit: "g"},
+	}
+
+	recipes := []Recipe{
+		{ID: 1, Name: "Pancakes", Ingredients: []Ingredient{
+			{Name: "flour", Quantity: 200, Unit: "g"},
+			{Name: "milk", Quantity: 300, Unit: "ml"},
+		}},
+		{ID: 2, Name: "Tomato pasta", Ingredients: []Ingredient{
+			{Name: "tomato", Quantity: 3},
+			{Name: "pasta", Quantity: 500, Unit: "g"},
+		}},
+		{ID: 3, Name: "Bread", Ingredients: []Ingredient{
+			{Name: "flour", Quantity: 500, Unit: "g"},
+		}},
+		{ID: 4, Name: "Water"},
+	}
+
+	want := []struct {
+		id       int64
+		coverage float64
+		missing  int
+		expiring int
+	}{
+		{2, 1, 0, 1},   // fully covered, tomatoes expire soon
+		{3, 1, 0, 0},   // fully covered
+		{1, 0.5, 1, 0}, // the milk has expired
+		{4, 0, 0, 0},   // no ingredients
+	}
+
+	matches := MatchPantry(recipes, items, now, 3)
+	if len(matches) != len(want) {
+		t.Fatalf("got %d matches, want %d", len(matches), len(want))
+	}
+
+	for i, expected := range want {
+		got := matches[i]
+		if got.RecipeID != expected.id || got.Coverage != expected.coverage ||
+			len(got.MissingIngredients) != expected.missing || len(got.ExpiringIngredients) != expected.expiring {
+			t.Errorf("position %d: got recipe %d with coverage %v, %d missing and %d expiring, want recipe %d with %v, %d and %d",
+				i, got.RecipeID, got.Coverage, len(got.MissingIngredients), len(got.ExpiringIngredients),
+				expected.id, expected.coverage, expected.missing, expected.expiring)
+		}
+	}
+}
+
+func TestValidatePantryItem(t *testing.T) {
+	date, badDate := "2026-06-15", "15/06/2026"
+
+	tests := []struct {
+		name string
+		item PantryItem
+		err  error
+	}{
+		{"valid", PantryItem{Name: "flour"}, nil},
+		{"with expiry", PantryItem{Name: "milk", ExpiresOn: &date}, nil},
+		{"missing name", PantryItem{Name: "  "}, ErrEmptyName},
+		{"bad expiry", PantryItem{Name: "milk", ExpiresOn: &badDate}, ErrInvalidDate},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validatePantryItem(test.item); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
+	"github.com/mjande/recipes-microservice/imaging"
+	"github.com/mjande/recipes-microservice/storage"
+)
+
+var ErrInvalidStep = errors.New("step must be a positive number")
+
+// File extensions of stored originals by content type
+var photoExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Photo is a photo of a recipe, or of one of its steps when Step is set.
+// Thumbnails maps each thumbnail size name to its URL.
+type Photo struct {
+	ID          int64             `json:"id"`
+	RecipeID    int64             `json:"recipeId"`
+	Step        *int              `json:"step"`
+	ContentType string            `json:"contentType"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	URL         string            `json:"url"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+	CreatedAt   time.Time         `json:"createdAt"`
+
+	keyPrefix string
+	extension string
+}
+
+// Queries the photos of a recipe, in the order they were uploaded.
+func ListRecipePhotos(ctx context.Context, recipeId int64) ([]Photo, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return []Photo{}, err
+	}
+
+	photos, err := findPhotos(ctx, []int64{recipeId})
+	if err != nil {
+		return []Photo{}, err
+	}
+
+	if photos[recipeId] == nil {
+		return []Photo{}, nil
+	}
+
+	return photos[recipeId], nil
+}
+
+// Stores uploaded photos of a recipe, or of one of its steps, along with
+// thumbnails in every size. The files' types are sniffed from their
+// contents. Every file is decoded before any is stored, and either all of
+// the photos are stored or none are. The user must be able to edit the
+// recipe.
+func CreatePhotos(ctx context.Context, recipeId int64, step *int, files [][]byte) ([]Photo, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleEditor)
+	if err != nil {
+		return []Photo{}, err
+	}
+
+	if step != nil && *step < 1 {
+		return []Photo{}, ErrInvalidStep
+	}
+
+	photos := make([]Photo, len(files))
+	images := make([]image.Image, len(files))
+	for i, data := range files {
+		contentType, img, err := imaging.Decode(data)
+		if err != nil {
+			return []Photo{}, fmt.Errorf("photo %d: %w", i+1, err)
+		}
+
+		keyPrefix, err := generatePhotoKeyPrefix(recipeId)
+		if err != nil {
+			return []Photo{}, err
+		}
+
+		images[i] = img
+		photos[i] = Photo{
+			RecipeID:    recipeId,
+			Step:        step,
+			ContentType: contentType,
+			Width:       img.Bounds().Dx(),
+			Height:      img.Bounds().Dy(),
+			keyPrefix:   keyPrefix,
+			extension:   photoExtensions[contentType],
+		}
+	}
+
+	// Clean up whatever was stored if any photo cannot be saved
+	stored := 0
+	saved := false
+	defer func() {
+		if !saved {
+			for _, photo := range photos[:stored] {
+				deletePhotoBlobs(ctx, photo)
+			}
+		}
+	}()
+
+	for i, photo := range photos {
+		stored = i + 1
+		err = storePhotoBlobs(ctx, photo, files[i], images[i])
+		if err != nil {
+			return []Photo{}, err
+		}
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return []Photo{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO recipe_photos (recipe_id, step, key_prefix, extension, content_type, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+
+	for i, photo := range photos {
+		err = tx.QueryRow(ctx, query, recipeId, step, photo.keyPrefix, photo.extension, photo.ContentType, photo.Width,
+			photo.Height).Scan(&photos[i].ID, &photos[i].CreatedAt)
+		if err != nil {
+			return []Photo{}, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return []Photo{}, err
+	}
+	saved = true
+
+	for i := range photos {
+		photos[i].setURLs()
+	}
+
+	publishRecipeEvent(ctx, events.RecipeUpdated, recipeId)
+
+	return photos, nil
+}
+
+// Removes a photo and its thumbnails. The user must be able to edit the
+// recipe.
+func DeletePhoto(ctx context.Context, recipeId int64, photoId int64) error {
+	err := authorizeRecipe(ctx, recipeId, RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	photos, err := findPhotos(ctx, []int64{recipeId})
+	if err != nil {
+		return err
+	}
+
+	for _, photo := range photos[recipeId] {
+		if photo.ID != photoId {
+			continue
+		}
+
+		err = deletePhotoBlobs(ctx, photo)
+		if err != nil {
+			return err
+		}
+
+		_, err = database.DB.Exec(ctx, `DELETE FROM recipe_photos WHERE id = $1`, photoId)
+		if err != nil {
+			return err
+		}
+
+		publishRecipeEvent(ctx, events.RecipeUpdated, recipeId)
+		return nil
+	}
+
+	return pgx.ErrNoRows
+}
+
+// Helper Functions
+
+// Queries the photos of several recipes, grouped by recipe.
+func findPhotos(ctx context.Context, recipeIds []int64) (map[int64][]Photo, error) {
+	query := `SELECT id, recipe_id, step, key_prefix, extension, content_type, width, height, created_at
+		FROM recipe_photos
+		WHERE recipe_id = ANY($1)
+		ORDER BY created_at, id`
+
+	rows, err := database.DB.Query(ctx, query, recipeIds)
+	if err != nil {
+		return map[int64][]Photo{}, err
+	}
+	defer rows.Close()
+
+	photos := map[int64][]Photo{}
+	for rows.Next() {
+		var photo Photo
+
+		err = rows.Scan(&photo.ID, &photo.RecipeID, &photo.Step, &photo.keyPrefix, &photo.extension,
+			&photo.ContentType, &photo.Width, &photo.Height, &photo.CreatedAt)
+		if err != nil {
+			return map[int64][]Photo{}, err
+		}
+
+		photo.setURLs()
+		photos[photo.RecipeID] = append(photos[photo.RecipeID], photo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return map[int64][]Photo{}, err
+	}
+
+	return photos, nil
+}
+
+// Adds the photos of each recipe.
+func loadRecipePhotos(ctx context.Context, recipes []Recipe) error {
+	recipeIds := make([]int64, len(recipes))
+	for i, recipe := range recipes {
+		recipeIds[i] = recipe.ID
+	}
+
+	photos, err := findPhotos(ctx, recipeIds)
+	if err != nil {
+		return err
+	}
+
+	for i := range recipes {
+		recipes[i].Photos = photos[recipes[i].ID]
+		if recipes[i].Photos == nil {
+			recipes[i].Photos = []Photo{}
+		}
+	}
+
+	return nil
+}
+
+// Stores the original photo and a thumbnail in every size.
+func storePhotoBlobs(ctx context.Context, photo Photo, data []byte, img image.Image) error {
+	err := storage.Store.Put(ctx, photo.originalKey(), bytes.NewReader(data), int64(len(data)), photo.ContentType)
+	if err != nil {
+		return err
+	}
+
+	for _, size := range imaging.ThumbnailSizes {
+		thumbnail, err := imaging.EncodeThumbnail(imaging.Resize(img, size.MaxSize))
+		if err != nil {
+			return err
+		}
+
+		err = storage.Store.Put(ctx, photo.thumbnailKey(size.Name), bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Removes the original photo and its thumbnails from storage.
+func deletePhotoBlobs(ctx context.Context, photo Photo) error {
+	err := storage.Store.Delete(ctx, photo.originalKey())
+	if err != nil {
+		return err
+	}
+
+	for _, size := range imaging.ThumbnailSizes {
+		err = storage.Store.Delete(ctx, photo.thumbnailKey(size.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Generates a random, unguessable storage key prefix for a new photo.
+func generatePhotoKeyPrefix(recipeId int64) (string, error) {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+
+	return "recipes/" + strconv.FormatInt(recipeId, 10) + "/" + hex.EncodeToString(random), nil
+}
+
+func (p Photo) originalKey() string {
+	return p.keyPrefix + "/original." + p.extension
+}
+
+func (p Photo) thumbnailKey(size string) string {
+	return p.keyPrefix + "/" + size + ".jpg"
+}
+
+// Fills in the photo's URLs from its storage keys.
+func (p *Photo) setURLs() {
+	p.URL = storage.Store.URL(p.originalKey())
+	p.Thumbnails = map[string]string{}
+	for _, size := range imaging.ThumbnailSizes {
+		p.Thumbnails[size.Name] = storage.Store.URL(p.thumbnailKey(size.Name))
+	}
+}
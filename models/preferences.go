@@ -0,0 +1,217 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+var ErrInvalidRating = errors.New("rating must be between 1 and 5")
+var ErrEmptyNote = errors.New("note cannot be empty")
+
+// A personal note a user has written on a recipe. Notes are only visible to
+// the user who wrote them.
+type RecipeNote struct {
+	ID        int64     `json:"id"`
+	RecipeID  int64     `json:"recipeId"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Marks or unmarks a recipe as one of the current user's favorites.
+func SetFavorite(ctx context.Context, recipeId int64, favorite bool) error {
+	err := authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `INSERT INTO recipe_preferences (recipe_id, user_id, favorite) VALUES ($1, $2, $3)
+		ON CONFLICT (recipe_id, user_id) DO UPDATE SET favorite = EXCLUDED.favorite`
+
+	_, err = database.DB.Exec(ctx, query, recipeId, userId, favorite)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Sets the current user's rating of a recipe. A nil rating removes it.
+func SetRating(ctx context.Context, recipeId int64, rating *int) error {
+	err := authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	if rating != nil && (*rating < 1 || *rating > 5) {
+		return ErrInvalidRating
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `INSERT INTO recipe_preferences (recipe_id, user_id, rating) VALUES ($1, $2, $3)
+		ON CONFLICT (recipe_id, user_id) DO UPDATE SET rating = EXCLUDED.rating`
+
+	_, err = database.DB.Exec(ctx, query, recipeId, userId, rating)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Queries the current user's notes on a recipe, oldest first.
+func ListRecipeNotes(ctx context.Context, recipeId int64) ([]RecipeNote, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return []RecipeNote{}, err
+	}
+
+	return findRecipeNotes(ctx, recipeId)
+}
+
+// Queries one of the current user's notes on a recipe.
+func FindRecipeNote(ctx context.Context, recipeId int64, noteId int64) (RecipeNote, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return RecipeNote{}, err
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `SELECT id, recipe_id, body, created_at, updated_at FROM recipe_notes
+		WHERE id = $1 AND recipe_id = $2 AND user_id = $3`
+
+	var note RecipeNote
+	err = database.DB.QueryRow(ctx, query, noteId, recipeId, userId).Scan(&note.ID, &note.RecipeID, &note.Body,
+		&note.CreatedAt, &note.UpdatedAt)
+	if err != nil {
+		return RecipeNote{}, err
+	}
+
+	return note, nil
+}
+
+// Adds a note to a recipe for the current user.
+func CreateRecipeNote(ctx context.Context, recipeId int64, body string) (int64, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return -1, err
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return -1, ErrEmptyNote
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `INSERT INTO recipe_notes (recipe_id, user_id, body) VALUES ($1, $2, $3) RETURNING id`
+
+	var id int64
+	err = database.DB.QueryRow(ctx, query, recipeId, userId, strings.TrimSpace(body)).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Changes the text of one of the current user's notes.
+func UpdateRecipeNote(ctx context.Context, recipeId int64, noteId int64, body string) error {
+	_, err := FindRecipeNote(ctx, recipeId, noteId)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return ErrEmptyNote
+	}
+
+	query := `UPDATE recipe_notes SET body = $1, updated_at = NOW() WHERE id = $2`
+
+	_, err = database.DB.Exec(ctx, query, strings.TrimSpace(body), noteId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Removes one of the current user's notes.
+func DeleteRecipeNote(ctx context.Context, recipeId int64, noteId int64) error {
+	_, err := FindRecipeNote(ctx, recipeId, noteId)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(ctx, `DELETE FROM recipe_notes WHERE id = $1`, noteId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Helper Functions
+
+// Adds the current user's favorite flag, rating and notes to a recipe.
+func loadRecipePreferences(ctx context.Context, recipe *Recipe) error {
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `SELECT COALESCE(p.favorite, FALSE), p.rating
+		FROM recipes r
+		LEFT JOIN recipe_preferences p ON p.recipe_id = r.id AND p.user_id = $2
+		WHERE r.id = $1`
+
+	err := database.DB.QueryRow(ctx, query, recipe.ID, userId).Scan(&recipe.Favorite, &recipe.Rating)
+	if err != nil {
+		return err
+	}
+
+	recipe.Notes, err = findRecipeNotes(ctx, recipe.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Queries the current user's notes on a recipe without checking access.
+func findRecipeNotes(ctx context.Context, recipeId int64) ([]RecipeNote, error) {
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	query := `SELECT id, recipe_id, body, created_at, updated_at FROM recipe_notes
+		WHERE recipe_id = $1 AND user_id = $2
+		ORDER BY created_at, id`
+
+	rows, err := database.DB.Query(ctx, query, recipeId, userId)
+	if err != nil {
+		return []RecipeNote{}, err
+	}
+	defer rows.Close()
+
+	notes := []RecipeNote{}
+	for rows.Next() {
+		var note RecipeNote
+
+		err = rows.Scan(&note.ID, &note.RecipeID, &note.Body, &note.CreatedAt, &note.UpdatedAt)
+		if err != nil {
+			return []RecipeNote{}, err
+		}
+
+		notes = append(notes, note)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []RecipeNote{}, err
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,374 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/nutrition"
+	"github.com/mjande/recipes-microservice/units"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+var ErrInvalidPrice = errors.New("price cannot be negative")
+var ErrInvalidQuantity = errors.New("quantity cannot be negative")
+
+// Price is what an ingredient costs at a store, for a package of Quantity
+// Unit. A quantity of zero is treated as a single unit.
+type Price struct {
+	ID       int64   `json:"id"`
+	Name     string  `json:"name"`
+	Price    float64 `json:"price"`
+	Quantity float32 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	Store    string  `json:"store"`
+}
+
+type IngredientCost struct {
+	Name     string  `json:"name"`
+	Quantity float32 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	Cost     float64 `json:"cost"`
+	Store    string  `json:"store"`
+}
+
+type Unpriced struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type RecipeCost struct {
+	RecipeID    int64            `json:"recipeId"`
+	Servings    int              `json:"servings"`
+	Total       float64          `json:"total"`
+	PerServing  *float64         `json:"perServing"`
+	Ingredients []IngredientCost `json:"ingredients"`
+	Unpriced    []Unpriced       `json:"unpriced"`
+}
+
+// Queries every price in the cookbook the request is scoped to.
+func ListPrices(ctx context.Context) ([]Price, error) {
+	scope, scopeId, err := cookbookScope(ctx, "p", "$1")
+	if err != nil {
+		return []Price{}, err
+	}
+
+	query := `SELECT p.id, p.name, p.price, p.quantity, p.unit, p.store
+		FROM prices p
+		WHERE ` + scope + `
+		ORDER BY p.name, p.store`
+
+	rows, err := database.DB.Query(ctx, query, scopeId)
+	if err != nil {
+		return []Price{}, err
+	}
+	defer rows.Close()
+
+	prices := []Price{}
+	for rows.Next() {
+		var price Price
+
+		err = rows.Scan(&price.ID, &price.Name, &price.Price, &price.Quantity, &price.Unit, &price.Store)
+		if err != nil {
+			return []Price{}, err
+		}
+
+		prices = append(prices, price)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Price{}, err
+	}
+
+	return prices, nil
+}
+
+// Queries a single price, as long as it belongs to the cookbook the request
+// is scoped to.
+func FindPrice(ctx context.Context, id int64) (Price, error) {
+	scope, scopeId, err := cookbookScope(ctx, "p", "$2")
+	if err != nil {
+		return Price{}, err
+	}
+
+	query := `SELECT p.id, p.name, p.price, p.quantity, p.unit, p.store
+		FROM prices p
+		WHERE p.id = $1 AND ` + scope
+
+	var price Price
+	err = database.DB.QueryRow(ctx, query, id, scopeId).Scan(&price.ID, &price.Name, &price.Price, &price.Quantity,
+		&price.Unit, &price.Store)
+	if err != nil {
+		return Price{}, err
+	}
+
+	return price, nil
+}
+
+// Adds a price to the cookbook the request is scoped to.
+func CreatePrice(ctx context.Context, price Price) (int64, error) {
+	err := validatePrice(price)
+	if err != nil {
+		return -1, err
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	query := `INSERT INTO prices (user_id, household_id, name, price, quantity, unit, store)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	var id int64
+	err = database.DB.QueryRow(ctx, query, userId, householdId, strings.TrimSpace(price.Name), price.Price,
+		price.Quantity, strings.TrimSpace(price.Unit), strings.TrimSpace(price.Store)).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Updates a price.
+func UpdatePrice(ctx context.Context, id int64, price Price) error {
+	_, err := FindPrice(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = validatePrice(price)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE prices SET name = $1, price = $2, quantity = $3, unit = $4, store = $5 WHERE id = $6`
+
+	_, err = database.DB.Exec(ctx, query, strings.TrimSpace(price.Name), price.Price, price.Quantity,
+		strings.TrimSpace(price.Unit), strings.TrimSpace(price.Store), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Removes a price.
+func DeletePrice(ctx context.Context, id int64) error {
+	_, err := FindPrice(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(ctx, `DELETE FROM prices WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Estimates what a recipe costs to make from the prices in the cookbook the
+// request is scoped to. Ingredients without a usable price are listed as
+// unpriced and left out of the total.
+func FindRecipeCost(ctx context.Context, recipeId int64) (RecipeCost, error) {
+	recipe, err := FindRecipe(ctx, recipeId)
+	if err != nil {
+		return RecipeCost{}, err
+	}
+
+	prices, err := ListPrices(ctx)
+	if err != nil {
+		return RecipeCost{}, err
+	}
+
+	cost := RecipeCost{
+		RecipeID:    recipe.ID,
+		Servings:    recipe.Servings,
+		Ingredients: []IngredientCost{},
+		Unpriced:    []Unpriced{},
+	}
+
+	pricesByName := groupPrices(prices)
+	for _, ingredient := range recipe.Ingredients {
+		price, amount, reason := cheapestPrice(pricesByName, ingredient.Name, ingredient.Quantity, ingredient.Unit)
+		if price == nil {
+			cost.Unpriced = append(cost.Unpriced, Unpriced{Name: ingredient.Name, Reason: reason})
+			continue
+		}
+
+		cost.Ingredients = append(cost.Ingredients, IngredientCost{
+			Name:     ingredient.Name,
+			Quantity: ingredient.Quantity,
+			Unit:     ingredient.Unit,
+			Cost:     roundCost(amount),
+			Store:    price.Store,
+		})
+		cost.Total += amount
+	}
+
+	if recipe.Servings > 0 {
+		perServing := roundCost(cost.Total / float64(recipe.Servings))
+		cost.PerServing = &perServing
+	}
+
+	cost.Total = roundCost(cost.Total)
+	return cost, nil
+}
+
+// Adds costs to shopping list items from the prices in the cookbook the
+// request is scoped to. Items without a usable price keep a nil cost.
+func priceShoppingList(ctx context.Context, items []ShoppingListItem) ([]ShoppingListItem, error) {
+	prices, err := ListPrices(ctx)
+	if err != nil {
+		return []ShoppingListItem{}, err
+	}
+
+	pricesByName := groupPrices(prices)
+	for i, item := range items {
+		price, amount, _ := cheapestPrice(pricesByName, item.Name, item.Quantity, item.Unit)
+		if price == nil {
+			continue
+		}
+
+		cost := roundCost(amount)
+		items[i].Cost = &cost
+		items[i].Store = price.Store
+	}
+
+	return items, nil
+}
+
+// Sums the costs of shopping list items and lists the items that have no
+// price.
+func ShoppingListCost(items []ShoppingListItem) (float64, []string) {
+	total := 0.0
+	unpriced := []string{}
+	for _, item := range items {
+		if item.Cost == nil {
+			unpriced = append(unpriced, item.Name)
+			continue
+		}
+
+		total += *item.Cost
+	}
+
+	return roundCost(total), unpriced
+}
+
+// Helper Functions
+
+// Groups prices by normalized ingredient name.
+func groupPrices(prices []Price) map[string][]Price {
+	grouped := map[string][]Price{}
+	for _, price := range prices {
+		name := normalizeIngredientName(price.Name)
+		grouped[name] = append(grouped[name], price)
+	}
+
+	return grouped
+}
+
+// Finds the cheapest way to buy a quantity of an ingredient from its prices.
+// Returns the price used and the cost, or nil and the reason no price could
+// be used.
+func cheapestPrice(prices map[string][]Price, name string, quantity float32, unit string) (*Price, float64, string) {
+	candidates, ok := prices[normalizeIngredientName(name)]
+	if !ok {
+		return nil, 0, "no price"
+	}
+
+	var cheapest *Price
+	cheapestCost := math.Inf(1)
+	for i, price := range candidates {
+		packageSize := float64(price.Quantity)
+		if packageSize == 0 {
+			packageSize = 1
+		}
+
+		// Express the needed quantity in the price's unit
+		needed, ok := convertQuantity(name, float64(quantity), unit, price.Unit)
+		if !ok {
+			continue
+		}
+
+		cost := needed / packageSize * price.Price
+		if cost < cheapestCost {
+			cheapest = &candidates[i]
+			cheapestCost = cost
+		}
+	}
+
+	if cheapest == nil {
+		return nil, 0, "no price in a unit that converts to \"" + unit + "\""
+	}
+
+	return cheapest, cheapestCost, ""
+}
+
+// Converts a quantity of an ingredient between units. Units of different
+// kinds, such as cups and pounds, are converted using the ingredient's
+// density or piece weight from the nutrition dataset.
+func convertQuantity(name string, quantity float64, from string, to string) (float64, bool) {
+	if strings.EqualFold(strings.TrimSpace(from), strings.TrimSpace(to)) {
+		return quantity, true
+	}
+
+	fromUnit, ok := units.Parse(from)
+	if !ok {
+		return 0, false
+	}
+
+	toUnit, ok := units.Parse(to)
+	if !ok {
+		return 0, false
+	}
+
+	converted, err := units.Convert(quantity, fromUnit, toUnit)
+	if err == nil {
+		return converted, true
+	}
+
+	food, ok := nutrition.Lookup(name)
+	if !ok {
+		return 0, false
+	}
+
+	grams, _, ok := nutrition.Grams(food, quantity, from)
+	if !ok {
+		return 0, false
+	}
+
+	gramsPerUnit, _, ok := nutrition.Grams(food, 1, to)
+	if !ok || gramsPerUnit == 0 {
+		return 0, false
+	}
+
+	return grams / gramsPerUnit, true
+}
+
+// Rounds an amount of money to cents.
+func roundCost(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Checks that a price names an ingredient and has no negative amounts.
+func validatePrice(price Price) error {
+	if strings.TrimSpace(price.Name) == "" {
+		return ErrEmptyName
+	}
+
+	if price.Price < 0 {
+		return ErrInvalidPrice
+	}
+
+	if price.Quantity < 0 {
+		return ErrInvalidQuantity
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConvertQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		food     string
+		quantity float64
+		from     string
+		to       string
+		want     float64
+		ok       bool
+	}{
+		{"same unit ignoring case", "salt", 2, "G", "g", 2, true},
+		{"same kind", "salt", 1.5, "kg", "g", 1500, true},
+		{"volume to mass by density", "butter", 1, "cup", "g", 227.12448, true},
+		{"count to mass by piece weight", "eggs", 2, "", "g", 100, true},
+		{"mass to count by piece weight", "egg", 150, "g", "piece", 3, true},
+		{"no piece weight", "rice", 1, "piece", "g", 0, false},
+		{"unknown unit", "salt", 1, "handful", "g", 0, false},
+		{"unknown food", "unobtainium", 1, "cup", "g", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := convertQuantity(test.food, test.quantity, test.from, test.to)
+			if math.Abs(got-test.want) > 1e-6 || ok != test.ok {
+				t.Errorf("got %v %v, want %v %v", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestCheapestPrice(t *testing.T) {
+	prices := groupPrices([]Price{
+		{Name: "Butter", Price: 4, Quantity: 500, Unit: "g", Store: "corner shop"},
+		{Name: "butter", Price: 5, Quantity: 1, Unit: "kg", Store: "supermarket"},
+		{Name: "eggs", Price: 3, Quantity: 12, Store: "farm"},
+		{Name: "saffron", Price: 8, Quantity: 1, Unit: "piece", Store: "deli"},
+	})
+
+	tests := []struct {
+		name     string
+		food     string
+		quantity float32
+		unit     string
+		store    string
+		cost     float64
+		reason   string
+	}{
+		{"cheapest package", "butter", 250, "g", "supermarket", 1.25, ""},
+		{"converts units", "butter", 0.5, "kg", "supermarket", 2.5, ""},
+		{"priced per piece", "egg", 6, "", "farm", 1.5, ""},
+		{"no price", "flour", 1, "kg", "", 0, "no price"},
+		{"no convertible unit", "saffron", 1, "g", "", 0, `no price in a unit that converts to "g"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			price, cost, reason := cheapestPrice(prices, test.food, test.quantity, test.unit)
+
+			store := ""
+			if price != nil {
+				store = price.Store
+			}
+
+			if store != test.store || math.Abs(cost-test.cost) > 1e-6 || reason != test.reason {
+				t.Errorf("got %q %v %q, want %q %v %q", store, cost, reason, test.store, test.cost, test.reason)
+			}
+		})
+	}
+}
+
+func TestShoppingListCost(t *testing.T) {
+	first, second := 1.004, 2.5
+	items := []ShoppingListItem{
+		{Name: "butter", Cost: &first},
+		{Name: "flour"},
+		{Name: "eggs", Cost: &second},
+	}
+
+	total, unpriced := ShoppingListCost(items)
+	if total != 3.5 || len(unpriced) != 1 || unpriced[0] != "flour" {
+		t.Errorf("got %v %v, want 3.5 [flour]", total, unpriced)
+	}
+}
+
+func TestValidatePrice(t *testing.T) {
+	tests := []struct {
+		name  string
+		price Price
+		err   error
+	}{
+		{"valid", Price{Name: "butter", Price: 4, Quantity: 500, Unit: "g"}, nil},
+		{"free and unsized", Price{Name: "water"}, nil},
+		{"missing name", Price{Name: " ", Price: 1}, ErrEmptyName},
+		{"negative price", Price{Name: "butter", Price: -1}, ErrInvalidPrice},
+		{"negative quantity", Price{Name: "butter", Quantity: -1}, ErrInvalidQuantity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validatePrice(test.price); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
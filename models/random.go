@@ -0,0 +1,256 @@
+package models
+
+import (
+	"context"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Most recipes remembered per session when avoiding repeated picks.
+const maxPickHistory = 100
+
+// How long a session's picks are remembered after its last pick.
+const pickSessionTTL = 2 * time.Hour
+
+// Most sessions remembered at once. The least recently used session is
+// forgotten to make room for a new one.
+const maxPickSessions = 1000
+
+// Matches the amounts in free-form cooking times such as "1 hour 15 min",
+// each with the unit that follows it. Ranges such as "30-45 minutes" or
+// "1 to 2 hours" are matched as one amount.
+var cookingTimePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)(?:\s*(?:-|–|to)\s*(\d+(?:\.\d+)?))?\s*([a-z]*)`)
+
+// Units of cooking times that are hours. Amounts in any other unit, or
+// without one, are minutes.
+var hourUnits = map[string]bool{"h": true, "hr": true, "hrs": true, "hour": true, "hours": true}
+
+// Narrows down the recipes PickRandomRecipes chooses from. Recipes must have
+// every tag in Tags and fit every diet in Diets. MaxCookingTime is in
+// minutes and ExcludeCookedWithin in days; zero leaves them unlimited. Seed
+// makes the picks reproducible. Session identifies a series of calls whose
+// picks should not repeat; calls without one share a session per user,
+// unless they are seeded.
+type RandomFilter struct {
+	Count               int
+	Tags                []string
+	Diets               []string
+	MaxCookingTime      int
+	ExcludeCookedWithin int
+	ExcludeIDs          []int64
+	Seed                *uint64
+	Session             string
+}
+
+// Recipes recently picked in each session, so that consecutive calls do not
+// suggest the same recipes. The history is kept in memory, so it is lost on
+// restart and is not shared between instances of the service.
+var randomPicks = pickHistory{sessions: map[string]*pickSession{}}
+
+type pickHistory struct {
+	mu       sync.Mutex
+	sessions map[string]*pickSession
+}
+
+type pickSession struct {
+	picked   []int64
+	lastUsed time.Time
+}
+
+// Picks up to Count random recipes from the cookbook the request is scoped to
+// that match the filter. Recipes picked earlier in the same session are
+// avoided until every matching recipe has been picked.
+func PickRandomRecipes(ctx context.Context, filter RandomFilter) ([]Recipe, error) {
+	recipes, err := ListRecipes(ctx, RecipeFilter{Diets: filter.Diets})
+	if err != nil {
+		return []Recipe{}, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -filter.ExcludeCookedWithin).Format(DateLayout)
+
+	candidates := []Recipe{}
+	for _, recipe := range recipes {
+		if slices.Contains(filter.ExcludeIDs, recipe.ID) || !hasTags(recipe, filter.Tags) {
+			continue
+		}
+
+		if filter.MaxCookingTime > 0 {
+			minutes, ok := parseCookingTime(recipe.CookingTime)
+			if !ok || minutes > filter.MaxCookingTime {
+				continue
+			}
+		}
+
+		// Dates in DateLayout sort chronologically as strings
+		if filter.ExcludeCookedWithin > 0 && recipe.LastCooked != nil && *recipe.LastCooked >= cutoff {
+			continue
+		}
+
+		candidates = append(candidates, recipe)
+	}
+
+	var random *rand.Rand
+	if filter.Seed != nil {
+		random = rand.New(rand.NewPCG(*filter.Seed, 0))
+	} else {
+		random = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+
+	// A seed without a session must give the same picks on every call, so
+	// earlier picks are not avoided
+	if filter.Seed != nil && filter.Session == "" {
+		history := pickHistory{sessions: map[string]*pickSession{}}
+		return history.pick("", candidates, filter.Count, random), nil
+	}
+
+	key, err := pickSessionKey(ctx, filter.Session)
+	if err != nil {
+		return []Recipe{}, err
+	}
+
+	return randomPicks.pick(key, candidates, filter.Count, random), nil
+}
+
+// Helper Functions
+
+// Picks count recipes at random, preferring those not yet picked in the
+// session.
+func (h *pickHistory) pick(key string, candidates []Recipe, count int, random *rand.Rand) []Recipe {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for k, session := range h.sessions {
+		if now.Sub(session.lastUsed) > pickSessionTTL {
+			delete(h.sessions, k)
+		}
+	}
+
+	session, ok := h.sessions[key]
+	if !ok {
+		if len(h.sessions) >= maxPickSessions {
+			h.evictOldestSession()
+		}
+
+		session = &pickSession{}
+		h.sessions[key] = session
+	}
+	session.lastUsed = now
+
+	fresh, stale := []Recipe{}, []Recipe{}
+	for _, recipe := range candidates {
+		if slices.Contains(session.picked, recipe.ID) {
+			stale = append(stale, recipe)
+		} else {
+			fresh = append(fresh, recipe)
+		}
+	}
+
+	// Shuffle copies, so that the result only depends on the candidates and
+	// the random source
+	shuffle := func(recipes []Recipe) {
+		random.Shuffle(len(recipes), func(i, j int) {
+			recipes[i], recipes[j] = recipes[j], recipes[i]
+		})
+	}
+	shuffle(fresh)
+	shuffle(stale)
+
+	// Unpicked recipes come first. Once they run out, the session starts over.
+	if len(fresh) < count {
+		session.picked = nil
+	}
+
+	ordered := append(fresh, stale...)
+	picked := ordered[:min(count, len(ordered))]
+	for _, recipe := range picked {
+		session.picked = append(session.picked, recipe.ID)
+	}
+
+	if len(session.picked) > maxPickHistory {
+		session.picked = session.picked[len(session.picked)-maxPickHistory:]
+	}
+
+	return picked
+}
+
+// Forgets the least recently used session.
+func (h *pickHistory) evictOldestSession() {
+	oldestKey := ""
+	var oldest *pickSession
+	for key, session := range h.sessions {
+		if oldest == nil || session.lastUsed.Before(oldest.lastUsed) {
+			oldestKey, oldest = key, session
+		}
+	}
+
+	delete(h.sessions, oldestKey)
+}
+
+// Identifies a session by user and cookbook as well as the name the client
+// gave it, so that sessions cannot see each other's picks.
+func pickSessionKey(ctx context.Context, session string) (string, error) {
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scope := ""
+	if householdId != nil {
+		scope = *householdId
+	}
+
+	return utils.ExtractUserIDFromContext(ctx) + "/" + scope + "/" + session, nil
+}
+
+// Reports whether a recipe has every one of the tags, ignoring case.
+func hasTags(recipe Recipe, tags []string) bool {
+	for _, tag := range tags {
+		found := slices.ContainsFunc(recipe.Tags, func(recipeTag string) bool {
+			return strings.EqualFold(recipeTag, tag)
+		})
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Parses a free-form cooking time such as "45 minutes", "1 hr 15 min" or
+// "1.5 hours" into minutes. Ranges such as "30-45 minutes" take their upper
+// bound, and numbers without a unit are minutes.
+func parseCookingTime(cookingTime string) (int, bool) {
+	matches := cookingTimePattern.FindAllStringSubmatch(strings.ToLower(cookingTime), -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+
+	var minutes float64
+	for _, match := range matches {
+		amountText := match[1]
+		if match[2] != "" {
+			amountText = match[2]
+		}
+
+		amount, err := strconv.ParseFloat(amountText, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		if hourUnits[match[3]] {
+			amount *= 60
+		}
+
+		minutes += amount
+	}
+
+	return int(minutes + 0.5), true
+}
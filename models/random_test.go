@@ -0,0 +1,167 @@
+package models
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func pickIDs(recipes []Recipe) []int64 {
+	ids := []int64{}
+	for _, recipe := range recipes {
+		ids = append(ids, recipe.ID)
+	}
+
+	return ids
+}
+
+func numberedRecipes(count int) []Recipe {
+	recipes := []Recipe{}
+	for i := 1; i <= count; i++ {
+		recipes = append(recipes, Recipe{ID: int64(i)})
+	}
+
+	return recipes
+}
+
+func TestPickIsReproducibleWithSeed(t *testing.T) {
+	candidates := numberedRecipes(20)
+
+	first := pickHistory{sessions: map[string]*pickSession{}}
+	second := pickHistory{sessions: map[string]*pickSession{}}
+
+	a := first.pick("", candidates, 5, rand.New(rand.NewPCG(42, 0)))
+	b := second.pick("", candidates, 5, rand.New(rand.NewPCG(42, 0)))
+
+	if !slices.Equal(pickIDs(a), pickIDs(b)) {
+		t.Errorf("same seed gave %v and %v", pickIDs(a), pickIDs(b))
+	}
+
+	other := pickHistory{sessions: map[string]*pickSession{}}
+	c := other.pick("", candidates, 5, rand.New(rand.NewPCG(43, 0)))
+	if slices.Equal(pickIDs(a), pickIDs(c)) {
+		t.Errorf("different seeds both gave %v", pickIDs(a))
+	}
+}
+
+func TestPickDoesNotShuffleCandidates(t *testing.T) {
+	candidates := numberedRecipes(10)
+	history := pickHistory{sessions: map[string]*pickSession{}}
+
+	history.pick("", candidates, 3, rand.New(rand.NewPCG(1, 0)))
+
+	if !slices.Equal(pickIDs(candidates), pickIDs(numberedRecipes(10))) {
+		t.Errorf("candidates were reordered to %v", pickIDs(candidates))
+	}
+}
+
+func TestPickAvoidsRepeatsWithinSession(t *testing.T) {
+	candidates := numberedRecipes(6)
+	history := pickHistory{sessions: map[string]*pickSession{}}
+	random := rand.New(rand.NewPCG(7, 0))
+
+	seen := []int64{}
+	for range 3 {
+		for _, id := range pickIDs(history.pick("session", candidates, 2, random)) {
+			if slices.Contains(seen, id) {
+				t.Fatalf("recipe %d picked twice before every recipe was picked", id)
+			}
+			seen = append(seen, id)
+		}
+	}
+
+	// Every recipe has been picked, so the session starts over
+	if picked := history.pick("session", candidates, 2, random); len(picked) != 2 {
+		t.Errorf("got %d recipes after the session started over, want 2", len(picked))
+	}
+
+	// Another session is not affected by the first
+	if picked := history.pick("other", candidates, 6, random); len(picked) != 6 {
+		t.Errorf("got %d recipes in a new session, want 6", len(picked))
+	}
+}
+
+func TestPickReturnsAtMostCandidates(t *testing.T) {
+	history := pickHistory{sessions: map[string]*pickSession{}}
+
+	picked := history.pick("", numberedRecipes(2), 5, rand.New(rand.NewPCG(1, 0)))
+	if len(picked) != 2 {
+		t.Errorf("got %d recipes, want 2", len(picked))
+	}
+}
+
+func TestPickForgetsLeastRecentlyUsedSession(t *testing.T) {
+	history := pickHistory{sessions: map[string]*pickSession{}}
+
+	now := time.Now()
+	for i := range maxPickSessions {
+		key := strconv.Itoa(i)
+		history.sessions[key] = &pickSession{lastUsed: now.Add(time.Duration(i-maxPickSessions) * time.Second)}
+	}
+
+	history.pick("new", numberedRecipes(3), 1, rand.New(rand.NewPCG(1, 0)))
+
+	if len(history.sessions) != maxPickSessions {
+		t.Errorf("got %d sessions, want %d", len(history.sessions), maxPickSessions)
+	}
+
+	if _, ok := history.sessions["0"]; ok {
+		t.Error("least recently used session was kept")
+	}
+
+	if _, ok := history.sessions["new"]; !ok {
+		t.Error("new session was not remembered")
+	}
+}
+
+func TestParseCookingTime(t *testing.T) {
+	tests := []struct {
+		cookingTime string
+		minutes     int
+		ok          bool
+	}{
+		{"45 minutes", 45, true},
+		{"45", 45, true},
+		{"1 hr 15 min", 75, true},
+		{"1h15", 75, true},
+		{"1.5 hours", 90, true},
+		{"1 hour 30 minutes", 90, true},
+		{"30-45 minutes", 45, true},
+		{"10–15 mins", 15, true},
+		{"1 to 2 hours", 120, true},
+		{"2 Hours", 120, true},
+		{"overnight", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.cookingTime, func(t *testing.T) {
+			minutes, ok := parseCookingTime(test.cookingTime)
+			if minutes != test.minutes || ok != test.ok {
+				t.Errorf("got %d %v, want %d %v", minutes, ok, test.minutes, test.ok)
+			}
+		})
+	}
+}
+
+func TestHasTags(t *testing.T) {
+	recipe := Recipe{Tags: []string{"Dinner", "quick"}}
+
+	tests := []struct {
+		tags []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"dinner"}, true},
+		{[]string{"DINNER", "Quick"}, true},
+		{[]string{"dinner", "vegan"}, false},
+	}
+
+	for _, test := range tests {
+		if got := hasTags(recipe, test.tags); got != test.want {
+			t.Errorf("hasTags(%v) = %v, want %v", test.tags, got, test.want)
+		}
+	}
+}
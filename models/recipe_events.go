@@ -0,0 +1,65 @@
+package models
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Subscribes to the recipe events of the cookbook the request is scoped to,
+// resuming after lastEventID when it is set. See events.Bus.Subscribe.
+func SubscribeRecipeEvents(ctx context.Context, lastEventID string) (*events.Subscription, []events.Event, bool, error) {
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return nil, []events.Event{}, false, err
+	}
+
+	scope := events.Scope{UserID: utils.ExtractUserIDFromContext(ctx)}
+	if householdId != nil {
+		scope = events.Scope{HouseholdID: *householdId}
+	}
+
+	subscription, missed, resumed := events.Default.Subscribe(scope, lastEventID)
+	return subscription, missed, resumed, nil
+}
+
+// Helper Functions
+
+// Queries the cookbook a recipe belongs to.
+func findRecipeScope(ctx context.Context, recipeId int64) (events.Scope, error) {
+	var userId int64
+	var householdId *int64
+
+	err := database.DB.QueryRow(ctx, `SELECT user_id, household_id FROM recipes WHERE id = $1`, recipeId).Scan(&userId, &householdId)
+	if err != nil {
+		return events.Scope{}, err
+	}
+
+	if householdId != nil {
+		return events.Scope{HouseholdID: strconv.FormatInt(*householdId, 10)}, nil
+	}
+
+	return events.Scope{UserID: strconv.FormatInt(userId, 10)}, nil
+}
+
+// Announces a change to a recipe to the subscribers of its cookbook. Events
+// are a convenience for open clients, so a recipe that cannot be found is
+// not an error.
+func publishRecipeEvent(ctx context.Context, eventType string, recipeId int64) {
+	scope, err := findRecipeScope(ctx, recipeId)
+	if err != nil {
+		return
+	}
+
+	events.Default.Publish(scope, eventType, recipeId)
+}
+
+// Announces the same change to several recipes.
+func publishRecipeEvents(ctx context.Context, eventType string, recipeIds []int64) {
+	for _, recipeId := range recipeIds {
+		publishRecipeEvent(ctx, eventType, recipeId)
+	}
+}
@@ -2,84 +2,167 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
 	"github.com/mjande/recipes-microservice/utils"
 )
 
 type Recipe struct {
-	ID           int64        `json:"id"`
-	Name         string       `json:"name"`
-	CookingTime  string       `json:"cookingTime"`
-	Description  string       `json:"description"`
-	Instructions string       `json:"instructions"`
-	Ingredients  []Ingredient `json:"ingredients"`
-	Tags         []string     `json:"tags"`
-	UserID       int64        `json:"userId"`
+	ID             int64          `json:"id"`
+	Name           string         `json:"name"`
+	CookingTime    string         `json:"cookingTime"`
+	Servings       int            `json:"servings"`
+	Description    string         `json:"description"`
+	Instructions   string         `json:"instructions"`
+	Ingredients    []Ingredient   `json:"ingredients"`
+	Tags           []string       `json:"tags"`
+	Classification Classification `json:"classification"`
+	Favorite       bool           `json:"favorite"`
+	Rating         *int           `json:"rating"`
+	Notes          []RecipeNote   `json:"notes"`
+	TimesCooked    int            `json:"timesCooked"`
+	LastCooked     *string        `json:"lastCooked"`
+	Photos         []Photo        `json:"photos"`
+	Timers         []Timer        `json:"timers"`
+	UserID         int64          `json:"userId"`
+	HouseholdID    *int64         `json:"householdId"`
+
+	// The text a recipe was written in, when it was created from Cooklang or
+	// Markdown, so that it can be exported again as it was written
+	Source       string `json:"-"`
+	SourceFormat string `json:"-"`
 }
 
-// Queries the database for all recipes (while only loading basic
-// data for index page)
-func ListRecipes(ctx context.Context) ([]Recipe, error) {
-	userId := utils.ExtractUserIDFromContext(ctx)
+// Narrows down and orders the recipes returned by ListRecipes. Recipes must
+// fit every diet in Diets and contain none of the allergens in
+// ExcludeAllergens. When allergens are excluded, recipes whose allergens are
+// not fully known, because they were never classified or have ingredients
+// missing from the catalog, are left out as well. FavoritesOnly keeps only
+// the current user's favorites. Sort is one of the keys of recipeSorts.
+type RecipeFilter struct {
+	Diets            []string
+	ExcludeAllergens []string
+	FavoritesOnly    bool
+	Sort             string
+}
 
-	query := `SELECT id, name, cooking_time, description FROM recipes WHERE user_id = $1`
+var ErrInvalidSort = errors.New("unknown sort order")
 
-	// Execute query
-	rows, err := database.DB.Query(ctx, query, userId)
+// ORDER BY clauses for the ways recipes can be sorted
+var recipeSorts = map[string]string{
+	"":            "r.id",
+	"name":        "r.name, r.id",
+	"rating":      "p.rating DESC NULLS LAST, r.name, r.id",
+	"timesCooked": "COALESCE(l.times_cooked, 0) DESC, r.name, r.id",
+	"lastCooked":  "l.last_cooked DESC NULLS LAST, r.name, r.id",
+}
+
+// Queries the database for all recipes in the personal or household cookbook
+// the request is scoped to (while only loading basic data for index page)
+func ListRecipes(ctx context.Context, filter RecipeFilter) ([]Recipe, error) {
+	err := validateClassification(Classification{Allergens: filter.ExcludeAllergens, Diets: filter.Diets})
 	if err != nil {
 		return []Recipe{}, err
 	}
-	defer rows.Close()
 
-	// Map database response onto recipes slice
-	recipes := []Recipe{}
-	for rows.Next() {
-		var recipe Recipe
+	order, ok := recipeSorts[filter.Sort]
+	if !ok {
+		return []Recipe{}, ErrInvalidSort
+	}
 
-		err = rows.Scan(&recipe.ID, &recipe.Name, &recipe.CookingTime, &recipe.Description)
-		if err != nil {
-			return []Recipe{}, err
-		}
+	scope, scopeId, err := cookbookScope(ctx, "r", "$1")
+	if err != nil {
+		return []Recipe{}, err
+	}
 
-		// Get all tags for this recipe
-		tags, err := FindTagsByRecipe(ctx, recipe.ID)
-		if err != nil {
-			return []Recipe{}, err
-		}
+	userId := utils.ExtractUserIDFromContext(ctx)
 
-		// Add the tag name to this recipe
-		for _, tag := range tags {
-			recipe.Tags = append(recipe.Tags, tag.Name)
-		}
+	query := listedRecipesQuery("$4") + `
+		WHERE ` + scope + ` AND r.diets @> $2 AND NOT r.allergens && $3
+			AND (cardinality($3::text[]) = 0 OR (r.classified_at IS NOT NULL AND r.unclassified = '{}'))
+			AND (NOT $5::boolean OR p.favorite)
+		ORDER BY ` + order
 
-		recipes = append(recipes, recipe)
+	diets, excludeAllergens := filter.Diets, filter.ExcludeAllergens
+	if diets == nil {
+		diets = []string{}
+	}
+	if excludeAllergens == nil {
+		excludeAllergens = []string{}
 	}
 
-	if err = rows.Err(); err != nil {
+	// Execute query
+	rows, err := database.DB.Query(ctx, query, scopeId, diets, excludeAllergens, userId, filter.FavoritesOnly)
+	if err != nil {
 		return []Recipe{}, err
 	}
 
-	return recipes, nil
+	return scanListedRecipes(ctx, rows)
+}
+
+// Queries all recipes in the cookbook the request is scoped to, along with
+// their ingredients.
+func ListRecipesWithIngredients(ctx context.Context) ([]Recipe, error) {
+	return listRecipesWithIngredients(ctx, RecipeFilter{})
 }
 
 // Queries the database for an ingredient that has the given id.
 func FindRecipe(ctx context.Context, id int64) (Recipe, error) {
-	query := `SELECT id, name, cooking_time, description, instructions FROM recipes WHERE id = $1`
+	// Check that the user owns the recipe or has had it shared with them
+	err := authorizeRecipe(ctx, id, RoleViewer)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	recipe, err := findRecipe(ctx, id)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	err = loadRecipePreferences(ctx, &recipe)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	err = loadCookStats(ctx, &recipe)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	recipes := []Recipe{recipe}
+	err = loadRecipePhotos(ctx, recipes)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	return recipes[0], nil
+}
+
+// Loads a recipe with its ingredients and tags without checking whether the
+// current user may see it. Callers are responsible for authorization.
+func findRecipe(ctx context.Context, id int64) (Recipe, error) {
+	query := `SELECT id, name, cooking_time, servings, description, instructions, user_id, household_id,
+			allergens, diets, unclassified, classification_overridden,
+			COALESCE(source, ''), COALESCE(source_format, '')
+		FROM recipes WHERE id = $1`
 
 	// Query the database
 	result := database.DB.QueryRow(ctx, query, id)
 
 	// Scan database result into recipe object
 	var recipe Recipe
-	err := result.Scan(&recipe.ID, &recipe.Name, &recipe.CookingTime, &recipe.Description, &recipe.Instructions)
+	err := result.Scan(&recipe.ID, &recipe.Name, &recipe.CookingTime, &recipe.Servings, &recipe.Description, &recipe.Instructions, &recipe.UserID, &recipe.HouseholdID,
+		&recipe.Classification.Allergens, &recipe.Classification.Diets, &recipe.Classification.Unclassified,
+		&recipe.Classification.Overridden, &recipe.Source, &recipe.SourceFormat)
 	if err != nil {
 		return Recipe{}, err
 	}
 
-	ingredientsQuery := `SELECT id, name, quantity, unit FROM ingredients WHERE recipe_id = $1`
+	ingredientsQuery := `SELECT id, name, quantity, unit, catalog_id FROM ingredients WHERE recipe_id = $1 ORDER BY id`
 
 	// Get all ingredients used in this recipe
 	rows, err := database.DB.Query(ctx, ingredientsQuery, recipe.ID)
@@ -93,7 +176,7 @@ func FindRecipe(ctx context.Context, id int64) (Recipe, error) {
 	for rows.Next() {
 		var ingredient Ingredient
 
-		err = rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Quantity, &ingredient.Unit)
+		err = rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Quantity, &ingredient.Unit, &ingredient.CatalogID)
 		if err != nil {
 			return Recipe{}, err
 		}
@@ -115,23 +198,47 @@ func FindRecipe(ctx context.Context, id int64) (Recipe, error) {
 		tagStrs = append(tagStrs, tag.Name)
 	}
 
-	// Add ingredients and tags to recipe object
+	timers, err := findTimers(ctx, recipe.ID)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	// Add ingredients, tags and timers to recipe object
 	recipe.Ingredients = ingredients
 	recipe.Tags = tagStrs
+	recipe.Timers = timers
 
 	return recipe, nil
 }
 
+// Creates a recipe in the personal or household cookbook the request is
+// scoped to.
 func CreateRecipe(ctx context.Context, recipe Recipe) (int64, error) {
 	userId := utils.ExtractUserIDFromContext(ctx)
-	query := `INSERT INTO recipes (name, user_id, cooking_time, description, instructions) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	err = validateTimers(recipe.Timers)
+	if err != nil {
+		return -1, err
+	}
+
+	var source, sourceFormat *string
+	if recipe.Source != "" {
+		source, sourceFormat = &recipe.Source, &recipe.SourceFormat
+	}
+
+	query := `INSERT INTO recipes (name, user_id, household_id, cooking_time, servings, description, instructions, source, source_format) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
 
 	// Send query
-	row := database.DB.QueryRow(ctx, query, recipe.Name, userId, recipe.CookingTime, recipe.Description, recipe.Instructions)
+	row := database.DB.QueryRow(ctx, query, recipe.Name, userId, householdId, recipe.CookingTime, recipe.Servings, recipe.Description, recipe.Instructions, source, sourceFormat)
 
 	// Get id of created recipe
 	var id int64
-	err := row.Scan(&id)
+	err = row.Scan(&id)
 	if err != nil {
 		return -1, err
 	}
@@ -149,20 +256,46 @@ func CreateRecipe(ctx context.Context, recipe Recipe) (int64, error) {
 
 	// Create tags
 	for _, tag := range recipe.Tags {
+		if NormalizeTag(tag) == "" {
+			continue
+		}
+
 		_, err := CreateTag(ctx, id, tag)
 		if err != nil {
 			return -1, err
 		}
 	}
 
+	err = replaceTimers(ctx, id, recipe.Timers)
+	if err != nil {
+		return -1, err
+	}
+
+	err = classifyRecipe(ctx, id)
+	if err != nil {
+		return -1, err
+	}
+
+	publishRecipeEvent(ctx, events.RecipeCreated, id)
+
 	return id, nil
 }
 
 func UpdateRecipe(ctx context.Context, id int64, recipe Recipe) (int64, error) {
-	query := `UPDATE recipes SET name = $1, cooking_time = $2, description = $3, instructions = $4 WHERE id = $5 RETURNING id`
+	err := authorizeRecipe(ctx, id, RoleEditor)
+	if err != nil {
+		return -1, err
+	}
+
+	err = validateTimers(recipe.Timers)
+	if err != nil {
+		return -1, err
+	}
+
+	query := `UPDATE recipes SET name = $1, cooking_time = $2, servings = $3, description = $4, instructions = $5 WHERE id = $6 RETURNING id`
 
 	// Send query
-	_, err := database.DB.Exec(ctx, query, recipe.Name, recipe.CookingTime, recipe.Description, recipe.Instructions, id)
+	_, err = database.DB.Exec(ctx, query, recipe.Name, recipe.CookingTime, recipe.Servings, recipe.Description, recipe.Instructions, id)
 	if err != nil {
 		return -1, nil
 	}
@@ -177,21 +310,172 @@ func UpdateRecipe(ctx context.Context, id int64, recipe Recipe) (int64, error) {
 		return -1, err
 	}
 
+	// Timers are left alone by updates that do not mention them
+	if recipe.Timers != nil {
+		err = replaceTimers(ctx, id, recipe.Timers)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	err = classifyRecipe(ctx, id)
+	if err != nil {
+		return -1, err
+	}
+
+	publishRecipeEvent(ctx, events.RecipeUpdated, id)
+
 	return id, nil
 }
 
 func DeleteRecipe(ctx context.Context, id int64) error {
+	// Only the owner may delete a recipe, even if others can edit it
+	err := authorizeRecipe(ctx, id, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	// Remove the photo files first, so that a failure leaves the recipe in
+	// place to retry the deletion
+	photos, err := findPhotos(ctx, []int64{id})
+	if err != nil {
+		return err
+	}
+
+	for _, photo := range photos[id] {
+		err = deletePhotoBlobs(ctx, photo)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Find the cookbook to announce the deletion to while the recipe exists
+	scope, err := findRecipeScope(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	query := `DELETE FROM recipes WHERE id = $1`
 
-	_, err := database.DB.Exec(ctx, query, id)
+	_, err = database.DB.Exec(ctx, query, id)
 	if err != nil {
 		return err
 	}
 
+	events.Default.Publish(scope, events.RecipeDeleted, id)
+
 	return nil
 }
 
 // Helper Functions
+
+// Returns the SELECT and FROM clauses of a query listing recipes as "r",
+// along with the preferences and cook stats of the user whose ID is bound to
+// userPlaceholder. Rows are read with scanListedRecipes.
+func listedRecipesQuery(userPlaceholder string) string {
+	return `SELECT r.id, r.name, r.cooking_time, r.servings, r.description, r.user_id, r.household_id,
+			r.allergens, r.diets, r.unclassified, r.classification_overridden,
+			COALESCE(p.favorite, FALSE), p.rating, COALESCE(l.times_cooked, 0), l.last_cooked::text
+		FROM recipes r
+		LEFT JOIN recipe_preferences p ON p.recipe_id = r.id AND p.user_id = ` + userPlaceholder + `
+		LEFT JOIN (
+			SELECT recipe_id, COUNT(*) AS times_cooked, MAX(cooked_on) AS last_cooked
+			FROM cook_log
+			WHERE user_id = ` + userPlaceholder + `
+			GROUP BY recipe_id
+		) l ON l.recipe_id = r.id`
+}
+
+// Maps the rows of a query built with listedRecipesQuery onto recipes, and
+// adds their tags and photos. The rows are closed.
+func scanListedRecipes(ctx context.Context, rows pgx.Rows) ([]Recipe, error) {
+	defer rows.Close()
+
+	recipes := []Recipe{}
+	for rows.Next() {
+		var recipe Recipe
+
+		err := rows.Scan(&recipe.ID, &recipe.Name, &recipe.CookingTime, &recipe.Servings, &recipe.Description,
+			&recipe.UserID, &recipe.HouseholdID,
+			&recipe.Classification.Allergens, &recipe.Classification.Diets, &recipe.Classification.Unclassified,
+			&recipe.Classification.Overridden, &recipe.Favorite, &recipe.Rating,
+			&recipe.TimesCooked, &recipe.LastCooked)
+		if err != nil {
+			return []Recipe{}, err
+		}
+
+		recipes = append(recipes, recipe)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []Recipe{}, err
+	}
+	rows.Close()
+
+	// Add tags to each recipe once the rows have been released
+	for i := range recipes {
+		tags, err := FindTagsByRecipe(ctx, recipes[i].ID)
+		if err != nil {
+			return []Recipe{}, err
+		}
+
+		for _, tag := range tags {
+			recipes[i].Tags = append(recipes[i].Tags, tag.Name)
+		}
+	}
+
+	err := loadRecipePhotos(ctx, recipes)
+	if err != nil {
+		return []Recipe{}, err
+	}
+
+	return recipes, nil
+}
+
+// Queries the recipes ListRecipes returns for a filter, along with their
+// ingredients.
+func listRecipesWithIngredients(ctx context.Context, filter RecipeFilter) ([]Recipe, error) {
+	recipes, err := ListRecipes(ctx, filter)
+	if err != nil {
+		return []Recipe{}, err
+	}
+
+	recipeIds := make([]int64, len(recipes))
+	for i, recipe := range recipes {
+		recipeIds[i] = recipe.ID
+	}
+
+	query := `SELECT id, name, recipe_id, quantity, unit, catalog_id FROM ingredients WHERE recipe_id = ANY($1) ORDER BY id`
+
+	rows, err := database.DB.Query(ctx, query, recipeIds)
+	if err != nil {
+		return []Recipe{}, err
+	}
+	defer rows.Close()
+
+	ingredients := map[int64][]Ingredient{}
+	for rows.Next() {
+		var ingredient Ingredient
+
+		err = rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.RecipeID, &ingredient.Quantity, &ingredient.Unit, &ingredient.CatalogID)
+		if err != nil {
+			return []Recipe{}, err
+		}
+
+		ingredients[ingredient.RecipeID] = append(ingredients[ingredient.RecipeID], ingredient)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Recipe{}, err
+	}
+
+	for i := range recipes {
+		recipes[i].Ingredients = ingredients[recipes[i].ID]
+	}
+
+	return recipes, nil
+}
+
 func updateRecipeIngredients(ctx context.Context, recipeId int64, recipe Recipe) error {
 	ingredientsToDeleteSlice, err := ListIngredientsByRecipe(ctx, recipeId)
 	if err != nil {
@@ -261,6 +545,10 @@ func updateRecipeTags(ctx context.Context, recipeId int64, recipe Recipe) error
 	}
 
 	for _, tag := range recipe.Tags {
+		if NormalizeTag(tag) == "" {
+			continue
+		}
+
 		// Check if recipe previously included tag
 		prevTag, err := FindTag(ctx, recipeId, tag)
 		if err != nil && err == pgx.ErrNoRows {
@@ -276,11 +564,11 @@ func updateRecipeTags(ctx context.Context, recipeId int64, recipe Recipe) error
 		}
 	}
 
-	deleteQuery := `DELETE FROM recipe_tags WHERE id = $1`
+	deleteQuery := `DELETE FROM recipe_tags WHERE recipe_id = $1 AND tag_id = $2`
 
 	for id, delete := range tagsToDelete {
 		if delete {
-			_, err = database.DB.Exec(ctx, deleteQuery, id)
+			_, err = database.DB.Exec(ctx, deleteQuery, recipeId, id)
 			if err != nil {
 				return err
 			}
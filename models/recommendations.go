@@ -0,0 +1,366 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mjande/recipes-microservice/normalizer"
+)
+
+// How far back the cook log is searched for recipes the user enjoyed.
+const enjoyedWindowDays = 90
+
+// Lowest rating that counts as enjoying a recipe.
+const enjoyedRating = 4
+
+// Recipes made within this many days are penalized, so that suggestions
+// rotate.
+const recentlyCookedDays = 7
+
+// Number of days without making a recipe after which its recency score stops
+// growing.
+const maxRecencyDays = 60
+
+// How much each factor contributes to a recommendation's score. Each factor
+// scores between -1 and 1 before weighting.
+var recommendationWeights = map[string]float64{
+	"similarity": 3,
+	"recency":    2,
+	"rating":     2,
+	"seasonal":   1,
+}
+
+// Months in which ingredients are in season, for temperate regions of the
+// northern hemisphere. Keys are normalized ingredient names.
+var seasonalIngredients = map[string][]time.Month{
+	"apple":            {time.September, time.October, time.November},
+	"artichoke":        {time.March, time.April, time.May},
+	"asparagus":        {time.March, time.April, time.May, time.June},
+	"basil":            {time.June, time.July, time.August, time.September},
+	"bell pepper":      {time.July, time.August, time.September},
+	"blueberry":        {time.June, time.July, time.August},
+	"brussels sprout":  {time.October, time.November, time.December, time.January, time.February},
+	"butternut squash": {time.September, time.October, time.November, time.December},
+	"cabbage":          {time.November, time.December, time.January, time.February, time.March},
+	"cauliflower":      {time.September, time.October, time.November},
+	"cherry":           {time.June, time.July},
+	"corn":             {time.July, time.August, time.September},
+	"cranberry":        {time.October, time.November, time.December},
+	"cucumber":         {time.June, time.July, time.August, time.September},
+	"eggplant":         {time.July, time.August, time.September},
+	"grapefruit":       {time.December, time.January, time.February, time.March},
+	"green bean":       {time.June, time.July, time.August, time.September},
+	"kale":             {time.October, time.November, time.December, time.January, time.February, time.March},
+	"leek":             {time.October, time.November, time.December, time.January, time.February, time.March},
+	"lemon":            {time.December, time.January, time.February, time.March, time.April},
+	"mushroom":         {time.September, time.October, time.November},
+	"orange":           {time.December, time.January, time.February, time.March},
+	"parsnip":          {time.October, time.November, time.December, time.January, time.February, time.March},
+	"pea":              {time.May, time.June, time.July},
+	"peach":            {time.June, time.July, time.August},
+	"pear":             {time.September, time.October, time.November},
+	"pumpkin":          {time.September, time.October, time.November},
+	"radish":           {time.April, time.May, time.June},
+	"rhubarb":          {time.April, time.May, time.June},
+	"spinach":          {time.March, time.April, time.May, time.September, time.October, time.November},
+	"strawberry":       {time.May, time.June, time.July},
+	"sweet potato":     {time.October, time.November, time.December},
+	"tomato":           {time.July, time.August, time.September},
+	"watermelon":       {time.June, time.July, time.August},
+	"zucchini":         {time.June, time.July, time.August, time.September},
+}
+
+// A recipe suggested to the current user, with its score and the reasons
+// that make it up.
+type Recommendation struct {
+	Recipe  Recipe                 `json:"recipe"`
+	Score   float64                `json:"score"`
+	Reasons []RecommendationReason `json:"reasons"`
+}
+
+// One factor of a recommendation's score. Score is the factor's weighted
+// contribution to the total.
+type RecommendationReason struct {
+	Factor      string  `json:"factor"`
+	Score       float64 `json:"score"`
+	Explanation string  `json:"explanation"`
+}
+
+// Suggests recipes from the cookbook the request is scoped to for the
+// current user to cook, best first. At most limit recipes are returned.
+func RecommendRecipes(ctx context.Context, limit int) ([]Recommendation, error) {
+	recipes, err := ListRecipesWithIngredients(ctx)
+	if err != nil {
+		return []Recommendation{}, err
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -enjoyedWindowDays).Format(DateLayout)
+
+	history, err := ListCookLog(ctx, from, "")
+	if err != nil {
+		return []Recommendation{}, err
+	}
+
+	recommendations := scoreRecipes(recipes, history, now)
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	return recommendations, nil
+}
+
+// Helper Functions
+
+// Scores every recipe against the user's recent cook log as of now, and
+// orders them from best to worst. Ties are broken by name and then id, so the
+// result only depends on the arguments.
+func scoreRecipes(recipes []Recipe, history []CookLogEntry, now time.Time) []Recommendation {
+	enjoyed := enjoyedRecipes(recipes, history)
+
+	recommendations := make([]Recommendation, 0, len(recipes))
+	for _, recipe := range recipes {
+		reasons := []RecommendationReason{}
+		for _, reason := range []RecommendationReason{
+			similarityReason(recipe, enjoyed),
+			recencyReason(recipe, now),
+			ratingReason(recipe),
+			seasonalReason(recipe, now.Month()),
+		} {
+			if reason.Score != 0 {
+				reasons = append(reasons, reason)
+			}
+		}
+
+		var score float64
+		for _, reason := range reasons {
+			score += reason.Score
+		}
+
+		recommendations = append(recommendations, Recommendation{
+			Recipe:  recipe,
+			Score:   roundScore(score),
+			Reasons: reasons,
+		})
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+
+		if a.Recipe.Name != b.Recipe.Name {
+			return a.Recipe.Name < b.Recipe.Name
+		}
+
+		return a.Recipe.ID < b.Recipe.ID
+	})
+
+	return recommendations
+}
+
+// Returns the recipes the user enjoyed making recently: those rated highly
+// in the cook log, or, when the entry has no rating, those the user rated
+// highly or marked as a favorite.
+func enjoyedRecipes(recipes []Recipe, history []CookLogEntry) []Recipe {
+	recipesById := map[int64]Recipe{}
+	for _, recipe := range recipes {
+		recipesById[recipe.ID] = recipe
+	}
+
+	enjoyed := []Recipe{}
+	seen := map[int64]bool{}
+	for _, entry := range history {
+		recipe, ok := recipesById[entry.RecipeID]
+		if !ok || seen[recipe.ID] {
+			continue
+		}
+
+		liked := entry.Rating != nil && *entry.Rating >= enjoyedRating
+		if entry.Rating == nil {
+			liked = recipe.Favorite || (recipe.Rating != nil && *recipe.Rating >= enjoyedRating)
+		}
+
+		if liked {
+			enjoyed = append(enjoyed, recipe)
+			seen[recipe.ID] = true
+		}
+	}
+
+	return enjoyed
+}
+
+// Scores how much a recipe shares ingredients and tags with the most similar
+// recipe the user enjoyed, other than itself.
+func similarityReason(recipe Recipe, enjoyed []Recipe) RecommendationReason {
+	var best float64
+	var bestName string
+	for _, other := range enjoyed {
+		if other.ID == recipe.ID {
+			continue
+		}
+
+		similarity := 0.7*jaccard(ingredientKeys(recipe), ingredientKeys(other)) +
+			0.3*jaccard(lowerAll(recipe.Tags), lowerAll(other.Tags))
+		if similarity > best {
+			best, bestName = similarity, other.Name
+		}
+	}
+
+	return RecommendationReason{
+		Factor:      "similarity",
+		Score:       roundScore(recommendationWeights["similarity"] * best),
+		Explanation: fmt.Sprintf("Similar to %s, which you enjoyed recently", bestName),
+	}
+}
+
+// Scores how long it has been since the user last made a recipe. Recipes
+// never made get a moderate score, and those made very recently are
+// penalized.
+func recencyReason(recipe Recipe, now time.Time) RecommendationReason {
+	reason := RecommendationReason{Factor: "recency"}
+
+	if recipe.LastCooked == nil {
+		reason.Score = roundScore(recommendationWeights["recency"] * 0.5)
+		reason.Explanation = "You haven't made this yet"
+		return reason
+	}
+
+	lastCooked, err := time.Parse(DateLayout, *recipe.LastCooked)
+	if err != nil {
+		return reason
+	}
+
+	today, _ := time.Parse(DateLayout, now.Format(DateLayout))
+	days := int(today.Sub(lastCooked).Hours() / 24)
+
+	var factor float64
+	if days < recentlyCookedDays {
+		factor = -float64(recentlyCookedDays-days) / recentlyCookedDays
+		reason.Explanation = fmt.Sprintf("You made this %s ago", pluralize(days, "day"))
+		if days == 0 {
+			reason.Explanation = "You made this today"
+		}
+	} else {
+		factor = float64(min(days, maxRecencyDays)) / maxRecencyDays
+		reason.Explanation = fmt.Sprintf("You haven't made this in %s", pluralize(days, "day"))
+	}
+
+	reason.Score = roundScore(recommendationWeights["recency"] * factor)
+	return reason
+}
+
+// Scores the user's rating of a recipe, and whether it is a favorite.
+func ratingReason(recipe Recipe) RecommendationReason {
+	var factor float64
+	explanations := []string{}
+
+	if recipe.Rating != nil {
+		factor = float64(*recipe.Rating-3) / 2
+		explanations = append(explanations, fmt.Sprintf("You rated this %s", pluralize(*recipe.Rating, "star")))
+	}
+
+	if recipe.Favorite {
+		factor = min(factor+0.5, 1)
+		explanations = append(explanations, "It's one of your favorites")
+	}
+
+	return RecommendationReason{
+		Factor:      "rating",
+		Score:       roundScore(recommendationWeights["rating"] * factor),
+		Explanation: strings.Join(explanations, ". "),
+	}
+}
+
+// Scores how many of a recipe's ingredients are in season in the given
+// month. Three or more seasonal ingredients give the full score.
+func seasonalReason(recipe Recipe, month time.Month) RecommendationReason {
+	inSeason := []string{}
+	for _, ingredient := range recipe.Ingredients {
+		for _, candidate := range normalizer.Candidates(ingredient.Name) {
+			if slices.Contains(seasonalIngredients[candidate], month) {
+				if !slices.Contains(inSeason, candidate) {
+					inSeason = append(inSeason, candidate)
+				}
+				break
+			}
+		}
+	}
+
+	return RecommendationReason{
+		Factor:      "seasonal",
+		Score:       roundScore(recommendationWeights["seasonal"] * float64(min(len(inSeason), 3)) / 3),
+		Explanation: "Uses ingredients in season: " + strings.Join(inSeason, ", "),
+	}
+}
+
+// Returns the set of ingredients in a recipe, identified by catalog entry
+// when linked and by normalized name otherwise.
+func ingredientKeys(recipe Recipe) []string {
+	keys := []string{}
+	for _, ingredient := range recipe.Ingredients {
+		key := normalizer.Normalize(ingredient.Name)
+		if ingredient.CatalogID != nil {
+			key = fmt.Sprintf("#%d", *ingredient.CatalogID)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Returns the Jaccard similarity of two sets: the size of their intersection
+// over the size of their union.
+func jaccard(a []string, b []string) float64 {
+	union := map[string]bool{}
+	inA := map[string]bool{}
+	for _, item := range a {
+		union[item] = true
+		inA[item] = true
+	}
+
+	var intersection int
+	counted := map[string]bool{}
+	for _, item := range b {
+		if inA[item] && !counted[item] {
+			intersection++
+			counted[item] = true
+		}
+		union[item] = true
+	}
+
+	if len(union) == 0 {
+		return 0
+	}
+
+	return float64(intersection) / float64(len(union))
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+
+	return lowered
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// Rounds a score to two decimal places.
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func TestRecencyReason(t *testing.T) {
+	now := time.Date(2026, time.June, 15, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		lastCooked  *string
+		score       float64
+		explanation string
+	}{
+		{"never made", nil, 1, "You haven't made this yet"},
+		{"made today", stringPtr("2026-06-15"), -2, "You made this today"},
+		{"made this week", stringPtr("2026-06-12"), -1.14, "You made this 3 days ago"},
+		{"made a month ago", stringPtr("2026-05-16"), 1, "You haven't made this in 30 days"},
+		{"made long ago", stringPtr("2025-01-01"), 2, "You haven't made this in 530 days"},
+		{"unreadable date", stringPtr("last week"), 0, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := recencyReason(Recipe{LastCooked: test.lastCooked}, now)
+			if reason.Score != test.score || reason.Explanation != test.explanation {
+				t.Errorf("got %v %q, want %v %q", reason.Score, reason.Explanation, test.score, test.explanation)
+			}
+		})
+	}
+}
+
+func TestRatingReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		rating   *int
+		favorite bool
+		score    float64
+	}{
+		{"unrated", nil, false, 0},
+		{"five stars", intPtr(5), false, 2},
+		{"three stars", intPtr(3), false, 0},
+		{"one star", intPtr(1), false, -2},
+		{"favorite only", nil, true, 1},
+		{"three star favorite", intPtr(3), true, 1},
+		{"five star favorite is capped", intPtr(5), true, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := ratingReason(Recipe{Rating: test.rating, Favorite: test.favorite})
+			if reason.Score != test.score {
+				t.Errorf("got %v, want %v", reason.Score, test.score)
+			}
+		})
+	}
+}
+
+func TestSeasonalReason(t *testing.T) {
+	tests := []struct {
+		name        string
+		ingredients []string
+		month       time.Month
+		score       float64
+	}{
+		{"nothing in season", []string{"rice", "salt"}, time.July, 0},
+		{"one in season", []string{"tomato", "rice"}, time.July, 0.33},
+		{"counted once", []string{"tomato", "tomatoes"}, time.July, 0.33},
+		{"capped at three", []string{"tomato", "corn", "zucchini", "cucumber"}, time.July, 1},
+		{"out of season", []string{"tomato", "corn"}, time.December, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recipe := Recipe{}
+			for _, name := range test.ingredients {
+				recipe.Ingredients = append(recipe.Ingredients, Ingredient{Name: name})
+			}
+
+			reason := seasonalReason(recipe, test.month)
+			if reason.Score != test.score {
+				t.Errorf("got %v, want %v", reason.Score, test.score)
+			}
+		})
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want float64
+	}{
+		{"both empty", nil, nil, 0},
+		{"disjoint", []string{"a"}, []string{"b"}, 0},
+		{"identical", []string{"a", "b"}, []string{"b", "a"}, 1},
+		{"overlapping", []string{"a", "b"}, []string{"b", "c"}, 1.0 / 3},
+		{"duplicates count once", []string{"a", "a"}, []string{"a", "a"}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := jaccard(test.a, test.b); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScoreRecipesRanking(t *testing.T) {
+	now := time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC)
+	today := "2026-01-10"
+
+	pasta := []Ingredient{{Name: "spaghetti"}, {Name: "garlic"}, {Name: "olive oil"}}
+	recipes := []Recipe{
+		{ID: 1, Name: "Aglio e olio", Ingredients: pasta, LastCooked: &today},
+		{ID: 2, Name: "Garlic spaghetti", Ingredients: pasta},
+		{ID: 3, Name: "Favorite soup", Favorite: true, Rating: intPtr(5)},
+		{ID: 4, Name: "Toast"},
+		{ID: 5, Name: "Bread"},
+		{ID: 6, Name: "Disliked stew", Rating: intPtr(1)},
+	}
+
+	// Aglio e olio was enjoyed today, so similar recipes rank highly while
+	// it rotates out
+	history := []CookLogEntry{{RecipeID: 1, CookedOn: today, Rating: intPtr(5)}}
+
+	recommendations := scoreRecipes(recipes, history, now)
+
+	want := []struct {
+		id    int64
+		score float64
+	}{
+		{2, 3.1}, // similarity 2.1 + never made 1
+		{3, 3},   // rating 2 + never made 1
+		{5, 1},   // never made, tied with Toast and first by name
+		{4, 1},   // never made
+		{6, -1},  // rating -2 + never made 1
+		{1, -2},  // made today
+	}
+
+	if len(recommendations) != len(want) {
+		t.Fatalf("got %d recommendations, want %d", len(recommendations), len(want))
+	}
+
+	for i, expected := range want {
+		got := recommendations[i]
+		if got.Recipe.ID != expected.id || got.Score != expected.score {
+			t.Errorf("position %d: got recipe %d scoring %v, want recipe %d scoring %v",
+				i, got.Recipe.ID, got.Score, expected.id, expected.score)
+		}
+	}
+}
+
+func TestScoreRecipesOmitsZeroReasons(t *testing.T) {
+	now := time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC)
+
+	recommendations := scoreRecipes([]Recipe{{ID: 1, Name: "Toast"}}, nil, now)
+
+	reasons := recommendations[0].Reasons
+	if len(reasons) != 1 || reasons[0].Factor != "recency" {
+		t.Errorf("got reasons %+v, want only recency", reasons)
+	}
+}
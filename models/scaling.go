@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"math"
+
+	"github.com/mjande/recipes-microservice/units"
+)
+
+var ErrInvalidScale = errors.New("scale must be a positive number")
+
+// Returns a copy of a recipe with its ingredient quantities multiplied by
+// scale and, when system is set, shown in that system's units. Ingredients
+// with units that are not recognized are only scaled.
+func ScaleRecipe(recipe Recipe, scale float64, system units.System) (Recipe, error) {
+	if scale <= 0 || math.IsInf(scale, 0) || math.IsNaN(scale) {
+		return Recipe{}, ErrInvalidScale
+	}
+
+	if recipe.Servings > 0 {
+		recipe.Servings = max(int(math.Round(float64(recipe.Servings)*scale)), 1)
+	}
+
+	ingredients := make([]Ingredient, len(recipe.Ingredients))
+	for i, ingredient := range recipe.Ingredients {
+		quantity, unit := convertToSystem(float64(ingredient.Quantity)*scale, ingredient.Unit, system)
+		ingredient.Quantity = float32(quantity)
+		ingredient.Unit = unit
+		ingredients[i] = ingredient
+	}
+	recipe.Ingredients = ingredients
+
+	return recipe, nil
+}
+
+// Returns a copy of a shopping list with quantities shown in a system's
+// units.
+func ConvertShoppingList(items []ShoppingListItem, system units.System) []ShoppingListItem {
+	converted := make([]ShoppingListItem, len(items))
+	for i, item := range items {
+		quantity, unit := convertToSystem(float64(item.Quantity), item.Unit, system)
+		item.Quantity = float32(quantity)
+		item.Unit = unit
+		converted[i] = item
+	}
+
+	return converted
+}
+
+// Helper Functions
+
+// Converts a quantity into a system's units, leaving it alone when no system
+// is given or the unit is not recognized.
+func convertToSystem(quantity float64, unit string, system units.System) (float64, string) {
+	if system == "" {
+		return quantity, unit
+	}
+
+	parsed, ok := units.Parse(unit)
+	if !ok {
+		return quantity, unit
+	}
+
+	converted, target := units.ToSystem(quantity, parsed, system)
+	if target == parsed {
+		return quantity, unit
+	}
+
+	return converted, target.Name
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mjande/recipes-microservice/database"
+)
+
+var ErrShareLinkExpired = errors.New("this share link has expired")
+
+type ShareLink struct {
+	ID        int64      `json:"id"`
+	RecipeID  int64      `json:"recipeId"`
+	Token     string     `json:"token"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// Returns all public share links for a recipe. Only the recipe's owner may
+// list them.
+func ListShareLinks(ctx context.Context, recipeId int64) ([]ShareLink, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleOwner)
+	if err != nil {
+		return []ShareLink{}, err
+	}
+
+	query := `SELECT id, recipe_id, token, created_at, expires_at FROM recipe_share_links WHERE recipe_id = $1`
+
+	rows, err := database.DB.Query(ctx, query, recipeId)
+	if err != nil {
+		return []ShareLink{}, err
+	}
+	defer rows.Close()
+
+	links := []ShareLink{}
+	for rows.Next() {
+		var link ShareLink
+
+		err = rows.Scan(&link.ID, &link.RecipeID, &link.Token, &link.CreatedAt, &link.ExpiresAt)
+		if err != nil {
+			return []ShareLink{}, err
+		}
+
+		links = append(links, link)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []ShareLink{}, err
+	}
+
+	return links, nil
+}
+
+// Creates a new public share link for a recipe with an unguessable token. A
+// nil expiry creates a link that is valid until it is revoked.
+func CreateShareLink(ctx context.Context, recipeId int64, expiresAt *time.Time) (ShareLink, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleOwner)
+	if err != nil {
+		return ShareLink{}, err
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		return ShareLink{}, err
+	}
+
+	query := `INSERT INTO recipe_share_links (recipe_id, token, expires_at) VALUES ($1, $2, $3)
+		RETURNING id, recipe_id, token, created_at, expires_at`
+
+	row := database.DB.QueryRow(ctx, query, recipeId, token, expiresAt)
+
+	var link ShareLink
+	err = row.Scan(&link.ID, &link.RecipeID, &link.Token, &link.CreatedAt, &link.ExpiresAt)
+	if err != nil {
+		return ShareLink{}, err
+	}
+
+	return link, nil
+}
+
+// Revokes a public share link so its token can no longer be used.
+func DeleteShareLink(ctx context.Context, recipeId int64, linkId int64) error {
+	err := authorizeRecipe(ctx, recipeId, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM recipe_share_links WHERE id = $1 AND recipe_id = $2`
+
+	tag, err := database.DB.Exec(ctx, query, linkId, recipeId)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// Loads the recipe a public share link points to. This does not require an
+// authenticated user, so it must only be used for read-only views.
+func FindRecipeByShareToken(ctx context.Context, token string) (Recipe, error) {
+	query := `SELECT recipe_id, expires_at FROM recipe_share_links WHERE token = $1`
+
+	var recipeId int64
+	var expiresAt *time.Time
+	err := database.DB.QueryRow(ctx, query, token).Scan(&recipeId, &expiresAt)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return Recipe{}, ErrShareLinkExpired
+	}
+
+	recipe, err := findRecipe(ctx, recipeId)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	recipes := []Recipe{recipe}
+	err = loadRecipePhotos(ctx, recipes)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	return recipes[0], nil
+}
+
+// Helper Functions
+
+// Generates a random, URL-safe token with 256 bits of entropy.
+func generateShareToken() (string, error) {
+	bytes := make([]byte, 32)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
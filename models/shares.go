@@ -0,0 +1,177 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+// Roles a user can hold on a recipe, from least to most privileged.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+var ErrForbidden = errors.New("you do not have permission to perform this action")
+var ErrInvalidRole = errors.New("role must be either viewer or editor")
+
+type Share struct {
+	ID       int64  `json:"id"`
+	RecipeID int64  `json:"recipeId"`
+	UserID   int64  `json:"userId"`
+	Role     string `json:"role"`
+}
+
+// Returns the role the current user holds on the given recipe. Returns
+// pgx.ErrNoRows if the recipe does not exist and ErrForbidden if the user
+// has no access to it.
+func FindRecipeRole(ctx context.Context, recipeId int64) (string, error) {
+	userId := utils.ExtractUserIDFromContext(ctx)
+	// Household recipes belong to the household: its owners own the recipe
+	// and its members may edit it. Personal recipes belong to their creator.
+	query := `SELECT CASE
+			WHEN m.role = 'owner' THEN 'owner'
+			WHEN m.role = 'member' THEN 'editor'
+			WHEN r.household_id IS NULL AND r.user_id = $2 THEN 'owner'
+			ELSE COALESCE(s.role, '')
+		END
+		FROM recipes r
+		LEFT JOIN household_members m ON m.household_id = r.household_id AND m.user_id = $2
+		LEFT JOIN recipe_shares s ON s.recipe_id = r.id AND s.user_id = $2
+		WHERE r.id = $1`
+
+	var role string
+	err := database.DB.QueryRow(ctx, query, recipeId, userId).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+
+	if role == "" {
+		return "", ErrForbidden
+	}
+
+	return role, nil
+}
+
+// Checks that the current user holds at least the given role on a recipe.
+func authorizeRecipe(ctx context.Context, recipeId int64, minRole string) error {
+	role, err := FindRecipeRole(ctx, recipeId)
+	if err != nil {
+		return err
+	}
+
+	if roleRanks[role] < roleRanks[minRole] {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// Queries the database for all recipes other users have shared with the
+// current user.
+func ListSharedRecipes(ctx context.Context) ([]Recipe, error) {
+	userId := utils.ExtractUserIDFromContext(ctx)
+	query := listedRecipesQuery("$1") + `
+		JOIN recipe_shares s ON s.recipe_id = r.id
+		WHERE s.user_id = $1
+		ORDER BY r.name, r.id`
+
+	rows, err := database.DB.Query(ctx, query, userId)
+	if err != nil {
+		return []Recipe{}, err
+	}
+
+	return scanListedRecipes(ctx, rows)
+}
+
+// Returns all grants on a recipe. Only the recipe's owner may list them.
+func ListRecipeShares(ctx context.Context, recipeId int64) ([]Share, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleOwner)
+	if err != nil {
+		return []Share{}, err
+	}
+
+	query := `SELECT id, recipe_id, user_id, role FROM recipe_shares WHERE recipe_id = $1`
+
+	rows, err := database.DB.Query(ctx, query, recipeId)
+	if err != nil {
+		return []Share{}, err
+	}
+	defer rows.Close()
+
+	shares := []Share{}
+	for rows.Next() {
+		var share Share
+
+		err = rows.Scan(&share.ID, &share.RecipeID, &share.UserID, &share.Role)
+		if err != nil {
+			return []Share{}, err
+		}
+
+		shares = append(shares, share)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Share{}, err
+	}
+
+	return shares, nil
+}
+
+// Grants a user access to a recipe, or changes the role of an existing
+// grant. Only the recipe's owner may share it.
+func ShareRecipe(ctx context.Context, recipeId int64, share Share) (Share, error) {
+	if share.Role != RoleViewer && share.Role != RoleEditor {
+		return Share{}, ErrInvalidRole
+	}
+
+	err := authorizeRecipe(ctx, recipeId, RoleOwner)
+	if err != nil {
+		return Share{}, err
+	}
+
+	query := `INSERT INTO recipe_shares (recipe_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (recipe_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING id, recipe_id, user_id, role`
+
+	row := database.DB.QueryRow(ctx, query, recipeId, share.UserID, share.Role)
+
+	var created Share
+	err = row.Scan(&created.ID, &created.RecipeID, &created.UserID, &created.Role)
+	if err != nil {
+		return Share{}, err
+	}
+
+	return created, nil
+}
+
+// Revokes a user's access to a recipe. The owner may revoke any grant, and
+// a user may always remove their own access.
+func RevokeRecipeShare(ctx context.Context, recipeId int64, userId int64) error {
+	currentUserId := utils.ExtractUserIDFromContext(ctx)
+	if currentUserId != strconv.FormatInt(userId, 10) {
+		err := authorizeRecipe(ctx, recipeId, RoleOwner)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `DELETE FROM recipe_shares WHERE recipe_id = $1 AND user_id = $2`
+
+	_, err := database.DB.Exec(ctx, query, recipeId, userId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
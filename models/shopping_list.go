@@ -0,0 +1,58 @@
+package models
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+type ShoppingListItem struct {
+	Name      string   `json:"name"`
+	Quantity  float32  `json:"quantity"`
+	Unit      string   `json:"unit"`
+	RecipeIDs []int64  `json:"recipeIds"`
+	Cost      *float64 `json:"cost"`
+	Store     string   `json:"store"`
+}
+
+// Combines ingredients from several recipes into a shopping list. Ingredients
+// with the same name and unit are summed into a single item. Items are
+// sorted by name.
+func AggregateIngredients(ingredients []Ingredient) []ShoppingListItem {
+	type itemKey struct {
+		name string
+		unit string
+	}
+
+	items := []ShoppingListItem{}
+	indexes := map[itemKey]int{}
+	for _, ingredient := range ingredients {
+		key := itemKey{
+			name: strings.ToLower(strings.TrimSpace(ingredient.Name)),
+			unit: strings.ToLower(strings.TrimSpace(ingredient.Unit)),
+		}
+
+		index, ok := indexes[key]
+		if !ok {
+			index = len(items)
+			indexes[key] = index
+			items = append(items, ShoppingListItem{
+				Name:      key.name,
+				Unit:      key.unit,
+				RecipeIDs: []int64{},
+			})
+		}
+
+		item := &items[index]
+		item.Quantity += ingredient.Quantity
+		if !slices.Contains(item.RecipeIDs, ingredient.RecipeID) {
+			item.RecipeIDs = append(item.RecipeIDs, ingredient.RecipeID)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	return items
+}
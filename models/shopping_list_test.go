@@ -0,0 +1,49 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAggregateIngredients(t *testing.T) {
+	ingredients := []Ingredient{
+		{Name: "Flour", Quantity: 200, Unit: "g", RecipeID: 1},
+		{Name: "eggs", Quantity: 2, RecipeID: 1},
+		{Name: " flour ", Quantity: 300, Unit: "G", RecipeID: 2},
+		{Name: "flour", Quantity: 1, Unit: "cup", RecipeID: 2},
+		{Name: "eggs", Quantity: 1, RecipeID: 1},
+		{Name: "butter", Quantity: 50, Unit: "g", RecipeID: 3},
+	}
+
+	want := []struct {
+		name      string
+		quantity  float32
+		unit      string
+		recipeIds []int64
+	}{
+		{"butter", 50, "g", []int64{3}},
+		{"eggs", 3, "", []int64{1}},
+		{"flour", 500, "g", []int64{1, 2}},
+		{"flour", 1, "cup", []int64{2}},
+	}
+
+	items := AggregateIngredients(ingredients)
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+
+	for i, expected := range want {
+		item := items[i]
+		if item.Name != expected.name || item.Quantity != expected.quantity || item.Unit != expected.unit ||
+			!slices.Equal(item.RecipeIDs, expected.recipeIds) {
+			t.Errorf("item %d: got %s %v %s %v, want %s %v %s %v", i, item.Name, item.Quantity, item.Unit,
+				item.RecipeIDs, expected.name, expected.quantity, expected.unit, expected.recipeIds)
+		}
+	}
+}
+
+func TestAggregateIngredientsEmpty(t *testing.T) {
+	if items := AggregateIngredients(nil); items == nil || len(items) != 0 {
+		t.Errorf("got %v, want an empty list", items)
+	}
+}
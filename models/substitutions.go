@@ -0,0 +1,407 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/units"
+	"github.com/mjande/recipes-microservice/utils"
+)
+
+var ErrNotInCatalog = errors.New("ingredient is not in the ingredient catalog")
+var ErrInvalidRatio = errors.New("ratio must be greater than zero")
+var ErrInvalidSubstitution = errors.New("an ingredient cannot be substituted with itself")
+var ErrUnknownUnit = errors.New("unknown unit")
+
+// Substitution is a rule that one catalog ingredient can replace another.
+// Ratio is how much of the substitute replaces one unit of the ingredient,
+// measured in Unit when it is set and in the ingredient's own unit otherwise.
+// Diets lists the diets the substitution helps a recipe fit. Bundled rules
+// come with the service and cannot be changed.
+type Substitution struct {
+	ID           int64    `json:"id"`
+	IngredientID int64    `json:"ingredientId"`
+	Ingredient   string   `json:"ingredient"`
+	SubstituteID int64    `json:"substituteId"`
+	Substitute   string   `json:"substitute"`
+	Ratio        float32  `json:"ratio"`
+	Unit         string   `json:"unit"`
+	Notes        string   `json:"notes"`
+	Diets        []string `json:"diets"`
+	Bundled      bool     `json:"bundled"`
+}
+
+// A substitution applied to one of a recipe's ingredients.
+type SubstitutionOption struct {
+	Name     string       `json:"name"`
+	Quantity float32      `json:"quantity"`
+	Unit     string       `json:"unit"`
+	Rule     Substitution `json:"rule"`
+}
+
+type IngredientSubstitutions struct {
+	Ingredient    Ingredient           `json:"ingredient"`
+	Substitutions []SubstitutionOption `json:"substitutions"`
+}
+
+const substitutionColumns = `s.id, s.ingredient_id, i.name, s.substitute_id, c.name, s.ratio, s.unit, s.notes, s.diets, s.user_id IS NULL`
+
+// Queries the bundled substitution rules and the rules of the cookbook the
+// request is scoped to. If an ingredient is given, only rules replacing it
+// are returned.
+func ListSubstitutions(ctx context.Context, ingredient string) ([]Substitution, error) {
+	var ingredientId *int64
+	if strings.TrimSpace(ingredient) != "" {
+		entry, err := MatchCatalogEntry(ctx, ingredient)
+		if err != nil {
+			return []Substitution{}, err
+		}
+
+		if entry == nil {
+			return []Substitution{}, nil
+		}
+
+		ingredientId = &entry.ID
+	}
+
+	scope, scopeId, err := cookbookScope(ctx, "s", "$1")
+	if err != nil {
+		return []Substitution{}, err
+	}
+
+	query := `SELECT ` + substitutionColumns + `
+		FROM substitutions s
+		JOIN ingredient_catalog i ON i.id = s.ingredient_id
+		JOIN ingredient_catalog c ON c.id = s.substitute_id
+		WHERE (s.user_id IS NULL OR ` + scope + `)
+			AND ($2::int IS NULL OR s.ingredient_id = $2)
+		ORDER BY i.name, s.user_id IS NULL, c.name`
+
+	return querySubstitutions(ctx, query, scopeId, ingredientId)
+}
+
+// Queries a single substitution rule, as long as it is bundled or belongs to
+// the cookbook the request is scoped to.
+func FindSubstitution(ctx context.Context, id int64) (Substitution, error) {
+	scope, scopeId, err := cookbookScope(ctx, "s", "$2")
+	if err != nil {
+		return Substitution{}, err
+	}
+
+	query := `SELECT ` + substitutionColumns + `
+		FROM substitutions s
+		JOIN ingredient_catalog i ON i.id = s.ingredient_id
+		JOIN ingredient_catalog c ON c.id = s.substitute_id
+		WHERE s.id = $1 AND (s.user_id IS NULL OR ` + scope + `)`
+
+	var substitution Substitution
+	err = database.DB.QueryRow(ctx, query, id, scopeId).Scan(&substitution.ID, &substitution.IngredientID,
+		&substitution.Ingredient, &substitution.SubstituteID, &substitution.Substitute, &substitution.Ratio,
+		&substitution.Unit, &substitution.Notes, &substitution.Diets, &substitution.Bundled)
+	if err != nil {
+		return Substitution{}, err
+	}
+
+	return substitution, nil
+}
+
+// Adds a substitution rule to the cookbook the request is scoped to. The
+// ingredient and substitute are given by name and must match catalog
+// entries.
+func CreateSubstitution(ctx context.Context, substitution Substitution) (int64, error) {
+	err := validateSubstitution(substitution)
+	if err != nil {
+		return -1, err
+	}
+
+	ingredient, err := MatchCatalogEntry(ctx, substitution.Ingredient)
+	if err != nil {
+		return -1, err
+	}
+
+	substitute, err := MatchCatalogEntry(ctx, substitution.Substitute)
+	if err != nil {
+		return -1, err
+	}
+
+	if ingredient == nil || substitute == nil {
+		return -1, ErrNotInCatalog
+	} else if ingredient.ID == substitute.ID {
+		return -1, ErrInvalidSubstitution
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	householdId, err := scopedHouseholdID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	diets := substitution.Diets
+	if diets == nil {
+		diets = []string{}
+	}
+
+	query := `INSERT INTO substitutions (user_id, household_id, ingredient_id, substitute_id, ratio, unit, notes, diets)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	var id int64
+	err = database.DB.QueryRow(ctx, query, userId, householdId, ingredient.ID, substitute.ID, substitution.Ratio,
+		strings.TrimSpace(substitution.Unit), substitution.Notes, diets).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Removes a substitution rule. Bundled rules cannot be removed.
+func DeleteSubstitution(ctx context.Context, id int64) error {
+	substitution, err := FindSubstitution(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if substitution.Bundled {
+		return ErrForbidden
+	}
+
+	_, err = database.DB.Exec(ctx, `DELETE FROM substitutions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Lists the substitutions available for a recipe's ingredients. If a diet is
+// given, every ingredient that does not fit the diet is listed instead, with
+// the substitutions that help the recipe fit it. The cookbook's own rules are
+// listed before bundled ones.
+func RecipeSubstitutions(ctx context.Context, recipeId int64, diet string) ([]IngredientSubstitutions, error) {
+	err := authorizeRecipe(ctx, recipeId, RoleViewer)
+	if err != nil {
+		return []IngredientSubstitutions{}, err
+	}
+
+	if diet != "" {
+		err = validateClassification(Classification{Diets: []string{diet}})
+		if err != nil {
+			return []IngredientSubstitutions{}, err
+		}
+	}
+
+	recipe, err := findRecipe(ctx, recipeId)
+	if err != nil {
+		return []IngredientSubstitutions{}, err
+	}
+
+	ingredients, err := findClassifiedIngredients(ctx, recipeId)
+	if err != nil {
+		return []IngredientSubstitutions{}, err
+	}
+
+	catalogIds := []int64{}
+	for _, ingredient := range ingredients {
+		if ingredient.CatalogID != nil {
+			catalogIds = append(catalogIds, *ingredient.CatalogID)
+		}
+	}
+
+	rules, err := findSubstitutionsFor(ctx, catalogIds)
+	if err != nil {
+		return []IngredientSubstitutions{}, err
+	}
+
+	recipeIngredients := map[int64]Ingredient{}
+	for _, ingredient := range recipe.Ingredients {
+		recipeIngredients[ingredient.ID] = ingredient
+	}
+
+	results := []IngredientSubstitutions{}
+	for _, classified := range ingredients {
+		if classified.CatalogID == nil {
+			continue
+		}
+
+		if diet != "" && fitsDiet(diet, []classifiedIngredient{classified}) {
+			continue
+		}
+
+		ingredient := recipeIngredients[classified.ID]
+		ingredient.RecipeID = recipeId
+
+		result := IngredientSubstitutions{
+			Ingredient:    ingredient,
+			Substitutions: []SubstitutionOption{},
+		}
+
+		for _, rule := range rules[*classified.CatalogID] {
+			if diet != "" && !slices.Contains(rule.Diets, diet) {
+				continue
+			}
+
+			result.Substitutions = append(result.Substitutions, applySubstitution(ingredient, rule))
+		}
+
+		// Without a diet, only list ingredients that can be substituted
+		if diet == "" && len(result.Substitutions) == 0 {
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Copies a recipe into the cookbook the request is scoped to, replacing each
+// ingredient that does not fit the diet with its first available
+// substitution. Ingredients without a substitution are copied unchanged, so
+// the copy's classification shows whether it fits the diet.
+func CreateSubstitutedRecipe(ctx context.Context, recipeId int64, diet string) (int64, error) {
+	if diet == "" {
+		return -1, ErrInvalidDiet
+	}
+
+	substitutions, err := RecipeSubstitutions(ctx, recipeId, diet)
+	if err != nil {
+		return -1, err
+	}
+
+	recipe, err := findRecipe(ctx, recipeId)
+	if err != nil {
+		return -1, err
+	}
+
+	replacements := map[int64]SubstitutionOption{}
+	for _, substitution := range substitutions {
+		if len(substitution.Substitutions) > 0 {
+			replacements[substitution.Ingredient.ID] = substitution.Substitutions[0]
+		}
+	}
+
+	notes := []string{}
+	for i, ingredient := range recipe.Ingredients {
+		replacement, ok := replacements[ingredient.ID]
+		if !ok {
+			continue
+		}
+
+		note := ingredient.Name + " replaced with " + replacement.Name + "."
+		if replacement.Rule.Notes != "" {
+			note += " " + replacement.Rule.Notes
+		}
+		notes = append(notes, note)
+
+		recipe.Ingredients[i] = Ingredient{
+			Name:     replacement.Name,
+			Quantity: replacement.Quantity,
+			Unit:     replacement.Unit,
+		}
+	}
+
+	recipe.Name += " (" + diet + ")"
+	if len(notes) > 0 {
+		recipe.Description = strings.TrimSpace(recipe.Description + "\n\nSubstitutions: " + strings.Join(notes, " "))
+	}
+
+	return CreateRecipe(ctx, recipe)
+}
+
+// Helper Functions
+
+// Queries the substitution rules visible to the request for the given
+// catalog entries, grouped by the entry they replace.
+func findSubstitutionsFor(ctx context.Context, catalogIds []int64) (map[int64][]Substitution, error) {
+	scope, scopeId, err := cookbookScope(ctx, "s", "$1")
+	if err != nil {
+		return map[int64][]Substitution{}, err
+	}
+
+	query := `SELECT ` + substitutionColumns + `
+		FROM substitutions s
+		JOIN ingredient_catalog i ON i.id = s.ingredient_id
+		JOIN ingredient_catalog c ON c.id = s.substitute_id
+		WHERE (s.user_id IS NULL OR ` + scope + `) AND s.ingredient_id = ANY($2)
+		ORDER BY s.user_id IS NULL, s.id`
+
+	substitutions, err := querySubstitutions(ctx, query, scopeId, catalogIds)
+	if err != nil {
+		return map[int64][]Substitution{}, err
+	}
+
+	rules := map[int64][]Substitution{}
+	for _, substitution := range substitutions {
+		rules[substitution.IngredientID] = append(rules[substitution.IngredientID], substitution)
+	}
+
+	return rules, nil
+}
+
+// Runs a query selecting substitutionColumns and scans the rows.
+func querySubstitutions(ctx context.Context, query string, args ...any) ([]Substitution, error) {
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		return []Substitution{}, err
+	}
+	defer rows.Close()
+
+	substitutions := []Substitution{}
+	for rows.Next() {
+		var substitution Substitution
+
+		err = rows.Scan(&substitution.ID, &substitution.IngredientID, &substitution.Ingredient,
+			&substitution.SubstituteID, &substitution.Substitute, &substitution.Ratio, &substitution.Unit,
+			&substitution.Notes, &substitution.Diets, &substitution.Bundled)
+		if err != nil {
+			return []Substitution{}, err
+		}
+
+		substitutions = append(substitutions, substitution)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Substitution{}, err
+	}
+
+	return substitutions, nil
+}
+
+// Works out how much of the substitute replaces an ingredient.
+func applySubstitution(ingredient Ingredient, rule Substitution) SubstitutionOption {
+	unit := ingredient.Unit
+	if rule.Unit != "" {
+		unit = rule.Unit
+	}
+
+	return SubstitutionOption{
+		Name:     rule.Substitute,
+		Quantity: ingredient.Quantity * rule.Ratio,
+		Unit:     unit,
+		Rule:     rule,
+	}
+}
+
+// Checks that a substitution rule names both ingredients, has a positive
+// ratio, a known unit and only known diets.
+func validateSubstitution(substitution Substitution) error {
+	if strings.TrimSpace(substitution.Ingredient) == "" || strings.TrimSpace(substitution.Substitute) == "" {
+		return ErrEmptyName
+	}
+
+	if substitution.Ratio <= 0 {
+		return ErrInvalidRatio
+	}
+
+	if unit := strings.TrimSpace(substitution.Unit); unit != "" {
+		if _, ok := units.Parse(unit); !ok {
+			return ErrUnknownUnit
+		}
+	}
+
+	return validateClassification(Classification{Diets: substitution.Diets})
+}
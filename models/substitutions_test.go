@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplySubstitution(t *testing.T) {
+	tests := []struct {
+		name       string
+		ingredient Ingredient
+		rule       Substitution
+		quantity   float32
+		unit       string
+	}{
+		{
+			name:       "keeps the ingredient's unit",
+			ingredient: Ingredient{Name: "butter", Quantity: 2, Unit: "tbsp"},
+			rule:       Substitution{Substitute: "olive oil", Ratio: 0.75},
+			quantity:   1.5,
+			unit:       "tbsp",
+		},
+		{
+			name:       "uses the rule's unit",
+			ingredient: Ingredient{Name: "egg", Quantity: 2},
+			rule:       Substitution{Substitute: "flaxseed", Ratio: 1, Unit: "tbsp"},
+			quantity:   2,
+			unit:       "tbsp",
+		},
+		{
+			name:       "unknown quantity stays unknown",
+			ingredient: Ingredient{Name: "milk", Unit: "cup"},
+			rule:       Substitution{Substitute: "oat milk", Ratio: 1},
+			quantity:   0,
+			unit:       "cup",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			option := applySubstitution(test.ingredient, test.rule)
+			if option.Name != test.rule.Substitute || option.Quantity != test.quantity || option.Unit != test.unit {
+				t.Errorf("got %s %v %s, want %s %v %s", option.Name, option.Quantity, option.Unit,
+					test.rule.Substitute, test.quantity, test.unit)
+			}
+		})
+	}
+}
+
+func TestValidateSubstitution(t *testing.T) {
+	valid := Substitution{Ingredient: "butter", Substitute: "olive oil", Ratio: 0.75}
+
+	tests := []struct {
+		name   string
+		change func(*Substitution)
+		err    error
+	}{
+		{"valid", func(s *Substitution) {}, nil},
+		{"known unit", func(s *Substitution) { s.Unit = "tbsp" }, nil},
+		{"known diet", func(s *Substitution) { s.Diets = []string{"vegan"} }, nil},
+		{"missing ingredient", func(s *Substitution) { s.Ingredient = " " }, ErrEmptyName},
+		{"missing substitute", func(s *Substitution) { s.Substitute = "" }, ErrEmptyName},
+		{"zero ratio", func(s *Substitution) { s.Ratio = 0 }, ErrInvalidRatio},
+		{"negative ratio", func(s *Substitution) { s.Ratio = -1 }, ErrInvalidRatio},
+		{"unknown unit", func(s *Substitution) { s.Unit = "handful" }, ErrUnknownUnit},
+		{"unknown diet", func(s *Substitution) { s.Diets = []string{"keto"} }, ErrInvalidDiet},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			substitution := valid
+			test.change(&substitution)
+
+			if err := validateSubstitution(substitution); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,593 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
+)
+
+// Most logged changes read by a single sync. Clients sync again with the
+// returned token while there are more.
+const maxSyncChanges = 500
+
+// Most changes a client can push at once.
+const maxPushChanges = 100
+
+// Statuses of pushed changes
+const (
+	PushApplied  = "applied"
+	PushConflict = "conflict"
+	PushFailed   = "error"
+)
+
+var ErrInvalidSyncToken = errors.New("sync token is not valid")
+var ErrTooManyChanges = errors.New("at most 100 changes can be pushed at once")
+var ErrUnknownEntity = errors.New("entity must be recipe, ingredient or tag")
+var ErrUnknownOperation = errors.New("operation must be create, update or delete")
+var ErrMissingData = errors.New("change is missing the data for its entity")
+var ErrTagCreate = errors.New("tags are created by adding them to a recipe")
+var ErrSyncConflict = errors.New("changed on the server since the sync token")
+
+// A deleted entity. Ingredients deleted along with their recipe have no
+// tombstone of their own.
+type Tombstone struct {
+	ID        int64     `json:"id"`
+	RecipeID  *int64    `json:"recipeId,omitempty"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+type RecipeChanges struct {
+	Created []Recipe    `json:"created"`
+	Updated []Recipe    `json:"updated"`
+	Deleted []Tombstone `json:"deleted"`
+}
+
+type IngredientChanges struct {
+	Created []Ingredient `json:"created"`
+	Updated []Ingredient `json:"updated"`
+	Deleted []Tombstone  `json:"deleted"`
+}
+
+type TagChanges struct {
+	Created []Tag       `json:"created"`
+	Updated []Tag       `json:"updated"`
+	Deleted []Tombstone `json:"deleted"`
+}
+
+// The entities created, updated and deleted since a sync token, in their
+// current state. Token is passed to the next sync. More is set when there
+// are more changes to sync.
+type SyncDelta struct {
+	Token       string            `json:"token"`
+	More        bool              `json:"more"`
+	Recipes     RecipeChanges     `json:"recipes"`
+	Ingredients IngredientChanges `json:"ingredients"`
+	Tags        TagChanges        `json:"tags"`
+}
+
+// A change made by a client while offline. Creates carry the new entity in
+// the field named after its entity, and updates the whole entity. Updates
+// and deletes name the entity by ID. ClientID lets the client match results
+// to its changes, such as to learn the IDs of created entities.
+type PushChange struct {
+	Entity     string      `json:"entity"`
+	Operation  string      `json:"operation"`
+	ID         int64       `json:"id"`
+	ClientID   string      `json:"clientId"`
+	Recipe     *Recipe     `json:"recipe"`
+	Ingredient *Ingredient `json:"ingredient"`
+	Tag        *Tag        `json:"tag"`
+}
+
+// The outcome of a pushed change. Conflicting changes are not applied, and
+// Current holds the server's version of the entity, or nothing if it was
+// deleted or is no longer in the cookbook.
+type PushResult struct {
+	ClientID  string `json:"clientId,omitempty"`
+	Entity    string `json:"entity"`
+	Operation string `json:"operation"`
+	ID        int64  `json:"id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Current   any    `json:"current,omitempty"`
+}
+
+// A position in the change log: the last change a client has seen, by the
+// transaction that made it and its sequence number within the log.
+type syncToken struct {
+	txid uint64
+	seq  int64
+}
+
+// A logged change to an entity.
+type change struct {
+	txid      uint64
+	seq       int64
+	entity    string
+	entityId  int64
+	recipeId  *int64
+	operation string
+	changedAt time.Time
+}
+
+// Returns the recipes, ingredients and tags of the cookbook the request is
+// scoped to that changed since a sync token. Without a token, every entity
+// is returned as created. Entities changed several times are returned once,
+// in their current state, and entities created and deleted since the token
+// are left out.
+func SyncChanges(ctx context.Context, token string) (SyncDelta, error) {
+	since, err := parseSyncToken(token)
+	if err != nil {
+		return SyncDelta{}, err
+	}
+
+	scope, scopeId, err := cookbookScope(ctx, "c", "$3")
+	if err != nil {
+		return SyncDelta{}, err
+	}
+
+	// Changes of transactions that are still running could commit before
+	// changes already read, so they are left for a later sync
+	query := `SELECT c.txid::text, c.seq, c.entity, c.entity_id, c.recipe_id, c.operation, c.changed_at
+		FROM changes c
+		WHERE (c.txid > $1::text::xid8 OR (c.txid = $1::text::xid8 AND c.seq > $2))
+			AND c.txid < pg_snapshot_xmin(pg_current_snapshot())
+			AND ` + scope + `
+		ORDER BY c.txid, c.seq
+		LIMIT $4`
+
+	rows, err := database.DB.Query(ctx, query, strconv.FormatUint(since.txid, 10), since.seq, scopeId, maxSyncChanges+1)
+	if err != nil {
+		return SyncDelta{}, err
+	}
+	defer rows.Close()
+
+	changes := []change{}
+	for rows.Next() {
+		var c change
+		var txid string
+
+		err = rows.Scan(&txid, &c.seq, &c.entity, &c.entityId, &c.recipeId, &c.operation, &c.changedAt)
+		if err != nil {
+			return SyncDelta{}, err
+		}
+
+		c.txid, err = strconv.ParseUint(txid, 10, 64)
+		if err != nil {
+			return SyncDelta{}, err
+		}
+
+		changes = append(changes, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return SyncDelta{}, err
+	}
+
+	delta := SyncDelta{
+		Token:       since.String(),
+		More:        len(changes) > maxSyncChanges,
+		Recipes:     RecipeChanges{Created: []Recipe{}, Updated: []Recipe{}, Deleted: []Tombstone{}},
+		Ingredients: IngredientChanges{Created: []Ingredient{}, Updated: []Ingredient{}, Deleted: []Tombstone{}},
+		Tags:        TagChanges{Created: []Tag{}, Updated: []Tag{}, Deleted: []Tombstone{}},
+	}
+
+	if delta.More {
+		changes = changes[:maxSyncChanges]
+	}
+
+	if len(changes) > 0 {
+		last := changes[len(changes)-1]
+		delta.Token = syncToken{txid: last.txid, seq: last.seq}.String()
+	}
+
+	for _, c := range collapseChanges(changes) {
+		err = addToDelta(ctx, &delta, c)
+		if err != nil {
+			return SyncDelta{}, err
+		}
+	}
+
+	return delta, nil
+}
+
+// Applies changes a client made while offline, one at a time, in order.
+// Updates and deletes conflict when the entity changed on the server since
+// the client's sync token, and are then not applied. A failed or
+// conflicting change does not stop the others.
+func PushChanges(ctx context.Context, token string, changes []PushChange) ([]PushResult, error) {
+	since, err := parseSyncToken(token)
+	if err != nil {
+		return []PushResult{}, err
+	}
+
+	if len(changes) > maxPushChanges {
+		return []PushResult{}, ErrTooManyChanges
+	}
+
+	_, _, err = cookbookScope(ctx, "c", "$1")
+	if err != nil {
+		return []PushResult{}, err
+	}
+
+	// Changes logged while applying the push must not conflict with later
+	// changes in the same push
+	var latest int64
+	err = database.DB.QueryRow(ctx, `SELECT COALESCE(MAX(seq), 0) FROM changes`).Scan(&latest)
+	if err != nil {
+		return []PushResult{}, err
+	}
+
+	results := make([]PushResult, len(changes))
+	for i, pushed := range changes {
+		result := PushResult{
+			ClientID:  pushed.ClientID,
+			Entity:    pushed.Entity,
+			Operation: pushed.Operation,
+			ID:        pushed.ID,
+			Status:    PushApplied,
+		}
+
+		id, err := applyChange(ctx, since, latest, pushed)
+		if errors.Is(err, ErrSyncConflict) {
+			result.Status = PushConflict
+			result.Error = err.Error()
+			result.Current, err = findSyncEntity(ctx, pushed.Entity, pushed.ID)
+		}
+
+		if err != nil {
+			result.Status = PushFailed
+			result.Error = err.Error()
+		}
+
+		if result.Status == PushApplied {
+			result.ID = id
+		}
+
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// Helper Functions
+
+// Parses a sync token, which is the transaction and sequence number of the
+// last change the client has seen, separated by a dash. An empty token is
+// before every change.
+func parseSyncToken(token string) (syncToken, error) {
+	if token == "" {
+		return syncToken{}, nil
+	}
+
+	txidText, seqText, ok := strings.Cut(token, "-")
+	if !ok {
+		return syncToken{}, ErrInvalidSyncToken
+	}
+
+	txid, err := strconv.ParseUint(txidText, 10, 64)
+	if err != nil || txid > math.MaxInt64 {
+		return syncToken{}, ErrInvalidSyncToken
+	}
+
+	seq, err := strconv.ParseInt(seqText, 10, 64)
+	if err != nil || seq < 0 {
+		return syncToken{}, ErrInvalidSyncToken
+	}
+
+	return syncToken{txid: txid, seq: seq}, nil
+}
+
+func (t syncToken) String() string {
+	return strconv.FormatUint(t.txid, 10) + "-" + strconv.FormatInt(t.seq, 10)
+}
+
+// Reduces the changes to each entity to a single change, ordered by the
+// entity's last change. An entity is created if its first change created
+// it, and deleted if its last change deleted it. Entities both created and
+// deleted are left out.
+func collapseChanges(changes []change) []change {
+	type key struct {
+		entity string
+		id     int64
+	}
+
+	first := map[key]string{}
+	last := map[key]int{}
+	for i, c := range changes {
+		k := key{c.entity, c.entityId}
+		if _, ok := first[k]; !ok {
+			first[k] = c.operation
+		}
+		last[k] = i
+	}
+
+	collapsed := []change{}
+	for i, c := range changes {
+		k := key{c.entity, c.entityId}
+		if last[k] != i {
+			continue
+		}
+
+		switch {
+		case c.operation == "delete" && first[k] == "create":
+			continue
+		case c.operation == "delete":
+		case first[k] == "create":
+			c.operation = "create"
+		default:
+			c.operation = "update"
+		}
+
+		collapsed = append(collapsed, c)
+	}
+
+	return collapsed
+}
+
+// Adds the current state of a changed entity to a delta. Entities that are
+// gone by now are added as deleted.
+func addToDelta(ctx context.Context, delta *SyncDelta, c change) error {
+	tombstone := Tombstone{ID: c.entityId, DeletedAt: c.changedAt}
+	if c.entity == "ingredient" {
+		tombstone.RecipeID = c.recipeId
+	}
+
+	var entity any
+	if c.operation != "delete" {
+		var err error
+		entity, err = findSyncEntity(ctx, c.entity, c.entityId)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch c.entity {
+	case "recipe":
+		recipe, ok := entity.(*Recipe)
+		switch {
+		case !ok:
+			delta.Recipes.Deleted = append(delta.Recipes.Deleted, tombstone)
+		case c.operation == "create":
+			delta.Recipes.Created = append(delta.Recipes.Created, *recipe)
+		default:
+			delta.Recipes.Updated = append(delta.Recipes.Updated, *recipe)
+		}
+	case "ingredient":
+		ingredient, ok := entity.(*Ingredient)
+		switch {
+		case !ok:
+			delta.Ingredients.Deleted = append(delta.Ingredients.Deleted, tombstone)
+		case c.operation == "create":
+			delta.Ingredients.Created = append(delta.Ingredients.Created, *ingredient)
+		default:
+			delta.Ingredients.Updated = append(delta.Ingredients.Updated, *ingredient)
+		}
+	case "tag":
+		tag, ok := entity.(*Tag)
+		switch {
+		case !ok:
+			delta.Tags.Deleted = append(delta.Tags.Deleted, tombstone)
+		case c.operation == "create":
+			delta.Tags.Created = append(delta.Tags.Created, *tag)
+		default:
+			delta.Tags.Updated = append(delta.Tags.Updated, *tag)
+		}
+	}
+
+	return nil
+}
+
+// Queries the current state of an entity in the cookbook the request is
+// scoped to as a *Recipe, *Ingredient or *Tag, or nil if it no longer exists
+// or has left the cookbook, so that clients only learn of it as deleted.
+func findSyncEntity(ctx context.Context, entity string, id int64) (any, error) {
+	var err error
+
+	switch entity {
+	case "recipe":
+		var scope, scopeId string
+		scope, scopeId, err = cookbookScope(ctx, "r", "$2")
+		if err != nil {
+			return nil, err
+		}
+
+		var inCookbook bool
+		err = database.DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM recipes r WHERE r.id = $1 AND `+scope+`)`, id, scopeId).Scan(&inCookbook)
+		if err != nil || !inCookbook {
+			return nil, err
+		}
+
+		var recipe Recipe
+		recipe, err = findRecipe(ctx, id)
+		if err == nil {
+			return &recipe, nil
+		}
+	case "ingredient":
+		var scope, scopeId string
+		scope, scopeId, err = cookbookScope(ctx, "r", "$2")
+		if err != nil {
+			return nil, err
+		}
+
+		query := `SELECT i.id, i.name, i.recipe_id, i.quantity, i.unit, i.catalog_id
+			FROM ingredients i
+			JOIN recipes r ON r.id = i.recipe_id
+			WHERE i.id = $1 AND ` + scope
+
+		var ingredient Ingredient
+		err = database.DB.QueryRow(ctx, query, id, scopeId).Scan(&ingredient.ID, &ingredient.Name, &ingredient.RecipeID,
+			&ingredient.Quantity, &ingredient.Unit, &ingredient.CatalogID)
+		if err == nil {
+			return &ingredient, nil
+		}
+	case "tag":
+		var scope, scopeId string
+		scope, scopeId, err = cookbookScope(ctx, "t", "$2")
+		if err != nil {
+			return nil, err
+		}
+
+		query := `SELECT t.id, t.name, (SELECT COUNT(*) FROM recipe_tags WHERE tag_id = t.id)
+			FROM tags t
+			WHERE t.id = $1 AND ` + scope
+
+		var tag Tag
+		err = database.DB.QueryRow(ctx, query, id, scopeId).Scan(&tag.ID, &tag.Name, &tag.Count)
+		if err == nil {
+			return &tag, nil
+		}
+	default:
+		return nil, ErrUnknownEntity
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	return nil, err
+}
+
+// Applies a pushed change, unless it conflicts with a change logged after
+// since and up to latest. Returns the ID of the changed entity.
+func applyChange(ctx context.Context, since syncToken, latest int64, pushed PushChange) (int64, error) {
+	switch pushed.Operation {
+	case "create":
+	case "update", "delete":
+		conflict, err := hasConflict(ctx, since, latest, pushed.Entity, pushed.ID)
+		if err != nil {
+			return -1, err
+		}
+
+		if conflict {
+			return -1, ErrSyncConflict
+		}
+	default:
+		return -1, ErrUnknownOperation
+	}
+
+	switch pushed.Entity {
+	case "recipe":
+		if pushed.Operation == "delete" {
+			return pushed.ID, DeleteRecipe(ctx, pushed.ID)
+		}
+
+		if pushed.Recipe == nil {
+			return -1, ErrMissingData
+		}
+
+		if pushed.Operation == "create" {
+			return CreateRecipe(ctx, *pushed.Recipe)
+		}
+
+		return UpdateRecipe(ctx, pushed.ID, *pushed.Recipe)
+	case "ingredient":
+		return applyIngredientChange(ctx, pushed)
+	case "tag":
+		switch pushed.Operation {
+		case "create":
+			return -1, ErrTagCreate
+		case "delete":
+			return pushed.ID, DeleteTag(ctx, pushed.ID)
+		}
+
+		if pushed.Tag == nil {
+			return -1, ErrMissingData
+		}
+
+		tag, err := RenameTag(ctx, pushed.ID, pushed.Tag.Name)
+		return tag.ID, err
+	}
+
+	return -1, ErrUnknownEntity
+}
+
+// Applies a pushed change to a single ingredient of a recipe the user may
+// edit, and classifies the recipe again.
+func applyIngredientChange(ctx context.Context, pushed PushChange) (int64, error) {
+	var recipeId int64
+	if pushed.Operation == "create" {
+		if pushed.Ingredient == nil {
+			return -1, ErrMissingData
+		}
+
+		recipeId = pushed.Ingredient.RecipeID
+	} else {
+		err := database.DB.QueryRow(ctx, `SELECT recipe_id FROM ingredients WHERE id = $1`, pushed.ID).Scan(&recipeId)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	err := authorizeRecipe(ctx, recipeId, RoleEditor)
+	if err != nil {
+		return -1, err
+	}
+
+	id := pushed.ID
+	switch pushed.Operation {
+	case "create":
+		id, err = CreateIngredient(ctx, *pushed.Ingredient)
+	case "update":
+		if pushed.Ingredient == nil {
+			return -1, ErrMissingData
+		}
+
+		query := `UPDATE ingredients SET name = $1, quantity = $2, unit = $3 WHERE id = $4`
+		_, err = database.DB.Exec(ctx, query, pushed.Ingredient.Name, pushed.Ingredient.Quantity, pushed.Ingredient.Unit, id)
+		if err != nil {
+			return -1, err
+		}
+
+		// A renamed ingredient may match a different catalog entry
+		_, _, err = relinkIngredient(ctx, id, pushed.Ingredient.Name)
+	case "delete":
+		_, err = database.DB.Exec(ctx, `DELETE FROM ingredients WHERE id = $1`, id)
+	}
+
+	if err != nil {
+		return -1, err
+	}
+
+	err = classifyRecipe(ctx, recipeId)
+	if err != nil {
+		return -1, err
+	}
+
+	publishRecipeEvent(ctx, events.RecipeUpdated, recipeId)
+
+	return id, nil
+}
+
+// Reports whether an entity changed in the cookbook the request is scoped
+// to after since and up to latest. Recipes also change when their
+// ingredients do.
+func hasConflict(ctx context.Context, since syncToken, latest int64, entity string, id int64) (bool, error) {
+	scope, scopeId, err := cookbookScope(ctx, "c", "$6")
+	if err != nil {
+		return false, err
+	}
+
+	query := `SELECT EXISTS (
+		SELECT 1 FROM changes c
+		WHERE (c.txid > $1::text::xid8 OR (c.txid = $1::text::xid8 AND c.seq > $2)) AND c.seq <= $3 AND ` + scope + ` AND (
+			(c.entity = $4 AND c.entity_id = $5)
+			OR ($4 = 'recipe' AND c.entity = 'ingredient' AND c.recipe_id = $5)
+		)
+	)`
+
+	var conflict bool
+	err = database.DB.QueryRow(ctx, query, strconv.FormatUint(since.txid, 10), since.seq, latest, entity, id, scopeId).Scan(&conflict)
+	if err != nil {
+		return false, err
+	}
+
+	return conflict, nil
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCollapseChanges(t *testing.T) {
+	type step struct {
+		entity    string
+		entityId  int64
+		operation string
+	}
+
+	tests := []struct {
+		name    string
+		changes []step
+		want    []step
+	}{
+		{
+			name:    "single change is kept",
+			changes: []step{{"recipe", 1, "update"}},
+			want:    []step{{"recipe", 1, "update"}},
+		},
+		{
+			name:    "create then updates is a create",
+			changes: []step{{"recipe", 1, "create"}, {"recipe", 1, "update"}, {"recipe", 1, "update"}},
+			want:    []step{{"recipe", 1, "create"}},
+		},
+		{
+			name:    "updates are one update",
+			changes: []step{{"recipe", 1, "update"}, {"recipe", 1, "update"}},
+			want:    []step{{"recipe", 1, "update"}},
+		},
+		{
+			name:    "update then delete is a delete",
+			changes: []step{{"recipe", 1, "update"}, {"recipe", 1, "delete"}},
+			want:    []step{{"recipe", 1, "delete"}},
+		},
+		{
+			name:    "create then delete is dropped",
+			changes: []step{{"recipe", 1, "create"}, {"recipe", 1, "update"}, {"recipe", 1, "delete"}},
+			want:    []step{},
+		},
+		{
+			name:    "entities are kept apart",
+			changes: []step{{"recipe", 1, "create"}, {"tag", 1, "update"}, {"recipe", 2, "update"}, {"recipe", 1, "update"}},
+			want:    []step{{"tag", 1, "update"}, {"recipe", 2, "update"}, {"recipe", 1, "create"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := []change{}
+			for i, s := range test.changes {
+				changes = append(changes, change{txid: 1, seq: int64(i + 1), entity: s.entity, entityId: s.entityId, operation: s.operation})
+			}
+
+			got := []step{}
+			for _, c := range collapseChanges(changes) {
+				got = append(got, step{c.entity, c.entityId, c.operation})
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("got %v, want %v", got, test.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCollapseChangesKeepsLatestPosition(t *testing.T) {
+	changes := []change{
+		{txid: 5, seq: 10, entity: "recipe", entityId: 1, operation: "create"},
+		{txid: 7, seq: 12, entity: "recipe", entityId: 1, operation: "update"},
+	}
+
+	collapsed := collapseChanges(changes)
+	if len(collapsed) != 1 || collapsed[0].txid != 7 || collapsed[0].seq != 12 {
+		t.Errorf("got %+v, want the position of the last change", collapsed)
+	}
+}
+
+func TestParseSyncToken(t *testing.T) {
+	tests := []struct {
+		token string
+		want  syncToken
+		err   error
+	}{
+		{"", syncToken{}, nil},
+		{"0-0", syncToken{}, nil},
+		{"812-45", syncToken{txid: 812, seq: 45}, nil},
+		{"9223372036854775807-1", syncToken{txid: 9223372036854775807, seq: 1}, nil},
+		{"9223372036854775808-1", syncToken{}, ErrInvalidSyncToken},
+		{"45", syncToken{}, ErrInvalidSyncToken},
+		{"812-", syncToken{}, ErrInvalidSyncToken},
+		{"812--1", syncToken{}, ErrInvalidSyncToken},
+		{"-1-5", syncToken{}, ErrInvalidSyncToken},
+		{"abc-def", syncToken{}, ErrInvalidSyncToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.token, func(t *testing.T) {
+			got, err := parseSyncToken(test.token)
+			if got != test.want || !errors.Is(err, test.err) {
+				t.Errorf("got %+v %v, want %+v %v", got, err, test.want, test.err)
+			}
+		})
+	}
+}
+
+func TestSyncTokenRoundTrip(t *testing.T) {
+	token := syncToken{txid: 812, seq: 45}
+
+	parsed, err := parseSyncToken(token.String())
+	if err != nil || parsed != token {
+		t.Errorf("got %+v %v from %q, want %+v", parsed, err, token.String(), token)
+	}
+}
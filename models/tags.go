@@ -2,20 +2,81 @@ package models
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/events"
 )
 
+// Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+var ErrTagExists = errors.New("a tag with this name already exists, merge the tags instead")
+var ErrEmptyTag = errors.New("tag name cannot be empty")
+
 type Tag struct {
-	ID       int64
-	RecipeId int64
-	Name     string
+	ID       int64  `json:"id"`
+	RecipeId int64  `json:"-"`
+	Name     string `json:"name"`
+	Count    int64  `json:"count"`
+}
+
+// Normalizes a tag name so that tags differing only in case or whitespace
+// are stored as the same tag.
+func NormalizeTag(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// Returns all tags in the cookbook the request is scoped to, with the number
+// of recipes using each.
+func ListTags(ctx context.Context) ([]Tag, error) {
+	scope, scopeId, err := cookbookScope(ctx, "t", "$1")
+	if err != nil {
+		return []Tag{}, err
+	}
+
+	query := `SELECT t.id, t.name, COUNT(rt.recipe_id)
+		FROM tags t
+		LEFT JOIN recipe_tags rt ON rt.tag_id = t.id
+		WHERE ` + scope + `
+		GROUP BY t.id, t.name
+		ORDER BY t.name`
+
+	rows, err := database.DB.Query(ctx, query, scopeId)
+	if err != nil {
+		return []Tag{}, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+
+		err = rows.Scan(&tag.ID, &tag.Name, &tag.Count)
+		if err != nil {
+			return []Tag{}, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Tag{}, err
+	}
+
+	return tags, nil
 }
 
 // Returns all tags for a given recipe.
 func FindTagsByRecipe(ctx context.Context, recipeId int64) ([]Tag, error) {
-	query := `SELECT id, recipe_id, name FROM recipe_tags WHERE recipe_id = $1`
+	query := `SELECT t.id, rt.recipe_id, t.name
+		FROM recipe_tags rt
+		JOIN tags t ON t.id = rt.tag_id
+		WHERE rt.recipe_id = $1
+		ORDER BY t.name`
 
 	rows, err := database.DB.Query(ctx, query, recipeId)
 	if err != nil && err == pgx.ErrNoRows {
@@ -46,9 +107,12 @@ func FindTagsByRecipe(ctx context.Context, recipeId int64) ([]Tag, error) {
 
 // Returns a tag by recipe ID and tag name.
 func FindTag(ctx context.Context, recipeId int64, name string) (Tag, error) {
-	query := `SELECT id, recipe_id, name FROM recipe_tags WHERE recipe_id = $1 AND name = $2`
+	query := `SELECT t.id, rt.recipe_id, t.name
+		FROM recipe_tags rt
+		JOIN tags t ON t.id = rt.tag_id
+		WHERE rt.recipe_id = $1 AND t.name = $2`
 
-	result := database.DB.QueryRow(ctx, query, recipeId, name)
+	result := database.DB.QueryRow(ctx, query, recipeId, NormalizeTag(name))
 
 	var tag Tag
 	err := result.Scan(&tag.ID, &tag.RecipeId, &tag.Name)
@@ -59,14 +123,246 @@ func FindTag(ctx context.Context, recipeId int64, name string) (Tag, error) {
 	return tag, nil
 }
 
-// Create new recipe tag.
+// Adds a tag to a recipe. The tag is created in the recipe's cookbook if it
+// does not exist there yet. Returns the ID of the tag.
 func CreateTag(ctx context.Context, recipeId int64, tag string) (int64, error) {
-	query := `INSERT INTO recipe_tags (recipe_id, name) VALUES ($1, $2) RETURNING id`
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := createTag(ctx, tx, recipeId, tag)
+	if err != nil {
+		return -1, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Renames a tag on every recipe that uses it.
+func RenameTag(ctx context.Context, id int64, name string) (Tag, error) {
+	name = NormalizeTag(name)
+	if name == "" {
+		return Tag{}, ErrEmptyTag
+	}
+
+	tag, err := findScopedTag(ctx, id)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	query := `UPDATE tags SET name = $1 WHERE id = $2`
+
+	_, err = database.DB.Exec(ctx, query, name, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return Tag{}, ErrTagExists
+		}
+
+		return Tag{}, err
+	}
+
+	recipeIds, err := findTaggedRecipes(ctx, []int64{id})
+	if err != nil {
+		return Tag{}, err
+	}
+
+	publishRecipeEvents(ctx, events.RecipeUpdated, recipeIds)
+
+	tag.Name = name
+	return tag, nil
+}
+
+// Merges the source tags into the target tag. Every recipe tagged with one
+// of the sources is tagged with the target instead, and the sources are
+// deleted.
+func MergeTags(ctx context.Context, targetId int64, sourceIds []int64) (Tag, error) {
+	target, err := findScopedTag(ctx, targetId)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	// Recipes tagged with a source are retagged with the target
+	recipeIds, err := findTaggedRecipes(ctx, sourceIds)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return Tag{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	relinkQuery := `INSERT INTO recipe_tags (recipe_id, tag_id)
+		SELECT recipe_id, $1 FROM recipe_tags WHERE tag_id = $2
+		ON CONFLICT DO NOTHING`
+	deleteQuery := `DELETE FROM tags WHERE id = $1`
+
+	for _, sourceId := range sourceIds {
+		if sourceId == targetId {
+			continue
+		}
+
+		// Sources must be in the same cookbook as the target
+		_, err = findScopedTag(ctx, sourceId)
+		if err != nil {
+			return Tag{}, err
+		}
+
+		_, err = tx.Exec(ctx, relinkQuery, targetId, sourceId)
+		if err != nil {
+			return Tag{}, err
+		}
+
+		_, err = tx.Exec(ctx, deleteQuery, sourceId)
+		if err != nil {
+			return Tag{}, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	publishRecipeEvents(ctx, events.RecipeUpdated, recipeIds)
+
+	return findScopedTag(ctx, target.ID)
+}
+
+// Deletes a tag and removes it from every recipe that uses it.
+func DeleteTag(ctx context.Context, id int64) error {
+	_, err := findScopedTag(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	recipeIds, err := findTaggedRecipes(ctx, []int64{id})
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM tags WHERE id = $1`
+
+	_, err = database.DB.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	publishRecipeEvents(ctx, events.RecipeUpdated, recipeIds)
+
+	return nil
+}
+
+// Helper Functions
+
+// Returns a tag with its usage count, as long as it belongs to the cookbook
+// the request is scoped to.
+func findScopedTag(ctx context.Context, id int64) (Tag, error) {
+	scope, scopeId, err := cookbookScope(ctx, "t", "$2")
+	if err != nil {
+		return Tag{}, err
+	}
+
+	query := `SELECT t.id, t.name, (SELECT COUNT(*) FROM recipe_tags WHERE tag_id = t.id)
+		FROM tags t
+		WHERE t.id = $1 AND ` + scope
+
+	var tag Tag
+	err = database.DB.QueryRow(ctx, query, id, scopeId).Scan(&tag.ID, &tag.Name, &tag.Count)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	return tag, nil
+}
+
+// Queries the recipes tagged with any of the tags.
+func findTaggedRecipes(ctx context.Context, tagIds []int64) ([]int64, error) {
+	query := `SELECT DISTINCT recipe_id FROM recipe_tags WHERE tag_id = ANY($1) ORDER BY recipe_id`
+
+	rows, err := database.DB.Query(ctx, query, tagIds)
+	if err != nil {
+		return []int64{}, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// Moves a recipe's tags into the cookbook the recipe currently belongs to.
+// Used in the transaction that moves a recipe, since tags are per cookbook.
+func relinkRecipeTags(ctx context.Context, tx pgx.Tx, recipeId int64) error {
+	tagsQuery := `SELECT t.name FROM recipe_tags rt JOIN tags t ON t.id = rt.tag_id WHERE rt.recipe_id = $1`
+
+	rows, err := tx.Query(ctx, tagsQuery, recipeId)
+	if err != nil {
+		return err
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM recipe_tags WHERE recipe_id = $1`
+
+	_, err = tx.Exec(ctx, query, recipeId)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		_, err = createTag(ctx, tx, recipeId, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Adds a tag to a recipe within a transaction, creating the tag in the
+// recipe's cookbook if it does not exist there yet.
+func createTag(ctx context.Context, tx pgx.Tx, recipeId int64, tag string) (int64, error) {
+	name := NormalizeTag(tag)
+	if name == "" {
+		return -1, ErrEmptyTag
+	}
 
-	row := database.DB.QueryRow(ctx, query, recipeId, tag)
+	// Tags belong to the same cookbook as the recipe they are added to
+	insertQuery := `INSERT INTO tags (user_id, household_id, name)
+		SELECT user_id, household_id, $2 FROM recipes WHERE id = $1
+		ON CONFLICT DO NOTHING`
+
+	_, err := tx.Exec(ctx, insertQuery, recipeId, name)
+	if err != nil {
+		return -1, err
+	}
+
+	query := `SELECT t.id FROM tags t
+		JOIN recipes r ON r.id = $1
+		WHERE t.name = $2 AND (
+			(r.household_id IS NULL AND t.household_id IS NULL AND t.user_id = r.user_id)
+			OR t.household_id = r.household_id
+		)`
 
 	var id int64
-	err := row.Scan(&id)
+	err = tx.QueryRow(ctx, query, recipeId, name).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	linkQuery := `INSERT INTO recipe_tags (recipe_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	_, err = tx.Exec(ctx, linkQuery, recipeId, id)
 	if err != nil {
 		return -1, err
 	}
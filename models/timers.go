@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+
+	"github.com/mjande/recipes-microservice/database"
+)
+
+// Timer is a timer in one of a recipe's steps, such as the 25 minutes in
+// "bake for 25 minutes". Steps are numbered from 1, in the order of the
+// lines of the recipe's instructions. Named timers, such as "rest", say what
+// they time.
+type Timer struct {
+	ID       int64   `json:"id"`
+	Step     int     `json:"step"`
+	Name     string  `json:"name"`
+	Quantity float32 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+// Helper Functions
+
+// Checks that every timer belongs to a step.
+func validateTimers(timers []Timer) error {
+	for _, timer := range timers {
+		if timer.Step < 1 {
+			return ErrInvalidStep
+		}
+	}
+
+	return nil
+}
+
+// Queries the timers of a recipe, in the order of its steps.
+func findTimers(ctx context.Context, recipeId int64) ([]Timer, error) {
+	query := `SELECT id, step, name, quantity, unit FROM recipe_timers WHERE recipe_id = $1 ORDER BY step, id`
+
+	rows, err := database.DB.Query(ctx, query, recipeId)
+	if err != nil {
+		return []Timer{}, err
+	}
+	defer rows.Close()
+
+	timers := []Timer{}
+	for rows.Next() {
+		var timer Timer
+
+		err = rows.Scan(&timer.ID, &timer.Step, &timer.Name, &timer.Quantity, &timer.Unit)
+		if err != nil {
+			return []Timer{}, err
+		}
+
+		timers = append(timers, timer)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Timer{}, err
+	}
+
+	return timers, nil
+}
+
+// Replaces the timers of a recipe.
+func replaceTimers(ctx context.Context, recipeId int64, timers []Timer) error {
+	_, err := database.DB.Exec(ctx, `DELETE FROM recipe_timers WHERE recipe_id = $1`, recipeId)
+	if err != nil {
+		return err
+	}
+
+	for _, timer := range timers {
+		query := `INSERT INTO recipe_timers (recipe_id, step, name, quantity, unit) VALUES ($1, $2, $3, $4, $5)`
+
+		_, err = database.DB.Exec(ctx, query, recipeId, timer.Step, timer.Name, timer.Quantity, timer.Unit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
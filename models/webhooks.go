@@ -0,0 +1,569 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/mjande/recipes-microservice/database"
+	"github.com/mjande/recipes-microservice/utils"
+	"github.com/mjande/recipes-microservice/webhooks"
+)
+
+// Most outbox events turned into deliveries in one transaction.
+const webhookQueueBatch = 100
+
+// Most deliveries listed for a webhook.
+const maxListedDeliveries = 100
+
+// How long a claimed delivery is held by the dispatcher sending it. Another
+// dispatcher sends it again if it is neither retried nor finished by then,
+// so this must be longer than sending a whole batch can take.
+const webhookClaimHold = 15 * time.Minute
+
+var ErrInvalidWebhookURL = errors.New("url must be an absolute http or https URL")
+var ErrForbiddenWebhookAddress = webhooks.ErrForbiddenAddress
+var ErrUnresolvableWebhookHost = errors.New("url host could not be resolved")
+var ErrUnknownWebhookEvent = errors.New("events must be recipe.created, recipe.updated or recipe.deleted")
+var ErrUnknownDeliveryStatus = errors.New("status must be pending, succeeded or dead")
+var ErrDeliveryNotDead = errors.New("only dead deliveries can be retried")
+
+var webhookEvents = []string{webhooks.RecipeCreated, webhooks.RecipeUpdated, webhooks.RecipeDeleted}
+
+// An endpoint notified of recipe events. Global webhooks are registered by
+// admins and hear about every cookbook. The secret requests are signed with
+// is only returned when the webhook is created.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Global    bool      `json:"global"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Changes to a webhook. Fields left nil keep their current value.
+type WebhookPatch struct {
+	URL    *string   `json:"url"`
+	Events *[]string `json:"events"`
+	Active *bool     `json:"active"`
+}
+
+// An event sent, or being sent, to a webhook, with a log of each attempt.
+type WebhookDelivery struct {
+	ID            int64            `json:"id"`
+	WebhookID     int64            `json:"webhookId"`
+	Event         string           `json:"event"`
+	Status        string           `json:"status"`
+	Attempts      int              `json:"attempts"`
+	NextAttemptAt *time.Time       `json:"nextAttemptAt"`
+	CreatedAt     time.Time        `json:"createdAt"`
+	DeliveredAt   *time.Time       `json:"deliveredAt"`
+	Body          json.RawMessage  `json:"body"`
+	AttemptLog    []WebhookAttempt `json:"attemptLog"`
+}
+
+type WebhookAttempt struct {
+	AttemptedAt time.Time `json:"attemptedAt"`
+	StatusCode  *int      `json:"statusCode"`
+	Error       string    `json:"error"`
+	DurationMs  int       `json:"durationMs"`
+}
+
+// The body sent to webhooks. Data is the recipe as of the event.
+type webhookEvent struct {
+	ID         int64           `json:"id"`
+	Event      string          `json:"event"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Queries the webhooks of the cookbook the request is scoped to, along with
+// the global webhooks for admins.
+func ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	scope, scopeId, err := cookbookScope(ctx, "w", "$1")
+	if err != nil {
+		return []Webhook{}, err
+	}
+
+	query := `SELECT w.id, w.url, w.events, w.active, w.global, w.created_at
+		FROM webhooks w
+		WHERE (` + scope + ` AND NOT w.global) OR (w.global AND $2)
+		ORDER BY w.id`
+
+	rows, err := database.DB.Query(ctx, query, scopeId, utils.IsAdminFromContext(ctx))
+	if err != nil {
+		return []Webhook{}, err
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+
+		err = rows.Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Active, &webhook.Global, &webhook.CreatedAt)
+		if err != nil {
+			return []Webhook{}, err
+		}
+
+		hooks = append(hooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []Webhook{}, err
+	}
+
+	return hooks, nil
+}
+
+// Queries a single webhook, as long as it belongs to the cookbook the
+// request is scoped to or is global and the user is an admin.
+func FindWebhook(ctx context.Context, id int64) (Webhook, error) {
+	scope, scopeId, err := cookbookScope(ctx, "w", "$2")
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	query := `SELECT w.id, w.url, w.events, w.active, w.global, w.created_at
+		FROM webhooks w
+		WHERE w.id = $1 AND ((` + scope + ` AND NOT w.global) OR (w.global AND $3))`
+
+	var webhook Webhook
+	err = database.DB.QueryRow(ctx, query, id, scopeId, utils.IsAdminFromContext(ctx)).Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Active, &webhook.Global, &webhook.CreatedAt)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+// Registers a webhook in the cookbook the request is scoped to, or a global
+// one if the user is an admin. Returns its ID and the secret its requests
+// are signed with.
+func CreateWebhook(ctx context.Context, webhook Webhook) (int64, string, error) {
+	err := validateWebhook(ctx, webhook)
+	if err != nil {
+		return -1, "", err
+	}
+
+	if webhook.Global && !utils.IsAdminFromContext(ctx) {
+		return -1, "", ErrForbidden
+	}
+
+	userId := utils.ExtractUserIDFromContext(ctx)
+
+	var householdId *string
+	if !webhook.Global {
+		householdId, err = scopedHouseholdID(ctx)
+		if err != nil {
+			return -1, "", err
+		}
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		return -1, "", err
+	}
+
+	query := `INSERT INTO webhooks (user_id, household_id, global, url, secret, events)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	var id int64
+	err = database.DB.QueryRow(ctx, query, userId, householdId, webhook.Global, webhook.URL, secret, webhookEventList(webhook.Events)).Scan(&id)
+	if err != nil {
+		return -1, "", err
+	}
+
+	return id, secret, nil
+}
+
+// Updates the URL, events or active flag of a webhook, leaving the fields
+// the patch does not set unchanged. Inactive webhooks are not sent events,
+// and their pending deliveries wait until they are active again. Returns the
+// updated webhook.
+func UpdateWebhook(ctx context.Context, id int64, patch WebhookPatch) (Webhook, error) {
+	webhook, err := FindWebhook(ctx, id)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	if patch.URL != nil {
+		webhook.URL = *patch.URL
+	}
+	if patch.Events != nil {
+		webhook.Events = *patch.Events
+	}
+	if patch.Active != nil {
+		webhook.Active = *patch.Active
+	}
+
+	err = validateWebhook(ctx, webhook)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	query := `UPDATE webhooks SET url = $1, events = $2, active = $3 WHERE id = $4`
+
+	_, err = database.DB.Exec(ctx, query, webhook.URL, webhookEventList(webhook.Events), webhook.Active, id)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+// Removes a webhook along with its deliveries.
+func DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := FindWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM webhooks WHERE id = $1`
+
+	_, err = database.DB.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Queries the most recent deliveries to a webhook, newest first, with their
+// attempts. When status is set, only deliveries with that status are
+// returned.
+func ListWebhookDeliveries(ctx context.Context, webhookId int64, status string) ([]WebhookDelivery, error) {
+	if status != "" && status != webhooks.StatusPending && status != webhooks.StatusSucceeded && status != webhooks.StatusDead {
+		return []WebhookDelivery{}, ErrUnknownDeliveryStatus
+	}
+
+	_, err := FindWebhook(ctx, webhookId)
+	if err != nil {
+		return []WebhookDelivery{}, err
+	}
+
+	query := `SELECT id, webhook_id, event_type, status, attempts,
+			CASE WHEN status = 'pending' THEN next_attempt_at END, created_at, delivered_at, body
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3`
+
+	rows, err := database.DB.Query(ctx, query, webhookId, status, maxListedDeliveries)
+	if err != nil {
+		return []WebhookDelivery{}, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	deliveryIds := []int64{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var body string
+
+		err = rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Status, &delivery.Attempts,
+			&delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.DeliveredAt, &body)
+		if err != nil {
+			return []WebhookDelivery{}, err
+		}
+
+		delivery.Body = json.RawMessage(body)
+		delivery.AttemptLog = []WebhookAttempt{}
+		deliveries = append(deliveries, delivery)
+		deliveryIds = append(deliveryIds, delivery.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []WebhookDelivery{}, err
+	}
+
+	attempts, err := findWebhookAttempts(ctx, deliveryIds)
+	if err != nil {
+		return []WebhookDelivery{}, err
+	}
+
+	for i := range deliveries {
+		if attemptLog, ok := attempts[deliveries[i].ID]; ok {
+			deliveries[i].AttemptLog = attemptLog
+		}
+	}
+
+	return deliveries, nil
+}
+
+// Sends a dead delivery again, with a fresh set of attempts.
+func RetryWebhookDelivery(ctx context.Context, webhookId int64, deliveryId int64) error {
+	_, err := FindWebhook(ctx, webhookId)
+	if err != nil {
+		return err
+	}
+
+	var status string
+	err = database.DB.QueryRow(ctx, `SELECT status FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2`, deliveryId, webhookId).Scan(&status)
+	if err != nil {
+		return err
+	}
+
+	if status != webhooks.StatusDead {
+		return ErrDeliveryNotDead
+	}
+
+	query := `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND status = 'dead'`
+
+	_, err = database.DB.Exec(ctx, query, deliveryId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WebhookOutbox is the webhooks.Store backed by the database.
+type WebhookOutbox struct{}
+
+var _ webhooks.Store = WebhookOutbox{}
+
+// Turns the events in the outbox into deliveries to the active webhooks
+// subscribed to them: those of the event's cookbook and the global ones.
+func (WebhookOutbox) QueueDeliveries(ctx context.Context) error {
+	for {
+		queued, err := queueWebhookBatch(ctx)
+		if err != nil {
+			return err
+		}
+
+		if queued < webhookQueueBatch {
+			return nil
+		}
+	}
+}
+
+func (WebhookOutbox) ClaimDeliveries(ctx context.Context, limit int) ([]webhooks.Delivery, error) {
+	query := `UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT due.id FROM webhook_deliveries due
+			JOIN webhooks dw ON dw.id = due.webhook_id
+			WHERE due.status = 'pending' AND due.next_attempt_at <= NOW() AND dw.active
+			ORDER BY due.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF due SKIP LOCKED
+		)
+		RETURNING d.id, w.url, w.secret, d.event_type, d.body, d.attempts`
+
+	rows, err := database.DB.Query(ctx, query, limit, webhookClaimHold.Seconds())
+	if err != nil {
+		return []webhooks.Delivery{}, err
+	}
+	defer rows.Close()
+
+	deliveries := []webhooks.Delivery{}
+	for rows.Next() {
+		var delivery webhooks.Delivery
+		var body string
+
+		err = rows.Scan(&delivery.ID, &delivery.URL, &delivery.Secret, &delivery.Event, &body, &delivery.Attempts)
+		if err != nil {
+			return []webhooks.Delivery{}, err
+		}
+
+		delivery.Body = []byte(body)
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []webhooks.Delivery{}, err
+	}
+
+	return deliveries, nil
+}
+
+func (WebhookOutbox) RecordAttempt(ctx context.Context, delivery webhooks.Delivery, result webhooks.Result, status string, retryAt time.Time) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var statusCode *int
+	if result.StatusCode != 0 {
+		statusCode = &result.StatusCode
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO webhook_attempts (delivery_id, status_code, error, duration_ms) VALUES ($1, $2, $3, $4)`,
+		delivery.ID, statusCode, result.Error, result.Duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, next_attempt_at = $2,
+			delivered_at = CASE WHEN $1 = 'succeeded' THEN NOW() END
+		WHERE id = $3`
+
+	_, err = tx.Exec(ctx, query, status, retryAt, delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Helper Functions
+
+// Checks that a webhook subscribes to known events and that its URL is an
+// http or https URL of a host outside the service's network.
+func validateWebhook(ctx context.Context, webhook Webhook) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	err = webhooks.CheckHost(ctx, target.Hostname())
+	if errors.Is(err, webhooks.ErrForbiddenAddress) {
+		return ErrForbiddenWebhookAddress
+	} else if err != nil {
+		return ErrUnresolvableWebhookHost
+	}
+
+	for _, event := range webhook.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return ErrUnknownWebhookEvent
+		}
+	}
+
+	return nil
+}
+
+// Returns the events a webhook subscribes to, with none meaning every event.
+func webhookEventList(events []string) []string {
+	if events == nil {
+		return []string{}
+	}
+
+	return events
+}
+
+// Turns up to webhookQueueBatch outbox events into deliveries, removing them
+// from the outbox in the same transaction. Returns how many were queued.
+func queueWebhookBatch(ctx context.Context) (int, error) {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT id, event_type, user_id, household_id, payload::text, created_at
+		FROM webhook_outbox
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, query, webhookQueueBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	type outboxEvent struct {
+		webhookEvent
+		userId      int64
+		householdId *int64
+	}
+
+	events := []outboxEvent{}
+	for rows.Next() {
+		var event outboxEvent
+		var payload string
+
+		err = rows.Scan(&event.ID, &event.Event, &event.userId, &event.householdId, &payload, &event.OccurredAt)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		event.Data = json.RawMessage(payload)
+		events = append(events, event)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	insert := `INSERT INTO webhook_deliveries (webhook_id, event_type, body)
+		SELECT w.id, $1, $2 FROM webhooks w
+		WHERE w.active AND (cardinality(w.events) = 0 OR $1 = ANY(w.events))
+			AND (w.global
+				OR w.household_id = $4
+				OR ($4::integer IS NULL AND w.household_id IS NULL AND w.user_id = $3))`
+
+	ids := []int64{}
+	for _, event := range events {
+		body, err := json.Marshal(event.webhookEvent)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(ctx, insert, event.Event, string(body), event.userId, event.householdId)
+		if err != nil {
+			return 0, err
+		}
+
+		ids = append(ids, event.ID)
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM webhook_outbox WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
+
+// Queries the attempts at deliveries, oldest first, by delivery.
+func findWebhookAttempts(ctx context.Context, deliveryIds []int64) (map[int64][]WebhookAttempt, error) {
+	attempts := map[int64][]WebhookAttempt{}
+	if len(deliveryIds) == 0 {
+		return attempts, nil
+	}
+
+	query := `SELECT delivery_id, attempted_at, status_code, error, duration_ms
+		FROM webhook_attempts
+		WHERE delivery_id = ANY($1)
+		ORDER BY id`
+
+	rows, err := database.DB.Query(ctx, query, deliveryIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deliveryId int64
+		var attempt WebhookAttempt
+
+		err = rows.Scan(&deliveryId, &attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMs)
+		if err != nil {
+			return nil, err
+		}
+
+		attempts[deliveryId] = append(attempts[deliveryId], attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
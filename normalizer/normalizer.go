@@ -0,0 +1,125 @@
+// Package normalizer turns free-form ingredient names, as users type them,
+// into a canonical form that can be looked up in the ingredient catalog.
+package normalizer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Words describing how an ingredient is prepared or sized. They do not
+// change which ingredient it is, so they are dropped.
+var descriptors = map[string]bool{
+	"fresh": true, "freshly": true, "chopped": true, "diced": true, "minced": true,
+	"sliced": true, "grated": true, "shredded": true, "crushed": true, "cubed": true,
+	"peeled": true, "pitted": true, "trimmed": true, "rinsed": true, "drained": true,
+	"large": true, "small": true, "medium": true, "finely": true, "roughly": true,
+	"thinly": true, "coarsely": true, "softened": true, "melted": true, "beaten": true,
+	"room": true, "temperature": true, "optional": true, "to": true, "taste": true,
+	"boneless": true, "skinless": true, "halved": true, "quartered": true,
+	"organic": true, "packed": true, "heaping": true,
+}
+
+// Words that look plural but are not, or whose plural rule is irregular.
+var irregularSingulars = map[string]string{
+	"asparagus": "asparagus",
+	"couscous":  "couscous",
+	"hummus":    "hummus",
+	"molasses":  "molasses",
+	"swiss":     "swiss",
+	"brussels":  "brussels",
+	"hummous":   "hummous",
+	"leaves":    "leaf",
+	"loaves":    "loaf",
+	"halves":    "half",
+	"knives":    "knife",
+	"olives":    "olive",
+	"cloves":    "clove",
+	"chives":    "chives",
+	"anchovies": "anchovy",
+	"greens":    "greens",
+	"oats":      "oats",
+	"grits":     "grits",
+	"noodles":   "noodle",
+	"pasta":     "pasta",
+	"feet":      "foot",
+	"geese":     "goose",
+	"mice":      "mouse",
+}
+
+var parenthetical = regexp.MustCompile(`\([^)]*\)`)
+var nonLetters = regexp.MustCompile(`[^a-z\s'-]+`)
+
+// Returns the canonical form of an ingredient name: lower case, without
+// parentheticals, anything after a comma, preparation descriptors or
+// quantities, and with the final word made singular. "Roma Tomatoes, diced"
+// becomes "roma tomato".
+func Normalize(name string) string {
+	name = strings.ToLower(name)
+	name = parenthetical.ReplaceAllString(name, " ")
+
+	if comma := strings.Index(name, ","); comma >= 0 {
+		name = name[:comma]
+	}
+
+	name = nonLetters.ReplaceAllString(name, " ")
+
+	var words []string
+	for _, word := range strings.Fields(name) {
+		word = strings.Trim(word, "'-")
+		if word == "" || descriptors[word] {
+			continue
+		}
+
+		words = append(words, word)
+	}
+
+	if len(words) == 0 {
+		return ""
+	}
+
+	words[len(words)-1] = Singular(words[len(words)-1])
+	return strings.Join(words, " ")
+}
+
+// Returns the names to look an ingredient up by in the catalog, most
+// specific first. Leading words are dropped one at a time, so "roma tomato"
+// yields "roma tomato" and then "tomato".
+func Candidates(name string) []string {
+	normalized := Normalize(name)
+	if normalized == "" {
+		return []string{}
+	}
+
+	words := strings.Fields(normalized)
+
+	candidates := make([]string, 0, len(words))
+	for i := range words {
+		candidates = append(candidates, strings.Join(words[i:], " "))
+	}
+
+	return candidates
+}
+
+// Returns the singular form of an English noun using common pluralization
+// rules.
+func Singular(word string) string {
+	if singular, ok := irregularSingulars[word]; ok {
+		return singular
+	}
+
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 4 && strings.HasSuffix(word, "oes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"),
+		strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "xes"):
+		return word[:len(word)-2]
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+
+	return word
+}
@@ -0,0 +1,89 @@
+package normalizer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Roma Tomatoes, diced", "roma tomato"},
+		{"1/2 freshly grated Lemons", "lemon"},
+		{"Onion (large), finely chopped", "onion"},
+		{"Boneless skinless chicken breasts", "chicken breast"},
+		{"salt, to taste", "salt"},
+		{"Baby Spinach Leaves", "baby spinach leaf"},
+		{"cloves", "clove"},
+		{"Brussels sprouts", "brussels sprout"},
+		{"Swiss", "swiss"},
+		{"cook's sherry", "cook's sherry"},
+		{"  Butter -- softened  ", "butter"},
+		{"chopped", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Normalize(test.name); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSingular(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"berries", "berry"},
+		{"tomatoes", "tomato"},
+		{"peaches", "peach"},
+		{"radishes", "radish"},
+		{"boxes", "box"},
+		{"glasses", "glass"},
+		{"carrots", "carrot"},
+		{"leaves", "leaf"},
+		{"anchovies", "anchovy"},
+		{"couscous", "couscous"},
+		{"molasses", "molasses"},
+		{"oats", "oats"},
+		{"grass", "grass"},
+		{"citrus", "citrus"},
+		{"quinoa", "quinoa"},
+		{"peas", "pea"},
+		{"ties", "tie"},
+		{"gas", "gas"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.word, func(t *testing.T) {
+			if got := Singular(test.word); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Roma Tomatoes, diced", []string{"roma tomato", "tomato"}},
+		{"extra virgin olive oil", []string{"extra virgin olive oil", "virgin olive oil", "olive oil", "oil"}},
+		{"Salt", []string{"salt"}},
+		{"fresh", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Candidates(test.name); !slices.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package nutrition estimates calories and macronutrients for recipes from a
+// bundled, offline food dataset.
+//
+// The dataset in foods.csv lists approximate nutrient values per 100 g,
+// derived from the USDA FoodData Central database, for every entry in the
+// ingredient catalog. It also records each food's density in g/ml, used to
+// weigh ingredients measured by volume, and the weight of a typical piece in
+// grams, used to weigh ingredients measured by count. A value of 0 means the
+// conversion is not known for that food.
+package nutrition
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"math"
+	"strconv"
+
+	"github.com/mjande/recipes-microservice/normalizer"
+	"github.com/mjande/recipes-microservice/units"
+)
+
+//go:embed foods.csv
+var foodsCSV []byte
+
+var foods = mustLoadFoods(foodsCSV)
+
+// Nutrients holds nutrient amounts. Sodium is in milligrams, calories in
+// kcal and everything else in grams.
+type Nutrients struct {
+	Calories      float64 `json:"calories"`
+	Protein       float64 `json:"protein"`
+	Fat           float64 `json:"fat"`
+	Carbohydrates float64 `json:"carbohydrates"`
+	Fiber         float64 `json:"fiber"`
+	Sodium        float64 `json:"sodium"`
+}
+
+// Food is an entry of the bundled dataset.
+type Food struct {
+	Name       string
+	Per100g    Nutrients
+	Density    float64
+	PieceGrams float64
+}
+
+// Item is an ingredient to calculate nutrition for. Name should be the
+// ingredient's catalog name when it is known, otherwise the name as written.
+type Item struct {
+	Name     string
+	Quantity float64
+	Unit     string
+}
+
+type IngredientNutrition struct {
+	Name      string    `json:"name"`
+	Grams     float64   `json:"grams"`
+	Nutrients Nutrients `json:"nutrients"`
+}
+
+type Unresolved struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type Report struct {
+	Servings    int                   `json:"servings"`
+	Total       Nutrients             `json:"total"`
+	PerServing  *Nutrients            `json:"perServing"`
+	Ingredients []IngredientNutrition `json:"ingredients"`
+	Unresolved  []Unresolved          `json:"unresolved"`
+}
+
+// Looks up a food in the bundled dataset by name. The name is normalized
+// first, and less specific forms of it are tried if there is no exact match.
+func Lookup(name string) (Food, bool) {
+	for _, candidate := range normalizer.Candidates(name) {
+		if food, ok := foods[candidate]; ok {
+			return food, true
+		}
+	}
+
+	return Food{}, false
+}
+
+// Converts a quantity of a food into grams. Returns false with a reason when
+// the unit is unknown or the food lacks the density or piece weight needed.
+func Grams(food Food, quantity float64, unit string) (float64, string, bool) {
+	parsed, ok := units.Parse(unit)
+	if !ok {
+		return 0, "unknown unit \"" + unit + "\"", false
+	}
+
+	base := units.ToBase(quantity, parsed)
+
+	switch parsed.Kind {
+	case units.Mass:
+		return base, "", true
+	case units.Volume:
+		if food.Density == 0 {
+			return 0, "no density known to convert volume to weight", false
+		}
+
+		return base * food.Density, "", true
+	default:
+		if food.PieceGrams == 0 {
+			return 0, "no piece weight known to convert count to weight", false
+		}
+
+		return base * food.PieceGrams, "", true
+	}
+}
+
+// Calculates the nutrition of a recipe made from the given items. Items that
+// cannot be found in the dataset or converted to grams are listed as
+// unresolved and left out of the totals. Per-serving values are only given
+// when the number of servings is known.
+func Calculate(items []Item, servings int) Report {
+	report := Report{
+		Servings:    servings,
+		Ingredients: []IngredientNutrition{},
+		Unresolved:  []Unresolved{},
+	}
+
+	for _, item := range items {
+		food, ok := Lookup(item.Name)
+		if !ok {
+			report.Unresolved = append(report.Unresolved, Unresolved{Name: item.Name, Reason: "not in nutrition dataset"})
+			continue
+		}
+
+		grams, reason, ok := Grams(food, item.Quantity, item.Unit)
+		if !ok {
+			report.Unresolved = append(report.Unresolved, Unresolved{Name: item.Name, Reason: reason})
+			continue
+		}
+
+		nutrients := food.Per100g.scale(grams / 100)
+		report.Ingredients = append(report.Ingredients, IngredientNutrition{
+			Name:      item.Name,
+			Grams:     round(grams),
+			Nutrients: nutrients.rounded(),
+		})
+		report.Total = report.Total.add(nutrients)
+	}
+
+	if servings > 0 {
+		perServing := report.Total.scale(1 / float64(servings)).rounded()
+		report.PerServing = &perServing
+	}
+
+	report.Total = report.Total.rounded()
+	return report
+}
+
+// Helper Functions
+
+func (n Nutrients) add(other Nutrients) Nutrients {
+	return Nutrients{
+		Calories:      n.Calories + other.Calories,
+		Protein:       n.Protein + other.Protein,
+		Fat:           n.Fat + other.Fat,
+		Carbohydrates: n.Carbohydrates + other.Carbohydrates,
+		Fiber:         n.Fiber + other.Fiber,
+		Sodium:        n.Sodium + other.Sodium,
+	}
+}
+
+func (n Nutrients) scale(factor float64) Nutrients {
+	return Nutrients{
+		Calories:      n.Calories * factor,
+		Protein:       n.Protein * factor,
+		Fat:           n.Fat * factor,
+		Carbohydrates: n.Carbohydrates * factor,
+		Fiber:         n.Fiber * factor,
+		Sodium:        n.Sodium * factor,
+	}
+}
+
+func (n Nutrients) rounded() Nutrients {
+	return Nutrients{
+		Calories:      round(n.Calories),
+		Protein:       round(n.Protein),
+		Fat:           round(n.Fat),
+		Carbohydrates: round(n.Carbohydrates),
+		Fiber:         round(n.Fiber),
+		Sodium:        round(n.Sodium),
+	}
+}
+
+// Rounds to one decimal place.
+func round(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+// Parses the bundled dataset. The dataset is compiled into the binary, so a
+// malformed file is a programming error and panics at startup.
+func mustLoadFoods(data []byte) map[string]Food {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		panic("nutrition: cannot read foods.csv: " + err.Error())
+	}
+
+	foods := map[string]Food{}
+	for i, record := range records {
+		// Skip the header row
+		if i == 0 {
+			continue
+		}
+
+		values := make([]float64, len(record)-1)
+		for j, field := range record[1:] {
+			values[j], err = strconv.ParseFloat(field, 64)
+			if err != nil {
+				panic("nutrition: invalid value in foods.csv row " + strconv.Itoa(i+1) + ": " + err.Error())
+			}
+		}
+
+		foods[record[0]] = Food{
+			Name: record[0],
+			Per100g: Nutrients{
+				Calories:      values[0],
+				Protein:       values[1],
+				Fat:           values[2],
+				Carbohydrates: values[3],
+				Fiber:         values[4],
+				Sodium:        values[5],
+			},
+			Density:    values[6],
+			PieceGrams: values[7],
+		}
+	}
+
+	return foods
+}
@@ -0,0 +1,303 @@
+// Package pdf lays out simple text documents, such as printable recipes, and
+// writes them as PDF files. It only uses the standard Helvetica fonts, which
+// every PDF reader provides, so no fonts are embedded.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page size (US Letter) and margins, in points.
+const (
+	pageWidth    = 612
+	pageHeight   = 792
+	margin       = 54
+	contentWidth = pageWidth - 2*margin
+)
+
+// Font resource names
+const (
+	regular = "F1"
+	bold    = "F2"
+)
+
+// Document is a PDF document being laid out from top to bottom. Text that
+// does not fit on the current page continues on a new one.
+type Document struct {
+	title string
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// Creates an empty document. The title is stored in the document's metadata.
+func New(title string) *Document {
+	doc := &Document{title: title}
+	doc.PageBreak()
+	return doc
+}
+
+// Adds a large bold title.
+func (d *Document) Title(text string) {
+	d.paragraph(text, bold, 20, 0, 8)
+}
+
+// Adds a bold section heading.
+func (d *Document) Heading(text string) {
+	d.Space(6)
+	d.paragraph(text, bold, 14, 0, 4)
+}
+
+// Adds a paragraph of body text, wrapped to the page width.
+func (d *Document) Text(text string) {
+	d.paragraph(text, regular, 11, 0, 6)
+}
+
+// Adds a line of small grey text, such as details about a recipe.
+func (d *Document) Caption(text string) {
+	d.current().WriteString("0.4 g\n")
+	d.paragraph(text, regular, 9, 0, 6)
+	d.current().WriteString("0 g\n")
+}
+
+// Adds an item of a bulleted list.
+func (d *Document) Bullet(text string) {
+	d.marker("•", text)
+}
+
+// Adds an item of a numbered list.
+func (d *Document) Numbered(number int, text string) {
+	d.marker(fmt.Sprintf("%d.", number), text)
+}
+
+// Adds an item with an empty checkbox in front, for lists to tick off.
+func (d *Document) Checkbox(text string) {
+	d.ensureSpace(lineHeight(11))
+	size := 8.0
+	top := d.y - 2
+	fmt.Fprintf(d.current(), "0.5 w %.2f %.2f %.2f %.2f re S\n", float64(margin), top-size, size, size)
+	d.paragraph(text, regular, 11, 16, 2)
+}
+
+// Adds vertical space, in points.
+func (d *Document) Space(points float64) {
+	d.y -= points
+}
+
+// Continues on a new page.
+func (d *Document) PageBreak() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - margin
+}
+
+// Writes the document as a PDF file.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	offsets := []int{}
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 to 4 are the catalog, page tree, fonts and metadata. Each
+	// page then takes two objects: the page and its content stream.
+	kids := []string{}
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 6+2*i))
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title %s /Producer (recipes-microservice) >>", literal(d.title)))
+
+	for i, page := range d.pages {
+		var compressed bytes.Buffer
+		writer := zlib.NewWriter(&compressed)
+		_, err := writer.Write(page.Bytes())
+		if err != nil {
+			return 0, err
+		}
+
+		err = writer.Close()
+		if err != nil {
+			return 0, err
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, regular, bold, 7+2*i))
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+			compressed.Len(), compressed.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(offsets)+1, xref)
+
+	return out.WriteTo(w)
+}
+
+// Helper Functions
+
+func (d *Document) current() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Starts a new page unless the given height still fits on this one.
+func (d *Document) ensureSpace(height float64) {
+	if d.y-height < margin {
+		d.PageBreak()
+	}
+}
+
+// Adds a list item with a marker such as a bullet or number in front.
+func (d *Document) marker(marker string, text string) {
+	d.ensureSpace(lineHeight(11))
+	d.line(marker, regular, 11, margin+4)
+	d.paragraph(text, regular, 11, 18, 2)
+}
+
+// Wraps text to the page width less the indent and adds it line by line,
+// followed by the given space.
+func (d *Document) paragraph(text string, font string, size float64, indent float64, after float64) {
+	for _, line := range wrap(text, font, size, contentWidth-indent) {
+		d.ensureSpace(lineHeight(size))
+		d.line(line, font, size, margin+indent)
+		d.y -= lineHeight(size)
+	}
+
+	d.y -= after
+}
+
+// Draws a single line of text with its top at the current position, without
+// moving down.
+func (d *Document) line(text string, font string, size float64, x float64) {
+	baseline := d.y - size
+	fmt.Fprintf(d.current(), "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, baseline, literal(text))
+}
+
+func lineHeight(size float64) float64 {
+	return size * 1.3
+}
+
+// Splits text into lines no wider than width. Line breaks in the text are
+// kept, and words too long for a line are put on a line of their own.
+func wrap(text string, font string, size float64, width float64) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		current := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if current != "" {
+				candidate = current + " " + word
+			}
+
+			if current != "" && textWidth(candidate, font, size) > width {
+				lines = append(lines, current)
+				candidate = word
+			}
+
+			current = candidate
+		}
+
+		lines = append(lines, current)
+	}
+
+	return lines
+}
+
+// Returns the width of text in points.
+func textWidth(text string, font string, size float64) float64 {
+	widths := helveticaWidths
+	if font == bold {
+		widths = helveticaBoldWidths
+	}
+
+	var total int
+	for _, b := range encode(text) {
+		if b >= 32 && b <= 126 {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+
+	return float64(total) * size / 1000
+}
+
+// Encodes text as a PDF string literal in WinAnsiEncoding.
+func literal(text string) string {
+	var out strings.Builder
+	out.WriteByte('(')
+	for _, b := range encode(text) {
+		switch b {
+		case '(', ')', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(b)
+		default:
+			out.WriteByte(b)
+		}
+	}
+	out.WriteByte(')')
+
+	return out.String()
+}
+
+// Characters of WinAnsiEncoding outside of ASCII and Latin-1
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// Converts text to WinAnsiEncoding, replacing characters it cannot show.
+func encode(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			encoded = append(encoded, ' ')
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			encoded = append(encoded, byte(r))
+		case winAnsiSpecials[r] != 0:
+			encoded = append(encoded, winAnsiSpecials[r])
+		case r < 32:
+			// Drop other control characters
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+
+	return encoded
+}
+
+// Advance widths of the printable ASCII characters, from space to tilde, in
+// thousandths of the font size.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
@@ -0,0 +1,137 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Pancakes", "Pancakes"},
+		{"Crème brûlée", "Cr\xe8me br\xfbl\xe9e"},
+		{"1\t2", "1 2"},
+		{"10–15 min", "10\x9615 min"},
+		{"“quoted” • €5", "\x93quoted\x94 \x95 \x805"},
+		{"a\x01b\nc", "abc"},
+		{"寿司", "??"},
+	}
+
+	for _, test := range tests {
+		if got := string(encode(test.text)); got != test.want {
+			t.Errorf("encode(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	if got := literal(`Stew (slow) \ fast`); got != `(Stew \(slow\) \\ fast)` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	tests := []struct {
+		text string
+		font string
+		want float64
+	}{
+		{"", regular, 0},
+		{"a", regular, 5.56},
+		{"a", bold, 5.56},
+		{"i", regular, 2.22},
+		{"i", bold, 2.78},
+		{"寿", regular, 5.56},
+	}
+
+	for _, test := range tests {
+		if got := textWidth(test.text, test.font, 10); fmt.Sprintf("%.2f", got) != fmt.Sprintf("%.2f", test.want) {
+			t.Errorf("textWidth(%q, %s) = %v, want %v", test.text, test.font, got, test.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	// At size 10, "aaaa" is 22.24 points wide and a space 2.78
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", []string{""}},
+		{"fits", "aaaa aaaa", []string{"aaaa aaaa"}},
+		{"wraps", "aaaa aaaa aaaa", []string{"aaaa aaaa", "aaaa"}},
+		{"collapses spaces", "  aaaa   aaaa ", []string{"aaaa aaaa"}},
+		{"long word on its own line", "aa aaaaaaaaaaaa aa", []string{"aa", "aaaaaaaaaaaa", "aa"}},
+		{"keeps line breaks", "aaaa\n\naaaa", []string{"aaaa", "", "aaaa"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := wrap(test.text, regular, 10, 50); !slices.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteToOffsets(t *testing.T) {
+	doc := New("Soup (for two)")
+	doc.Title("Soup")
+	doc.Caption("Serves 2")
+	for i := 1; i <= 80; i++ {
+		doc.Numbered(i, "Stir the soup.")
+	}
+
+	var out bytes.Buffer
+	if _, err := doc.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+
+	// 80 numbered lines do not fit on one page
+	if len(doc.pages) < 2 {
+		t.Errorf("got %d pages, want at least 2", len(doc.pages))
+	}
+	if !bytes.Contains(data, []byte(fmt.Sprintf("/Count %d", len(doc.pages)))) {
+		t.Errorf("page tree does not count %d pages", len(doc.pages))
+	}
+
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	if match == nil {
+		t.Fatal("missing startxref")
+	}
+
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+
+	lines := strings.Split(string(data[xref:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+
+	// Objects are 5 shared ones and 2 per page, plus the free entry
+	if count != 6+2*len(doc.pages) {
+		t.Errorf("xref has %d entries, want %d", count, 6+2*len(doc.pages))
+	}
+
+	for object := 1; object < count; object++ {
+		entry := lines[2+object]
+		offset, _ := strconv.Atoi(entry[:10])
+
+		if header := fmt.Sprintf("%d 0 obj\n", object); !bytes.HasPrefix(data[offset:], []byte(header)) {
+			t.Errorf("xref entry %q for object %d does not point at it", entry, object)
+		}
+	}
+}
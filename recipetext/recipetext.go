@@ -0,0 +1,803 @@
+// Package recipetext reads and writes recipes as plain text, for people who
+// keep their recipes in a text editor. Two formats are supported.
+//
+// Cooklang (https://cooklang.org) marks ingredients, cookware and timers in
+// the steps themselves. Steps are separated by blank lines, and metadata
+// lines start with ">>":
+//
+//	>> title: Pancakes
+//	>> servings: 4
+//	>> time: 20 minutes
+//	>> tags: breakfast, sweet
+//
+//	Whisk @flour{2%cups}, @milk{1.5%cups} and @eggs{2} in a #large bowl{}.
+//
+//	Let the batter rest for ~{10%minutes}.
+//
+// Markdown recipes follow this convention: a "#" title, an optional
+// description, a list of metadata, then "Ingredients" and "Steps" sections.
+//
+//	# Pancakes
+//
+//	Fluffy weekend pancakes.
+//
+//	- Servings: 4
+//	- Time: 20 minutes
+//	- Tags: breakfast, sweet
+//
+//	## Ingredients
+//
+//	- 2 cups flour
+//	- 1 1/2 cups milk
+//	- 2 eggs
+//	- 1 [can] peaches
+//
+//	## Steps
+//
+//	1. Whisk the flour, milk and eggs in a large bowl.
+//	2. Let the batter rest for {10 minutes}.
+//
+// Ingredient lines start with an optional quantity and unit. Units that are
+// not recognized, such as "can", are written in brackets. Timers are written
+// in braces, optionally with a name: {rest: 10 minutes}. The "Instructions",
+// "Directions" and "Method" headings may be used instead of "Steps", and
+// other sections are ignored.
+//
+// In both formats the recognized metadata keys are title, description,
+// servings, time and tags. Other metadata, comments and formatting are not
+// stored in the recipe's fields, but the original text is kept so that an
+// unchanged recipe is exported exactly as it was written.
+package recipetext
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mjande/recipes-microservice/models"
+	"github.com/mjande/recipes-microservice/units"
+)
+
+// Format is a text format recipes can be written in.
+type Format string
+
+const (
+	Cooklang Format = "cooklang"
+	Markdown Format = "markdown"
+)
+
+var ErrUnknownFormat = errors.New("recipe text format must be cooklang or markdown")
+var ErrMissingTitle = errors.New("recipe text must have a title")
+var ErrUnclosedAmount = errors.New("an amount in braces is missing its closing brace")
+
+// Matches Cooklang block comments
+var blockComment = regexp.MustCompile(`(?s)\[-.*?-\]`)
+
+// Matches Markdown list items, capturing their text
+var listItem = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+(.*)$`)
+
+// Matches Markdown timers such as {10 minutes} or {rest: 10 minutes}
+var markdownTimer = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Matches the quantity at the start of an ingredient or timer, such as "2",
+// "1.5", "1/2" or "1 1/2"
+var leadingQuantity = regexp.MustCompile(`^(\d+(?:\.\d+)?(?:\s+\d+/\d+)?|\d+/\d+)(?:\s+|$)`)
+
+// Headings of the Markdown section holding the steps
+var stepHeadings = []string{"steps", "instructions", "directions", "method"}
+
+// Parses a recipe written in a format. The text is kept as the recipe's
+// source.
+func Parse(format Format, text string) (models.Recipe, error) {
+	var recipe models.Recipe
+	var err error
+
+	switch format {
+	case Cooklang:
+		recipe, err = ParseCooklang(text)
+	case Markdown:
+		recipe, err = ParseMarkdown(text)
+	default:
+		return models.Recipe{}, ErrUnknownFormat
+	}
+
+	if err != nil {
+		return models.Recipe{}, err
+	}
+
+	recipe.Source = text
+	recipe.SourceFormat = string(format)
+
+	return recipe, nil
+}
+
+// Writes a recipe in a format. A recipe that was written in the same format
+// and has not changed since is returned as it was written, including its
+// comments and formatting.
+func Export(recipe models.Recipe, format Format) (string, error) {
+	var write func(models.Recipe) string
+
+	switch format {
+	case Cooklang:
+		write = WriteCooklang
+	case Markdown:
+		write = WriteMarkdown
+	default:
+		return "", ErrUnknownFormat
+	}
+
+	if recipe.Source != "" && recipe.SourceFormat == string(format) {
+		original, err := Parse(format, recipe.Source)
+		if err == nil && write(exportedFields(original)) == write(exportedFields(recipe)) {
+			return recipe.Source, nil
+		}
+	}
+
+	return write(recipe), nil
+}
+
+// Parses a recipe written in Cooklang.
+func ParseCooklang(text string) (models.Recipe, error) {
+	recipe := newRecipe()
+	steps := []string{}
+	paragraph := []string{}
+
+	endStep := func() error {
+		if len(paragraph) == 0 {
+			return nil
+		}
+
+		step, err := parseCooklangStep(strings.Join(paragraph, " "), len(steps)+1, &recipe)
+		if err != nil {
+			return err
+		}
+
+		steps = append(steps, step)
+		paragraph = nil
+		return nil
+	}
+
+	text = blockComment.ReplaceAllString(text, "")
+	for _, line := range strings.Split(text, "\n") {
+		if comment := strings.Index(line, "--"); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, ">>") {
+			key, value, _ := strings.Cut(line[2:], ":")
+			setMetadata(&recipe, key, value)
+			continue
+		}
+
+		if line == "" {
+			err := endStep()
+			if err != nil {
+				return models.Recipe{}, err
+			}
+			continue
+		}
+
+		paragraph = append(paragraph, line)
+	}
+
+	err := endStep()
+	if err != nil {
+		return models.Recipe{}, err
+	}
+
+	if recipe.Name == "" {
+		return models.Recipe{}, ErrMissingTitle
+	}
+
+	recipe.Instructions = strings.Join(steps, "\n")
+
+	return recipe, nil
+}
+
+// Parses a recipe written in the Markdown convention described in the
+// package documentation.
+func ParseMarkdown(text string) (models.Recipe, error) {
+	recipe := newRecipe()
+	description := []string{}
+	paragraph := []string{}
+	steps := []string{}
+	section := ""
+	blank := true
+
+	endParagraph := func() {
+		if len(paragraph) > 0 {
+			description = append(description, strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		item := listItem.FindStringSubmatch(line)
+
+		switch {
+		case strings.HasPrefix(line, "# ") && recipe.Name == "" && section == "":
+			recipe.Name = strings.TrimSpace(line[2:])
+		case strings.HasPrefix(line, "## "):
+			endParagraph()
+			section = strings.ToLower(strings.TrimSpace(line[3:]))
+			if slices.Contains(stepHeadings, section) {
+				section = "steps"
+			}
+		case line == "":
+			endParagraph()
+		case section == "":
+			if item != nil {
+				key, value, ok := strings.Cut(item[1], ":")
+				if ok && setMetadata(&recipe, key, value) {
+					break
+				}
+			}
+
+			paragraph = append(paragraph, line)
+		case section == "ingredients":
+			if item != nil {
+				recipe.Ingredients = append(recipe.Ingredients, parseIngredientLine(item[1]))
+			}
+		case section == "steps":
+			// List items and paragraphs start new steps, other lines continue
+			// the current one
+			if item != nil {
+				steps = append(steps, item[1])
+			} else if blank || len(steps) == 0 {
+				steps = append(steps, line)
+			} else {
+				steps[len(steps)-1] += " " + line
+			}
+		}
+
+		blank = line == ""
+	}
+	endParagraph()
+
+	if recipe.Name == "" {
+		return models.Recipe{}, ErrMissingTitle
+	}
+
+	for i, step := range steps {
+		steps[i] = markdownTimer.ReplaceAllStringFunc(step, func(match string) string {
+			name, amount, ok := strings.Cut(match[1:len(match)-1], ":")
+			if !ok {
+				name, amount = "", name
+			}
+
+			timer := parseTimer(strings.TrimSpace(name), amount, i+1)
+			recipe.Timers = append(recipe.Timers, timer)
+			return timerText(timer)
+		})
+	}
+
+	if recipe.Description == "" {
+		recipe.Description = strings.Join(description, "\n\n")
+	}
+	recipe.Instructions = strings.Join(steps, "\n")
+
+	return recipe, nil
+}
+
+// Writes a recipe in Cooklang. Ingredients and timers are marked where their
+// steps mention them. Ingredients that no step mentions are listed in a step
+// of their own at the start.
+func WriteCooklang(recipe models.Recipe) string {
+	var out strings.Builder
+
+	out.WriteString(">> title: " + oneLine(recipe.Name) + "\n")
+	if recipe.Description != "" {
+		out.WriteString(">> description: " + oneLine(recipe.Description) + "\n")
+	}
+	if recipe.Servings > 0 {
+		out.WriteString(">> servings: " + strconv.Itoa(recipe.Servings) + "\n")
+	}
+	if recipe.CookingTime != "" {
+		out.WriteString(">> time: " + oneLine(recipe.CookingTime) + "\n")
+	}
+	if len(recipe.Tags) > 0 {
+		out.WriteString(">> tags: " + strings.Join(recipe.Tags, ", ") + "\n")
+	}
+
+	steps := markSteps(recipe,
+		func(ingredient models.Ingredient) string {
+			return "@" + ingredient.Name + "{" + cooklangAmount(ingredient.Quantity, ingredient.Unit) + "}"
+		},
+		func(timer models.Timer) string {
+			return "~" + timer.Name + "{" + cooklangAmount(timer.Quantity, timer.Unit) + "}"
+		},
+	)
+
+	for _, step := range steps {
+		out.WriteString("\n" + step + "\n")
+	}
+
+	return out.String()
+}
+
+// Writes a recipe in the Markdown convention described in the package
+// documentation.
+func WriteMarkdown(recipe models.Recipe) string {
+	var out strings.Builder
+
+	out.WriteString("# " + oneLine(recipe.Name) + "\n")
+	if recipe.Description != "" {
+		out.WriteString("\n" + recipe.Description + "\n")
+	}
+
+	metadata := []string{}
+	if recipe.Servings > 0 {
+		metadata = append(metadata, "- Servings: "+strconv.Itoa(recipe.Servings))
+	}
+	if recipe.CookingTime != "" {
+		metadata = append(metadata, "- Time: "+oneLine(recipe.CookingTime))
+	}
+	if len(recipe.Tags) > 0 {
+		metadata = append(metadata, "- Tags: "+strings.Join(recipe.Tags, ", "))
+	}
+	if len(metadata) > 0 {
+		out.WriteString("\n" + strings.Join(metadata, "\n") + "\n")
+	}
+
+	out.WriteString("\n## Ingredients\n\n")
+	for _, ingredient := range recipe.Ingredients {
+		out.WriteString("- " + ingredientLine(ingredient) + "\n")
+	}
+
+	out.WriteString("\n## Steps\n\n")
+	steps := markSteps(models.Recipe{Instructions: recipe.Instructions, Timers: recipe.Timers}, nil,
+		func(timer models.Timer) string {
+			if timer.Name == "" {
+				return "{" + timerText(timer) + "}"
+			}
+
+			return "{" + timer.Name + ": " + timerText(timer) + "}"
+		},
+	)
+
+	for i, step := range steps {
+		out.WriteString(strconv.Itoa(i+1) + ". " + step + "\n")
+	}
+
+	return out.String()
+}
+
+// Helper Functions
+
+func newRecipe() models.Recipe {
+	return models.Recipe{
+		Ingredients: []models.Ingredient{},
+		Tags:        []string{},
+		Timers:      []models.Timer{},
+	}
+}
+
+// Sets a recipe's field from a metadata entry. Reports false when the key
+// is not recognized.
+func setMetadata(recipe *models.Recipe, key string, value string) bool {
+	value = strings.TrimSpace(value)
+
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "title", "name":
+		recipe.Name = value
+	case "description":
+		recipe.Description = value
+	case "servings", "serves":
+		digits := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+		if digits < 0 {
+			digits = len(value)
+		}
+
+		servings, err := strconv.Atoi(value[:digits])
+		if err != nil {
+			return false
+		}
+		recipe.Servings = servings
+	case "time", "cooking time", "cook time", "total time":
+		recipe.CookingTime = value
+	case "tags":
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				recipe.Tags = append(recipe.Tags, tag)
+			}
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+// Reads the ingredients, cookware and timers marked in a Cooklang step into
+// the recipe, and returns the step as plain text.
+func parseCooklangStep(step string, number int, recipe *models.Recipe) (string, error) {
+	var plain strings.Builder
+
+	for i := 0; i < len(step); {
+		symbol := step[i]
+		if symbol != '@' && symbol != '#' && symbol != '~' {
+			plain.WriteByte(symbol)
+			i++
+			continue
+		}
+
+		name, amount, braced, next, err := readComponent(step, i+1)
+		if err != nil {
+			return "", err
+		}
+
+		// A lone symbol, or a timer without an amount, is plain text
+		if (name == "" && !braced) || (symbol == '~' && !braced) {
+			plain.WriteByte(symbol)
+			i++
+			continue
+		}
+
+		switch symbol {
+		case '@':
+			quantity, unit := parseAmount(strings.ReplaceAll(amount, "%", " "))
+			recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{Name: name, Quantity: quantity, Unit: unit})
+			plain.WriteString(name)
+		case '#':
+			plain.WriteString(name)
+		case '~':
+			quantity, unit := parseAmount(strings.ReplaceAll(amount, "%", " "))
+			timer := models.Timer{Step: number, Name: name, Quantity: quantity, Unit: unit}
+			recipe.Timers = append(recipe.Timers, timer)
+			plain.WriteString(timerText(timer))
+		}
+
+		i = next
+	}
+
+	return strings.Join(strings.Fields(plain.String()), " "), nil
+}
+
+// Reads the name and the amount in braces of a Cooklang component starting
+// at start, just after its symbol. Names of more than one word must be
+// followed by braces. Returns the position after the component.
+func readComponent(step string, start int) (name string, amount string, braced bool, next int, err error) {
+	brace := strings.IndexByte(step[start:], '{')
+	if brace >= 0 && !strings.ContainsAny(step[start:start+brace], "@#~{}") {
+		end := strings.IndexByte(step[start+brace:], '}')
+		if end < 0 {
+			return "", "", false, 0, ErrUnclosedAmount
+		}
+
+		name = strings.TrimSpace(step[start : start+brace])
+		amount = step[start+brace+1 : start+brace+end]
+		return name, amount, true, start + brace + end + 1, nil
+	}
+
+	end := start
+	for end < len(step) {
+		r, size := utf8.DecodeRuneInString(step[end:])
+		if !isWordRune(r) && r != '_' && r != '-' {
+			break
+		}
+		end += size
+	}
+
+	return step[start:end], "", false, end, nil
+}
+
+// Parses an amount such as "1 1/2 cups" into a quantity and unit. When the
+// amount does not start with a quantity, all of it is the unit.
+func parseAmount(amount string) (float32, string) {
+	amount = strings.TrimSpace(strings.ReplaceAll(amount, "*", ""))
+
+	match := leadingQuantity.FindStringSubmatch(amount)
+	if match == nil {
+		return 0, amount
+	}
+
+	quantity, ok := parseQuantity(match[1])
+	if !ok {
+		return 0, amount
+	}
+
+	return quantity, strings.TrimSpace(amount[len(match[0]):])
+}
+
+// Parses a quantity such as "2", "1.5", "1/2" or "1 1/2".
+func parseQuantity(text string) (float32, bool) {
+	var total float64
+	for _, part := range strings.Fields(text) {
+		if numerator, denominator, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.ParseFloat(numerator, 64)
+			if err != nil {
+				return 0, false
+			}
+
+			d, err := strconv.ParseFloat(denominator, 64)
+			if err != nil || d == 0 {
+				return 0, false
+			}
+
+			total += n / d
+			continue
+		}
+
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+		total += value
+	}
+
+	return float32(total), true
+}
+
+func parseTimer(name string, amount string, step int) models.Timer {
+	quantity, unit := parseAmount(amount)
+	return models.Timer{Step: step, Name: name, Quantity: quantity, Unit: unit}
+}
+
+// Parses a Markdown ingredient line such as "2 cups flour" or "1 [can]
+// peaches". Words after the quantity are only read as the unit when they are
+// a recognized unit or in brackets.
+func parseIngredientLine(line string) models.Ingredient {
+	line = strings.TrimSpace(line)
+
+	match := leadingQuantity.FindStringSubmatch(line)
+	if match == nil {
+		return models.Ingredient{Name: line}
+	}
+
+	quantity, ok := parseQuantity(match[1])
+	if !ok {
+		return models.Ingredient{Name: line}
+	}
+
+	rest := line[len(match[0]):]
+	if strings.HasPrefix(rest, "[") {
+		if unit, name, ok := strings.Cut(rest[1:], "]"); ok {
+			return models.Ingredient{Name: strings.TrimSpace(name), Quantity: quantity, Unit: strings.TrimSpace(unit)}
+		}
+	}
+
+	// Try two-word units such as "fl oz" before single words
+	words := strings.Fields(rest)
+	for length := min(2, len(words)-1); length >= 1; length-- {
+		unit := strings.Join(words[:length], " ")
+		if _, ok := units.Parse(unit); ok {
+			return models.Ingredient{Name: strings.Join(words[length:], " "), Quantity: quantity, Unit: unit}
+		}
+	}
+
+	return models.Ingredient{Name: strings.Join(words, " "), Quantity: quantity}
+}
+
+// Writes an ingredient as a Markdown ingredient line.
+func ingredientLine(ingredient models.Ingredient) string {
+	parts := []string{}
+	if ingredient.Quantity > 0 {
+		parts = append(parts, formatNumber(ingredient.Quantity))
+	}
+
+	// Brackets keep units that would not be read back as units, and names
+	// that would, from being mistaken for each other
+	_, known := units.Parse(ingredient.Unit)
+	if ingredient.Unit != "" && known && ingredient.Quantity > 0 && len(strings.Fields(ingredient.Unit)) <= 2 {
+		parts = append(parts, ingredient.Unit)
+	} else if ingredient.Unit != "" || (ingredient.Quantity > 0 && parseIngredientLine("1 "+ingredient.Name).Unit != "") {
+		if ingredient.Quantity <= 0 {
+			parts = append(parts, "0")
+		}
+		parts = append(parts, "["+ingredient.Unit+"]")
+	}
+
+	parts = append(parts, ingredient.Name)
+
+	return strings.Join(parts, " ")
+}
+
+// Writes a Cooklang amount, such as "2%cups".
+func cooklangAmount(quantity float32, unit string) string {
+	if quantity <= 0 {
+		return unit
+	}
+
+	if unit == "" {
+		return formatNumber(quantity)
+	}
+
+	return formatNumber(quantity) + "%" + unit
+}
+
+// Returns how a timer reads in the plain text of a step, such as
+// "10 minutes".
+func timerText(timer models.Timer) string {
+	if timer.Quantity <= 0 {
+		return timer.Unit
+	}
+
+	return strings.TrimSpace(formatNumber(timer.Quantity) + " " + timer.Unit)
+}
+
+func formatNumber(number float32) string {
+	return strconv.FormatFloat(float64(number), 'f', -1, 32)
+}
+
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// A part of a step to replace with its markup.
+type mark struct {
+	step   int
+	start  int
+	end    int
+	markup string
+}
+
+// Splits a recipe's instructions into steps, replacing the first mention of
+// each ingredient and timer with its markup. Ingredients are looked for in
+// order, from the last one found; timers only in their own step. Ingredients
+// that are not mentioned are put in a step of their own at the start, and
+// timers at the end of their step. A nil markup function leaves those out.
+func markSteps(recipe models.Recipe, ingredientMarkup func(models.Ingredient) string, timerMarkup func(models.Timer) string) []string {
+	steps := []string{}
+	for _, line := range strings.Split(recipe.Instructions, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			steps = append(steps, line)
+		}
+	}
+
+	marks := []mark{}
+	unmentioned := []string{}
+	trailing := map[int][]string{}
+
+	overlaps := func(step int, start int, end int) bool {
+		return slices.ContainsFunc(marks, func(m mark) bool {
+			return m.step == step && start < m.end && m.start < end
+		})
+	}
+
+	// Finds the first free mention of text in a step at or after offset
+	find := func(step int, offset int, text string) (int, bool) {
+		lower, target := strings.ToLower(steps[step]), strings.ToLower(text)
+		for offset <= len(lower) {
+			index := strings.Index(lower[offset:], target)
+			if index < 0 {
+				return 0, false
+			}
+
+			start := offset + index
+			end := start + len(target)
+			if !overlaps(step, start, end) && isWordBoundary(lower, start) && isWordBoundary(lower, end) {
+				return start, true
+			}
+			offset = start + 1
+		}
+
+		return 0, false
+	}
+
+	if ingredientMarkup != nil {
+		cursorStep, cursorOffset := 0, 0
+		for _, ingredient := range recipe.Ingredients {
+			markup := ingredientMarkup(ingredient)
+			found := false
+
+			// Look from the last mention onwards, then from the start
+			for _, from := range [][2]int{{cursorStep, cursorOffset}, {0, 0}} {
+				for step := from[0]; step < len(steps) && !found && ingredient.Name != ""; step++ {
+					offset := 0
+					if step == from[0] {
+						offset = from[1]
+					}
+
+					start, ok := find(step, offset, ingredient.Name)
+					if ok {
+						end := start + len(ingredient.Name)
+						marks = append(marks, mark{step, start, end, markup})
+						cursorStep, cursorOffset = step, end
+						found = true
+					}
+				}
+			}
+
+			if !found {
+				unmentioned = append(unmentioned, markup)
+			}
+		}
+	}
+
+	for _, timer := range recipe.Timers {
+		markup := timerMarkup(timer)
+		step := timer.Step - 1
+		if step >= len(steps) {
+			step = len(steps) - 1
+		}
+
+		if step < 0 {
+			steps = append(steps, "")
+			step = 0
+		}
+
+		start, ok := find(step, 0, timerText(timer))
+		if ok && timerText(timer) != "" {
+			marks = append(marks, mark{step, start, start + len(timerText(timer)), markup})
+		} else {
+			trailing[step] = append(trailing[step], markup)
+		}
+	}
+
+	marked := make([]string, len(steps))
+	for i, step := range steps {
+		stepMarks := []mark{}
+		for _, m := range marks {
+			if m.step == i {
+				stepMarks = append(stepMarks, m)
+			}
+		}
+		slices.SortFunc(stepMarks, func(a, b mark) int { return a.start - b.start })
+
+		var out strings.Builder
+		position := 0
+		for _, m := range stepMarks {
+			out.WriteString(step[position:m.start])
+			out.WriteString(m.markup)
+			position = m.end
+		}
+		out.WriteString(step[position:])
+
+		marked[i] = strings.Join(append([]string{out.String()}, trailing[i]...), " ")
+		marked[i] = strings.TrimSpace(marked[i])
+	}
+
+	if len(unmentioned) > 0 {
+		marked = append([]string{strings.Join(unmentioned, ", ")}, marked...)
+	}
+
+	return marked
+}
+
+// Reports whether a position in text is not inside a word.
+func isWordBoundary(text string, position int) bool {
+	if position == 0 || position == len(text) {
+		return true
+	}
+
+	before, after := []rune(text[:position]), []rune(text[position:])
+	return !isWordRune(before[len(before)-1]) || !isWordRune(after[0])
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Returns a copy of a recipe with only the fields the text formats hold, in
+// the form they are stored, for comparing two versions of a recipe.
+func exportedFields(recipe models.Recipe) models.Recipe {
+	tags := []string{}
+	for _, tag := range recipe.Tags {
+		if tag = models.NormalizeTag(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+
+	ingredients := make([]models.Ingredient, len(recipe.Ingredients))
+	for i, ingredient := range recipe.Ingredients {
+		ingredients[i] = models.Ingredient{Name: ingredient.Name, Quantity: ingredient.Quantity, Unit: ingredient.Unit}
+	}
+
+	return models.Recipe{
+		Name:         recipe.Name,
+		CookingTime:  recipe.CookingTime,
+		Servings:     recipe.Servings,
+		Description:  recipe.Description,
+		Instructions: recipe.Instructions,
+		Ingredients:  ingredients,
+		Tags:         tags,
+		Timers:       recipe.Timers,
+	}
+}
@@ -0,0 +1,222 @@
+package recipetext
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mjande/recipes-microservice/models"
+)
+
+const cooklangPancakes = `>> title: Pancakes
+>> servings: 4
+>> time: 20 minutes
+>> tags: breakfast, sweet
+
+-- Best with buttermilk
+Whisk @flour{2%cups}, @milk{1.5%cups} and @eggs{2} in a #large bowl{}.
+
+Let the batter rest for ~{10%minutes}. [- or overnight -]
+`
+
+const markdownPancakes = `# Pancakes
+
+Fluffy weekend pancakes.
+
+- Servings: 4
+- Time: 20 minutes
+- Tags: breakfast, sweet
+
+## Ingredients
+
+- 2 cups flour
+- 1 1/2 cups milk
+- 2 eggs
+- 1 [can] peaches
+
+## Steps
+
+1. Whisk the flour, milk and eggs in a large bowl.
+2. Let the batter rest for {10 minutes}.
+`
+
+func TestParseCooklang(t *testing.T) {
+	recipe, err := ParseCooklang(cooklangPancakes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.Recipe{
+		Name:         "Pancakes",
+		CookingTime:  "20 minutes",
+		Servings:     4,
+		Instructions: "Whisk flour, milk and eggs in a large bowl.\nLet the batter rest for 10 minutes.",
+		Ingredients: []models.Ingredient{
+			{Name: "flour", Quantity: 2, Unit: "cups"},
+			{Name: "milk", Quantity: 1.5, Unit: "cups"},
+			{Name: "eggs", Quantity: 2},
+		},
+		Tags:   []string{"breakfast", "sweet"},
+		Timers: []models.Timer{{Step: 2, Quantity: 10, Unit: "minutes"}},
+	}
+
+	if got := exportedFields(recipe); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	recipe, err := ParseMarkdown(markdownPancakes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.Recipe{
+		Name:         "Pancakes",
+		CookingTime:  "20 minutes",
+		Servings:     4,
+		Description:  "Fluffy weekend pancakes.",
+		Instructions: "Whisk the flour, milk and eggs in a large bowl.\nLet the batter rest for 10 minutes.",
+		Ingredients: []models.Ingredient{
+			{Name: "flour", Quantity: 2, Unit: "cups"},
+			{Name: "milk", Quantity: 1.5, Unit: "cups"},
+			{Name: "eggs", Quantity: 2},
+			{Name: "peaches", Quantity: 1, Unit: "can"},
+		},
+		Tags:   []string{"breakfast", "sweet"},
+		Timers: []models.Timer{{Step: 2, Quantity: 10, Unit: "minutes"}},
+	}
+
+	if got := exportedFields(recipe); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		text   string
+		err    error
+	}{
+		{"unknown format", Format("yaml"), "title: Pancakes", ErrUnknownFormat},
+		{"cooklang without title", Cooklang, "Whisk @flour{2%cups}.", ErrMissingTitle},
+		{"markdown without title", Markdown, "## Steps\n\n1. Whisk.", ErrMissingTitle},
+		{"unclosed amount", Cooklang, ">> title: Pancakes\n\nWhisk @flour{2%cups.", ErrUnclosedAmount},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Parse(test.format, test.text); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  Format
+		text  string
+		to    Format
+		write func(models.Recipe) string
+	}{
+		{"cooklang", Cooklang, cooklangPancakes, Cooklang, WriteCooklang},
+		{"markdown", Markdown, markdownPancakes, Markdown, WriteMarkdown},
+		{"cooklang to markdown", Cooklang, cooklangPancakes, Markdown, WriteMarkdown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original, err := Parse(test.from, test.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			written := test.write(original)
+			parsed, err := Parse(test.to, written)
+			if err != nil {
+				t.Fatalf("%v parsing\n%s", err, written)
+			}
+
+			if !reflect.DeepEqual(exportedFields(parsed), exportedFields(original)) {
+				t.Errorf("got %+v\nwant %+v\nfrom\n%s", exportedFields(parsed), exportedFields(original), written)
+			}
+
+			// Writing again gives the same text
+			if again := test.write(parsed); again != written {
+				t.Errorf("got\n%s\nwant\n%s", again, written)
+			}
+		})
+	}
+}
+
+func TestWriteCooklangListsUnmentionedIngredients(t *testing.T) {
+	recipe, err := ParseMarkdown(markdownPancakes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := WriteCooklang(recipe)
+	if !strings.Contains(written, "\n@peaches{1%can}\n\nWhisk the @flour{2%cups}, @milk{1.5%cups} and @eggs{2}") {
+		t.Errorf("unmentioned ingredient not listed first in\n%s", written)
+	}
+}
+
+func TestExport(t *testing.T) {
+	recipe, err := Parse(Cooklang, cooklangPancakes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An unchanged recipe keeps its comments and formatting
+	exported, err := Export(recipe, Cooklang)
+	if err != nil || exported != cooklangPancakes {
+		t.Errorf("got %q %v, want the original text", exported, err)
+	}
+
+	// Another format is written from the fields
+	exported, err = Export(recipe, Markdown)
+	if err != nil || exported != WriteMarkdown(recipe) {
+		t.Errorf("got %q %v, want written Markdown", exported, err)
+	}
+
+	// A changed recipe is written from its fields
+	recipe.Servings = 8
+	exported, err = Export(recipe, Cooklang)
+	if err != nil || exported != WriteCooklang(recipe) {
+		t.Errorf("got %q %v, want written Cooklang", exported, err)
+	}
+	if !strings.Contains(exported, ">> servings: 8\n") {
+		t.Errorf("changed servings missing from\n%s", exported)
+	}
+
+	if _, err := Export(recipe, Format("yaml")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("got %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestParseIngredientLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want models.Ingredient
+	}{
+		{"2 cups flour", models.Ingredient{Name: "flour", Quantity: 2, Unit: "cups"}},
+		{"1 1/2 tbsp sugar", models.Ingredient{Name: "sugar", Quantity: 1.5, Unit: "tbsp"}},
+		{"1/2 lemon", models.Ingredient{Name: "lemon", Quantity: 0.5}},
+		{"1 [can] peaches", models.Ingredient{Name: "peaches", Quantity: 1, Unit: "can"}},
+		{"salt", models.Ingredient{Name: "salt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			got := parseIngredientLine(test.line)
+			got = models.Ingredient{Name: got.Name, Quantity: got.Quantity, Unit: got.Unit}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Local stores files in a directory on the local filesystem. The files are
+// served by mounting Handler at the path of BaseURL.
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+// Creates a local store, making its directory if needed.
+func NewLocal(dir string, baseURL string) (*Local, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Local{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Local) URL(key string) string {
+	return l.BaseURL + "/" + key
+}
+
+// Returns the URL path files are served under.
+func (l *Local) Path() string {
+	parsed, err := url.Parse(l.BaseURL)
+	if err != nil {
+		return l.BaseURL
+	}
+
+	return strings.TrimSuffix(parsed.Path, "/")
+}
+
+// Serves the stored files. Directory listings are not served.
+func (l *Local) Handler() http.Handler {
+	files := http.StripPrefix(l.Path(), http.FileServer(http.Dir(l.Dir)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
+
+// Resolves a key to a path inside the store's directory, rejecting keys that
+// would escape it.
+func (l *Local) path(key string) (string, error) {
+	if key == "" || !fs.ValidPath(key) {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
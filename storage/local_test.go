@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalPath(t *testing.T) {
+	local := &Local{Dir: "/var/photos"}
+
+	tests := []struct {
+		key  string
+		want string
+		err  error
+	}{
+		{"photo.jpg", "/var/photos/photo.jpg", nil},
+		{"recipes/1/abc/small.jpg", "/var/photos/recipes/1/abc/small.jpg", nil},
+		{"", "", ErrInvalidKey},
+		{"../secret", "", ErrInvalidKey},
+		{"recipes/../../secret", "", ErrInvalidKey},
+		{"/etc/passwd", "", ErrInvalidKey},
+		{"recipes//photo.jpg", "", ErrInvalidKey},
+		{"recipes/./photo.jpg", "", ErrInvalidKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			got, err := local.path(test.key)
+			if got != filepath.FromSlash(test.want) || !errors.Is(err, test.err) {
+				t.Errorf("got %q %v, want %q %v", got, err, test.want, test.err)
+			}
+		})
+	}
+}
+
+func TestLocalPutAndDelete(t *testing.T) {
+	local, err := NewLocal(t.TempDir(), "http://localhost/photos/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	key := "recipes/1/photo.jpg"
+
+	err = local.Put(ctx, key, strings.NewReader("data"), 4, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(local.Dir, "recipes", "1", "photo.jpg"))
+	if err != nil || string(contents) != "data" {
+		t.Fatalf("got %q %v, want the stored data", contents, err)
+	}
+
+	if url := local.URL(key); url != "http://localhost/photos/recipes/1/photo.jpg" {
+		t.Errorf("got URL %q", url)
+	}
+
+	if err = local.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(filepath.Join(local.Dir, "recipes", "1", "photo.jpg")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file still exists after delete: %v", err)
+	}
+
+	// Deleting a missing file is not an error
+	if err = local.Delete(ctx, key); err != nil {
+		t.Errorf("deleting twice: %v", err)
+	}
+
+	if err = local.Put(ctx, "../escape", strings.NewReader("data"), 4, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("got %v, want %v", err, ErrInvalidKey)
+	}
+}
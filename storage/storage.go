@@ -0,0 +1,68 @@
+// Package storage stores uploaded files such as recipe photos. Files are
+// kept on the local filesystem by default, or in an S3-compatible bucket
+// when STORAGE_DRIVER is set to "s3".
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// Blobs is a store of files addressed by key. Keys are slash-separated paths
+// such as "recipes/1/photo.jpg".
+type Blobs interface {
+	// Stores a file under the given key, replacing any existing file.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Removes the file stored under the given key. Removing a missing file
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+	// Returns the URL clients can download the file from.
+	URL(key string) string
+}
+
+// The store used by the service, set up by InitStorage.
+var Store Blobs
+
+// Sets up Store from the environment. STORAGE_DRIVER selects the
+// implementation and defaults to "local".
+func InitStorage() error {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "", "local":
+		local, err := NewLocal(envOrDefault("STORAGE_DIR", "uploads"), envOrDefault("STORAGE_BASE_URL", "/media"))
+		if err != nil {
+			return err
+		}
+
+		Store = local
+	case "s3":
+		s3, err := NewS3(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          envOrDefault("S3_REGION", "us-east-1"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		})
+		if err != nil {
+			return err
+		}
+
+		Store = s3
+	default:
+		return errors.New("unknown STORAGE_DRIVER " + os.Getenv("STORAGE_DRIVER"))
+	}
+
+	return nil
+}
+
+// Helper Functions
+
+func envOrDefault(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return fallback
+}
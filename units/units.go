@@ -0,0 +1,177 @@
+// Package units parses the free-form units used in recipes and converts
+// quantities between units of the same kind.
+package units
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mjande/recipes-microservice/normalizer"
+)
+
+// Kind is the dimension a unit measures.
+type Kind string
+
+const (
+	Mass   Kind = "mass"
+	Volume Kind = "volume"
+	Count  Kind = "count"
+)
+
+var ErrIncompatibleUnits = errors.New("units measure different kinds of quantity")
+
+// Unit is a recognized unit. Factor converts one of the unit into the base
+// unit of its kind: grams for mass, milliliters for volume and single items
+// for counts.
+type Unit struct {
+	Name   string
+	Kind   Kind
+	Factor float64
+}
+
+var knownUnits = map[string]Unit{
+	// Mass, in grams
+	"mg":       {"mg", Mass, 0.001},
+	"g":        {"g", Mass, 1},
+	"gr":       {"g", Mass, 1},
+	"gram":     {"g", Mass, 1},
+	"kg":       {"kg", Mass, 1000},
+	"kilogram": {"kg", Mass, 1000},
+	"oz":       {"oz", Mass, 28.3495},
+	"ounce":    {"oz", Mass, 28.3495},
+	"lb":       {"lb", Mass, 453.592},
+	"lbs":      {"lb", Mass, 453.592},
+	"pound":    {"lb", Mass, 453.592},
+
+	// Volume, in milliliters
+	"ml":          {"ml", Volume, 1},
+	"milliliter":  {"ml", Volume, 1},
+	"millilitre":  {"ml", Volume, 1},
+	"l":           {"l", Volume, 1000},
+	"liter":       {"l", Volume, 1000},
+	"litre":       {"l", Volume, 1000},
+	"tsp":         {"tsp", Volume, 4.92892},
+	"teaspoon":    {"tsp", Volume, 4.92892},
+	"tbsp":        {"tbsp", Volume, 14.7868},
+	"tablespoon":  {"tbsp", Volume, 14.7868},
+	"cup":         {"cup", Volume, 236.588},
+	"fl oz":       {"fl oz", Volume, 29.5735},
+	"fluid ounce": {"fl oz", Volume, 29.5735},
+	"pint":        {"pint", Volume, 473.176},
+	"quart":       {"quart", Volume, 946.353},
+	"gallon":      {"gallon", Volume, 3785.41},
+	"pinch":       {"pinch", Volume, 0.31},
+	"dash":        {"dash", Volume, 0.62},
+
+	// Counts of whole items
+	"":      {"", Count, 1},
+	"pc":    {"piece", Count, 1},
+	"pcs":   {"piece", Count, 1},
+	"piece": {"piece", Count, 1},
+	"each":  {"piece", Count, 1},
+	"ea":    {"piece", Count, 1},
+	"whole": {"piece", Count, 1},
+	"clove": {"piece", Count, 1},
+	"leaf":  {"piece", Count, 1},
+	"slice": {"piece", Count, 1},
+	"stalk": {"piece", Count, 1},
+	"sprig": {"piece", Count, 1},
+}
+
+// Parses a unit as written in a recipe, such as "Cups", "tbsp." or
+// "grams". Returns false if the unit is not recognized.
+func Parse(unit string) (Unit, bool) {
+	name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(unit)), ".")
+
+	if parsed, ok := knownUnits[name]; ok {
+		return parsed, true
+	}
+
+	// Accept plurals such as "cups", "leaves" and "fluid ounces"
+	words := strings.Fields(name)
+	if len(words) > 0 {
+		words[len(words)-1] = normalizer.Singular(words[len(words)-1])
+		parsed, ok := knownUnits[strings.Join(words, " ")]
+		return parsed, ok
+	}
+
+	return Unit{}, false
+}
+
+// Converts a quantity between two units of the same kind.
+func Convert(quantity float64, from Unit, to Unit) (float64, error) {
+	if from.Kind != to.Kind {
+		return 0, ErrIncompatibleUnits
+	}
+
+	return quantity * from.Factor / to.Factor, nil
+}
+
+// Converts a quantity into the base unit of its kind.
+func ToBase(quantity float64, unit Unit) float64 {
+	return quantity * unit.Factor
+}
+
+// System is a system of measurement quantities can be shown in.
+type System string
+
+const (
+	Metric System = "metric"
+	US     System = "us"
+)
+
+var ErrUnknownSystem = errors.New("unit system must be metric or us")
+
+// Parses the name of a unit system. An empty name is not a system.
+func ParseSystem(name string) (System, error) {
+	switch System(strings.ToLower(name)) {
+	case Metric:
+		return Metric, nil
+	case US:
+		return US, nil
+	}
+
+	return "", ErrUnknownSystem
+}
+
+// Converts a mass or volume into the most readable unit of a system, such as
+// grams or kilograms for metric masses and teaspoons, tablespoons or cups
+// for US volumes. Counts, and pinches and dashes, are returned unchanged.
+func ToSystem(quantity float64, unit Unit, system System) (float64, Unit) {
+	if unit.Kind == Count || unit.Name == "pinch" || unit.Name == "dash" {
+		return quantity, unit
+	}
+
+	base := ToBase(quantity, unit)
+
+	var target Unit
+	switch {
+	case system == Metric && unit.Kind == Mass:
+		target = knownUnits["g"]
+		if base >= 1000 {
+			target = knownUnits["kg"]
+		}
+	case system == Metric && unit.Kind == Volume:
+		target = knownUnits["ml"]
+		if base >= 1000 {
+			target = knownUnits["l"]
+		}
+	case system == US && unit.Kind == Mass:
+		target = knownUnits["oz"]
+		if base >= knownUnits["lb"].Factor {
+			target = knownUnits["lb"]
+		}
+	case system == US && unit.Kind == Volume:
+		target = knownUnits["tsp"]
+		if base >= knownUnits["tbsp"].Factor {
+			target = knownUnits["tbsp"]
+		}
+		if base >= knownUnits["cup"].Factor/4 {
+			target = knownUnits["cup"]
+		}
+	default:
+		return quantity, unit
+	}
+
+	return base / target.Factor, target
+}
@@ -0,0 +1,148 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func closeTo(a float64, b float64) bool {
+	return math.Abs(a-b) < 0.001
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		unit string
+		name string
+		kind Kind
+		ok   bool
+	}{
+		{"Cups", "cup", Volume, true},
+		{"tbsp.", "tbsp", Volume, true},
+		{" grams ", "g", Mass, true},
+		{"KG", "kg", Mass, true},
+		{"lbs", "lb", Mass, true},
+		{"fluid ounces", "fl oz", Volume, true},
+		{"leaves", "piece", Count, true},
+		{"cloves", "piece", Count, true},
+		{"", "", Count, true},
+		{"can", "", "", false},
+		{"handful", "", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.unit, func(t *testing.T) {
+			unit, ok := Parse(test.unit)
+			if ok != test.ok || unit.Name != test.name || unit.Kind != test.kind {
+				t.Errorf("got %+v %v, want %s %s %v", unit, ok, test.name, test.kind, test.ok)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		quantity float64
+		from     string
+		to       string
+		want     float64
+	}{
+		{1, "cup", "ml", 236.588},
+		{2, "l", "ml", 2000},
+		{1, "tbsp", "tsp", 3},
+		{16, "tbsp", "cup", 1},
+		{1, "kg", "g", 1000},
+		{1, "lb", "oz", 16},
+		{500, "g", "lb", 1.102},
+		{250, "mg", "g", 0.25},
+		{3, "clove", "piece", 3},
+		{0, "cup", "ml", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.from+" to "+test.to, func(t *testing.T) {
+			from, _ := Parse(test.from)
+			to, _ := Parse(test.to)
+
+			got, err := Convert(test.quantity, from, to)
+			if err != nil || !closeTo(got, test.want) {
+				t.Errorf("got %v %v, want %v", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertIncompatible(t *testing.T) {
+	for _, pair := range [][2]string{{"cup", "g"}, {"kg", "ml"}, {"piece", "g"}, {"", "tsp"}} {
+		from, _ := Parse(pair[0])
+		to, _ := Parse(pair[1])
+
+		if _, err := Convert(1, from, to); !errors.Is(err, ErrIncompatibleUnits) {
+			t.Errorf("converting %q to %q: got %v, want %v", pair[0], pair[1], err, ErrIncompatibleUnits)
+		}
+	}
+}
+
+func TestToBase(t *testing.T) {
+	cup, _ := Parse("cup")
+	if got := ToBase(2, cup); !closeTo(got, 473.176) {
+		t.Errorf("got %v, want 473.176", got)
+	}
+}
+
+func TestParseSystem(t *testing.T) {
+	tests := []struct {
+		name   string
+		system System
+		err    error
+	}{
+		{"metric", Metric, nil},
+		{"US", US, nil},
+		{"", "", ErrUnknownSystem},
+		{"imperial", "", ErrUnknownSystem},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			system, err := ParseSystem(test.name)
+			if system != test.system || !errors.Is(err, test.err) {
+				t.Errorf("got %q %v, want %q %v", system, err, test.system, test.err)
+			}
+		})
+	}
+}
+
+func TestToSystem(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity float64
+		unit     string
+		system   System
+		want     float64
+		wantUnit string
+	}{
+		{"small metric mass", 8, "oz", Metric, 226.796, "g"},
+		{"large metric mass", 3, "lb", Metric, 1.361, "kg"},
+		{"small metric volume", 1, "cup", Metric, 236.588, "ml"},
+		{"large metric volume", 6, "cup", Metric, 1.420, "l"},
+		{"small us mass", 100, "g", US, 3.527, "oz"},
+		{"large us mass", 1, "kg", US, 2.205, "lb"},
+		{"teaspoons", 2, "ml", US, 0.406, "tsp"},
+		{"tablespoons", 30, "ml", US, 2.029, "tbsp"},
+		{"cups", 500, "ml", US, 2.113, "cup"},
+		{"counts are unchanged", 3, "clove", Metric, 3, "piece"},
+		{"pinches are unchanged", 2, "pinch", Metric, 2, "pinch"},
+		{"same system", 250, "g", Metric, 250, "g"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unit, _ := Parse(test.unit)
+
+			got, gotUnit := ToSystem(test.quantity, unit, test.system)
+			if !closeTo(got, test.want) || gotUnit.Name != test.wantUnit {
+				t.Errorf("got %v %s, want %v %s", got, gotUnit.Name, test.want, test.wantUnit)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+type contextKey string
+
+const householdIDKey contextKey = "householdId"
+
+// Middleware that reads the X-Household-ID header and stores it in the
+// request context, so that models can scope queries to a household.
+func HouseholdScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		householdId := r.Header.Get("X-Household-ID")
+		if householdId == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, err := strconv.ParseInt(householdId, 10, 64)
+		if err != nil {
+			SendErrorResponse(w, http.StatusBadRequest, "X-Household-ID must be a numeric household id")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), householdIDKey, householdId)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Returns the household the request is scoped to, taken from the
+// X-Household-ID header or else the household_id claim of the JWT. Returns an
+// empty string when the request is scoped to the user's personal cookbook.
+func ExtractHouseholdIDFromContext(ctx context.Context) string {
+	if householdId, ok := ctx.Value(householdIDKey).(string); ok {
+		return householdId
+	}
+
+	_, claims, _ := jwtauth.FromContext(ctx)
+	if householdId, ok := claims["household_id"].(float64); ok {
+		return strconv.Itoa(int(householdId))
+	}
+
+	return ""
+}
@@ -12,3 +12,11 @@ func ExtractUserIDFromContext(ctx context.Context) string {
 	userId := claims["user_id"].(float64)
 	return strconv.Itoa(int(userId))
 }
+
+// Reports whether the JWT has the admin claim, which lets a user manage
+// settings that apply to every cookbook.
+func IsAdminFromContext(ctx context.Context) bool {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	admin, _ := claims["admin"].(bool)
+	return admin
+}
@@ -0,0 +1,383 @@
+// Package webhooks delivers recipe events to the HTTP endpoints other
+// services register. Events wait in an outbox until a Dispatcher hands them
+// to a Store as deliveries, sends each delivery as a signed POST request and
+// retries failed ones with exponential backoff, giving up on a delivery
+// after MaxAttempts.
+//
+// Requests carry the event as a JSON body and these headers:
+//
+//	X-Webhook-Event      the event type, such as recipe.updated
+//	X-Webhook-Delivery   the delivery ID, the same on every attempt
+//	X-Webhook-Timestamp  when the request was sent, in Unix seconds
+//	X-Webhook-Signature  sha256= and the hex HMAC-SHA256 of the timestamp,
+//	                     a period and the body, keyed with the webhook secret
+//
+// Receivers should check the signature and reject old timestamps. Any 2xx
+// response acknowledges the delivery, and redirects are not followed.
+//
+// Webhooks may not point at loopback, private, link-local, multicast or
+// unspecified addresses, so that registering one cannot make the service
+// reach internal endpoints. URLs are checked when webhooks are registered
+// with CheckHost, and again when connecting by the client from NewClient,
+// which catches host names that resolve differently later.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Event types
+const (
+	RecipeCreated = "recipe.created"
+	RecipeUpdated = "recipe.updated"
+	RecipeDeleted = "recipe.deleted"
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// Defaults for a Dispatcher
+const (
+	DefaultInterval    = 5 * time.Second
+	DefaultBatchSize   = 50
+	DefaultMaxAttempts = 10
+	DefaultTimeout     = 10 * time.Second
+)
+
+// Wait before the first retry, doubled for every retry after it up to
+// maxBackoff.
+const (
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Most of a response body read so that the connection can be reused.
+const maxDrainSize = 64 << 10
+
+var ErrForbiddenAddress = errors.New("webhooks cannot be sent to loopback, private, link-local or multicast addresses")
+
+// Address ranges webhooks cannot be sent to, besides those netip reports
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // IPv4 translation
+}
+
+// An event being sent to a webhook.
+type Delivery struct {
+	ID       int64
+	URL      string
+	Secret   string
+	Event    string
+	Body     []byte
+	Attempts int
+}
+
+// The outcome of sending a delivery. StatusCode is 0 when no response was
+// received.
+type Result struct {
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+func (r Result) Succeeded() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Store keeps the outbox and the deliveries.
+type Store interface {
+	// Turns events waiting in the outbox into deliveries to the webhooks
+	// subscribed to them.
+	QueueDeliveries(ctx context.Context) error
+
+	// Returns up to limit pending deliveries that are due, holding them so
+	// that other dispatchers do not send them at the same time.
+	ClaimDeliveries(ctx context.Context, limit int) ([]Delivery, error)
+
+	// Logs an attempt at a delivery and sets its status. Pending deliveries
+	// are tried again at retryAt.
+	RecordAttempt(ctx context.Context, delivery Delivery, result Result, status string, retryAt time.Time) error
+}
+
+// Dispatcher sends the deliveries of a Store. Zero fields use the defaults,
+// and a nil Client is one from NewClient, created on first use and reused
+// afterwards so that connections to receivers are kept alive.
+type Dispatcher struct {
+	Store       Store
+	Client      *http.Client
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+
+	defaultClient     *http.Client
+	defaultClientOnce sync.Once
+}
+
+// Dispatches deliveries every Interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := d.Dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Println(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Queues the events in the outbox and sends the deliveries that are due.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	err := d.Store.QueueDeliveries(ctx)
+	if err != nil {
+		return err
+	}
+
+	batchSize := d.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	maxAttempts := d.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	client := d.client()
+
+	for {
+		deliveries, err := d.Store.ClaimDeliveries(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			result := Send(ctx, client, delivery, time.Now())
+
+			// Leave the delivery claimed to be retried once its hold ends,
+			// rather than counting an attempt cut short by shutting down
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			status := StatusPending
+			retryAt := time.Now().Add(Backoff(delivery.Attempts + 1))
+			if result.Succeeded() {
+				status = StatusSucceeded
+			} else if delivery.Attempts+1 >= maxAttempts {
+				status = StatusDead
+			}
+
+			err = d.Store.RecordAttempt(ctx, delivery, result, status, retryAt)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) < batchSize {
+			return nil
+		}
+	}
+}
+
+// Sends a delivery, signed with its webhook's secret as of now.
+func Send(ctx context.Context, client *http.Client, delivery Delivery, now time.Time) Result {
+	start := time.Now()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return Result{Error: err.Error()}
+	}
+
+	timestamp := now.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "recipes-microservice-webhooks")
+	request.Header.Set("X-Webhook-Event", delivery.Event)
+	request.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Webhook-Signature", Sign(delivery.Secret, timestamp, delivery.Body))
+
+	// Errors and responses are only described in general terms, since the
+	// delivery log is shown to whoever registered the webhook
+	response, err := client.Do(request)
+	if err != nil {
+		return Result{Error: describeError(err), Duration: time.Since(start)}
+	}
+	defer response.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxDrainSize))
+
+	result := Result{StatusCode: response.StatusCode, Duration: time.Since(start)}
+	if !result.Succeeded() {
+		result.Error = "receiver did not respond with a 2xx status"
+	}
+
+	return result
+}
+
+// Creates a client for sending deliveries. It does not follow redirects or
+// use proxies, and refuses to connect to addresses webhooks cannot be sent
+// to.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || forbiddenAddr(addrPort.Addr()) {
+				return ErrForbiddenAddress
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Checks that a host resolves only to addresses webhooks can be sent to.
+func CheckHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if forbiddenAddr(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// Signs a request body sent at a Unix timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Reports whether a signature is valid for a request body sent at a Unix
+// timestamp, for receivers written in Go.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Returns how long to wait before retrying a delivery that has failed the
+// given number of times. Waits are jittered so that deliveries failing
+// together are not all retried at once.
+func Backoff(failures int) time.Duration {
+	wait := maxBackoff
+	if failures <= 0 {
+		failures = 1
+	}
+	if failures <= 20 {
+		wait = min(baseBackoff<<(failures-1), maxBackoff)
+	}
+
+	// Wait between 80% and 100% of the backoff
+	return wait - time.Duration(rand.Int64N(int64(wait)/5+1))
+}
+
+// Generates a secret for signing a webhook's requests.
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := cryptorand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// Helper Functions
+
+// Returns the client to send deliveries with.
+func (d *Dispatcher) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+
+	d.defaultClientOnce.Do(func() {
+		d.defaultClient = NewClient(DefaultTimeout)
+	})
+
+	return d.defaultClient
+}
+
+// Reports whether an address is one webhooks cannot be sent to.
+func forbiddenAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Describes why a request failed without details of the receiver's network.
+func describeError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrForbiddenAddress):
+		return "receiver address is not allowed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	}
+
+	return "could not reach receiver"
+}
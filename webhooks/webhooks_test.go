@@ -0,0 +1,410 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store holding deliveries in memory. Pending deliveries
+// are claimed whenever Dispatch runs, regardless of when they are due.
+type memoryStore struct {
+	mu         sync.Mutex
+	deliveries []*storedDelivery
+}
+
+type storedDelivery struct {
+	delivery Delivery
+	status   string
+	results  []Result
+	retryAt  time.Time
+}
+
+func (s *memoryStore) QueueDeliveries(ctx context.Context) error {
+	return nil
+}
+
+func (s *memoryStore) ClaimDeliveries(ctx context.Context, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := []Delivery{}
+	for _, stored := range s.deliveries {
+		if stored.status == StatusPending && len(claimed) < limit {
+			claimed = append(claimed, stored.delivery)
+		}
+	}
+
+	return claimed, nil
+}
+
+func (s *memoryStore) RecordAttempt(ctx context.Context, delivery Delivery, result Result, status string, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.deliveries {
+		if stored.delivery.ID == delivery.ID {
+			stored.delivery.Attempts++
+			stored.status = status
+			stored.results = append(stored.results, result)
+			stored.retryAt = retryAt
+		}
+	}
+
+	return nil
+}
+
+// receiver is a webhook endpoint that checks signatures and fails the
+// first failures requests.
+type receiver struct {
+	mu         sync.Mutex
+	secret     string
+	failures   int
+	requests   int
+	deliveries []string
+	t          *testing.T
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.requests++
+	rc.deliveries = append(rc.deliveries, r.Header.Get("X-Webhook-Delivery"))
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Error(err)
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil {
+		rc.t.Errorf("bad timestamp header: %v", err)
+	}
+
+	if !Verify(rc.secret, timestamp, body, r.Header.Get("X-Webhook-Signature")) {
+		rc.t.Errorf("signature %q does not match", r.Header.Get("X-Webhook-Signature"))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("X-Webhook-Event") != RecipeUpdated || r.Header.Get("Content-Type") != "application/json" {
+		rc.t.Errorf("got event %q and content type %q", r.Header.Get("X-Webhook-Event"), r.Header.Get("Content-Type"))
+	}
+
+	if rc.requests <= rc.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "stack trace with internal details")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.requests
+}
+
+func newDelivery(url string) *storedDelivery {
+	return &storedDelivery{
+		delivery: Delivery{
+			ID:     7,
+			URL:    url,
+			Secret: "whsec_test",
+			Event:  RecipeUpdated,
+			Body:   []byte(`{"type":"recipe.updated","recipeId":3}`),
+		},
+		status: StatusPending,
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"recipeId":3}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(`1700000000.{"recipeId":3}`))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("whsec_test", 1700000000, body); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"recipeId":3}`)
+	signature := Sign("whsec_test", 1700000000, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", "whsec_test", 1700000000, body, signature, true},
+		{"other secret", "whsec_other", 1700000000, body, signature, false},
+		{"other timestamp", "whsec_test", 1700000001, body, signature, false},
+		{"other body", "whsec_test", 1700000000, []byte(`{"recipeId":4}`), signature, false},
+		{"without prefix", "whsec_test", 1700000000, body, strings.TrimPrefix(signature, "sha256="), false},
+		{"empty", "whsec_test", 1700000000, body, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Verify(test.secret, test.timestamp, test.body, test.signature); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		wait     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.failures), func(t *testing.T) {
+			for range 100 {
+				got := Backoff(test.failures)
+				if got < test.wait*4/5 || got > test.wait {
+					t.Fatalf("got %v, want between %v and %v", got, test.wait*4/5, test.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchRetriesUntilDelivered(t *testing.T) {
+	rc := &receiver{secret: "whsec_test", failures: 2, t: t}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	stored := newDelivery(server.URL)
+	store := &memoryStore{deliveries: []*storedDelivery{stored}}
+	dispatcher := Dispatcher{Store: store, Client: server.Client(), MaxAttempts: 5}
+
+	wantStatuses := []string{StatusPending, StatusPending, StatusSucceeded}
+	for i, want := range wantStatuses {
+		start := time.Now()
+		if err := dispatcher.Dispatch(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if stored.status != want || stored.delivery.Attempts != i+1 {
+			t.Fatalf("after attempt %d got %s with %d attempts, want %s", i+1, stored.status, stored.delivery.Attempts, want)
+		}
+
+		// Retries back off further after every failure
+		if wait := stored.retryAt.Sub(start); wait < (baseBackoff<<i)*4/5-time.Second || wait > baseBackoff<<i+time.Second {
+			t.Errorf("attempt %d retries after %v", i+1, wait)
+		}
+	}
+
+	// Nothing is sent once the delivery succeeded
+	if err := dispatcher.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rc.count() != 3 {
+		t.Errorf("receiver got %d requests, want 3", rc.count())
+	}
+
+	for _, id := range rc.deliveries {
+		if id != "7" {
+			t.Errorf("got delivery ID %q, want 7 on every attempt", id)
+		}
+	}
+
+	failed, succeeded := stored.results[0], stored.results[2]
+	if failed.StatusCode != http.StatusInternalServerError || succeeded.StatusCode != http.StatusNoContent || succeeded.Error != "" {
+		t.Errorf("got results %+v", stored.results)
+	}
+
+	// The receiver's response is not kept
+	if strings.Contains(failed.Error, "internal details") {
+		t.Errorf("result error %q holds the response body", failed.Error)
+	}
+}
+
+func TestDispatchDeadLetters(t *testing.T) {
+	rc := &receiver{secret: "whsec_test", failures: 100, t: t}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	stored := newDelivery(server.URL)
+	store := &memoryStore{deliveries: []*storedDelivery{stored}}
+	dispatcher := Dispatcher{Store: store, Client: server.Client(), MaxAttempts: 3}
+
+	for range 5 {
+		if err := dispatcher.Dispatch(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if stored.status != StatusDead || stored.delivery.Attempts != 3 {
+		t.Errorf("got %s with %d attempts, want dead after 3", stored.status, stored.delivery.Attempts)
+	}
+	if rc.count() != 3 {
+		t.Errorf("receiver got %d requests, want 3", rc.count())
+	}
+}
+
+func TestDispatchLeavesCancelledDeliveriesClaimed(t *testing.T) {
+	rc := &receiver{secret: "whsec_test", t: t}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	stored := newDelivery(server.URL)
+	store := &memoryStore{deliveries: []*storedDelivery{stored}}
+	dispatcher := Dispatcher{Store: store, Client: server.Client()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := dispatcher.Dispatch(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if stored.delivery.Attempts != 0 || stored.status != StatusPending {
+		t.Errorf("got %s with %d attempts, want an unrecorded attempt", stored.status, stored.delivery.Attempts)
+	}
+}
+
+func TestDispatcherReusesDefaultClient(t *testing.T) {
+	dispatcher := Dispatcher{}
+
+	first := dispatcher.client()
+	if first == nil || dispatcher.client() != first {
+		t.Error("got a new client for each dispatch, want one reused client")
+	}
+
+	custom := &http.Client{}
+	dispatcher.Client = custom
+	if dispatcher.client() != custom {
+		t.Error("did not use the configured client")
+	}
+}
+
+func TestNewClientRefusesForbiddenAddresses(t *testing.T) {
+	rc := &receiver{secret: "whsec_test", t: t}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	result := Send(context.Background(), NewClient(time.Second), newDelivery(server.URL).delivery, time.Now())
+
+	if result.StatusCode != 0 || result.Error != "receiver address is not allowed" {
+		t.Errorf("got %+v, want a refused connection", result)
+	}
+	if rc.count() != 0 {
+		t.Errorf("receiver got %d requests, want none", rc.count())
+	}
+}
+
+func TestNewClientDoesNotFollowRedirects(t *testing.T) {
+	rc := &receiver{secret: "whsec_test", t: t}
+	target := httptest.NewServer(rc)
+	defer target.Close()
+
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	// Use the test server's transport, which may reach loopback addresses
+	client := NewClient(time.Second)
+	client.Transport = redirect.Client().Transport
+
+	result := Send(context.Background(), client, newDelivery(redirect.URL).delivery, time.Now())
+
+	if result.StatusCode != http.StatusTemporaryRedirect || result.Succeeded() {
+		t.Errorf("got %+v, want the redirect as a failure", result)
+	}
+	if rc.count() != 0 {
+		t.Errorf("redirect was followed")
+	}
+}
+
+func TestForbiddenAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.0.0.5", true},
+		{"172.16.3.4", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"224.0.0.1", true},
+		{"ff02::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"100.64.0.1", true},
+		{"198.18.0.1", true},
+		{"255.255.255.255", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			if got := forbiddenAddr(netip.MustParseAddr(test.addr)); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	if err := CheckHost(context.Background(), "93.184.216.34"); err != nil {
+		t.Errorf("got %v for a public address", err)
+	}
+
+	for _, host := range []string{"127.0.0.1", "10.1.2.3", "::1"} {
+		if err := CheckHost(context.Background(), host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("got %v for %s, want %v", err, host, ErrForbiddenAddress)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	first, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(first, "whsec_") || len(first) != len("whsec_")+64 || first == second {
+		t.Errorf("got secrets %q and %q", first, second)
+	}
+}